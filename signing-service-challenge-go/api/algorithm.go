@@ -0,0 +1,59 @@
+package api
+
+import "net/http"
+
+// AlgorithmParametersResponse lists the parameters an algorithm uses when none are requested.
+type AlgorithmParametersResponse struct {
+	RSABits           int    `json:"rsa_bits,omitempty"`
+	Curve             string `json:"curve,omitempty"`
+	Hash              string `json:"hash,omitempty"`
+	SaltLength        int    `json:"salt_length,omitempty"`
+	SignatureEncoding string `json:"signature_encoding,omitempty"`
+}
+
+// AlgorithmResponse describes a signature algorithm devices can be created with.
+type AlgorithmResponse struct {
+	Name              string                      `json:"name"`
+	Description       string                      `json:"description"`
+	KeyType           string                      `json:"key_type"`
+	SignatureScheme   string                      `json:"signature_scheme"`
+	DefaultParameters AlgorithmParametersResponse `json:"default_parameters"`
+}
+
+// ListAlgorithmsResponse represents the response after listing the supported algorithms.
+type ListAlgorithmsResponse struct {
+	Algorithms []AlgorithmResponse `json:"algorithms"`
+}
+
+// ListAlgorithms lists the supported signature algorithms.
+func (s *Server) ListAlgorithms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, []string{
+			http.StatusText(http.StatusMethodNotAllowed),
+		})
+		return
+	}
+
+	algorithms := s.DeviceService.ListAlgorithms()
+
+	algorithmResponses := make([]AlgorithmResponse, len(algorithms))
+	for i, algorithm := range algorithms {
+		algorithmResponses[i] = AlgorithmResponse{
+			Name:            algorithm.Name,
+			Description:     algorithm.Metadata.Description,
+			KeyType:         algorithm.Metadata.KeyType,
+			SignatureScheme: algorithm.Metadata.SignatureScheme,
+			DefaultParameters: AlgorithmParametersResponse{
+				RSABits:           algorithm.DefaultParameters.RSABits,
+				Curve:             algorithm.DefaultParameters.Curve,
+				Hash:              algorithm.DefaultParameters.Hash,
+				SaltLength:        algorithm.DefaultParameters.SaltLength,
+				SignatureEncoding: algorithm.DefaultParameters.SignatureEncoding,
+			},
+		}
+	}
+
+	WriteAPIResponse(w, http.StatusOK, ListAlgorithmsResponse{
+		Algorithms: algorithmResponses,
+	})
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/errors"
+	"net/http"
+)
+
+// AuditResponse represents the outcome of a device's signature chain audit.
+type AuditResponse struct {
+	DeviceID            string `json:"device_id"`
+	TransactionsChecked int    `json:"transactions_checked"`
+	Valid               bool   `json:"valid"`
+	BrokenAt            *int   `json:"broken_at,omitempty"`
+	Reason              string `json:"reason,omitempty"`
+}
+
+// AuditDevice verifies the integrity of the complete signature chain of a device.
+func (s *Server) AuditDevice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, []string{
+			http.StatusText(http.StatusMethodNotAllowed),
+		})
+		return
+	}
+
+	deviceId := r.PathValue("deviceId")
+
+	report, err := s.TransactionService.AuditDevice(deviceId)
+	if err != nil {
+		appErr := errors.FromError(err)
+		WriteErrorResponse(w, appErr.Code, []string{appErr.Message})
+		return
+	}
+
+	WriteAPIResponse(w, http.StatusOK, AuditResponse{
+		DeviceID:            report.DeviceID,
+		TransactionsChecked: report.TransactionsChecked,
+		Valid:               report.Valid,
+		BrokenAt:            report.BrokenAt,
+		Reason:              report.Reason,
+	})
+}
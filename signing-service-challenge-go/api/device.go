@@ -2,13 +2,26 @@ package api
 
 import (
 	"encoding/json"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/errors"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
 	"net/http"
+	"time"
 )
 
 // CreateSignatureDeviceRequest represents the request to create a signature device.
+// Key size, curve and hash are optional and default to the algorithm's defaults.
 type CreateSignatureDeviceRequest struct {
-	Algorithm string `json:"algorithm"`
-	Label     string `json:"label,omitempty"`
+	Algorithm         string `json:"algorithm"`
+	Label             string `json:"label,omitempty"`
+	RSABits           int    `json:"rsa_bits,omitempty"`
+	Curve             string `json:"curve,omitempty"`
+	Hash              string `json:"hash,omitempty"`
+	SaltLength        int    `json:"salt_length,omitempty"`
+	SignatureEncoding string `json:"signature_encoding,omitempty"`
+	// SecuredDataFormat is LEGACY, LENGTH_PREFIXED or JSON and defaults to LEGACY.
+	SecuredDataFormat string `json:"secured_data_format,omitempty"`
 }
 
 // CreateSignatureDeviceResponse represents the response after creating a signature device.
@@ -18,10 +31,20 @@ type CreateSignatureDeviceResponse struct {
 
 // DeviceResponse represents a device's details in the ListDevices response.
 type DeviceResponse struct {
-	ID               string `json:"id"`
-	Label            string `json:"label,omitempty"`
-	Algorithm        string `json:"algorithm"`
-	SignatureCounter int    `json:"signature_counter"`
+	ID                string            `json:"id"`
+	Label             string            `json:"label,omitempty"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+	Algorithm         string            `json:"algorithm"`
+	RSABits           int               `json:"rsa_bits,omitempty"`
+	Curve             string            `json:"curve,omitempty"`
+	Hash              string            `json:"hash,omitempty"`
+	SaltLength        int               `json:"salt_length,omitempty"`
+	SignatureEncoding string            `json:"signature_encoding,omitempty"`
+	SignatureCounter  int               `json:"signature_counter"`
+	KeyGeneration     int               `json:"key_generation"`
+	SecuredDataFormat string            `json:"secured_data_format"`
+	Status            string            `json:"status"`
+	DecommissionedAt  *time.Time        `json:"decommissioned_at,omitempty"`
 }
 
 // ListDevicesResponse represents the response after listing devices.
@@ -45,7 +68,13 @@ func (s *Server) CreateSignatureDevice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	device, err := s.DeviceService.CreateSignatureDevice(req.Algorithm, req.Label)
+	device, err := s.DeviceService.CreateSignatureDeviceWithFormat(req.Algorithm, req.Label, crypto.Parameters{
+		RSABits:           req.RSABits,
+		Curve:             req.Curve,
+		Hash:              req.Hash,
+		SaltLength:        req.SaltLength,
+		SignatureEncoding: req.SignatureEncoding,
+	}, domain.SecuredDataFormat(req.SecuredDataFormat))
 	if err != nil {
 		WriteErrorResponse(w, http.StatusBadRequest, []string{err.Error()})
 		return
@@ -75,12 +104,7 @@ func (s *Server) ListDevices(w http.ResponseWriter, r *http.Request) {
 
 	deviceResponses := make([]DeviceResponse, len(devices))
 	for i, device := range devices {
-		deviceResponses[i] = DeviceResponse{
-			ID:               device.ID.String(),
-			Label:            device.Label,
-			Algorithm:        device.Algorithm,
-			SignatureCounter: device.SignatureCounter,
-		}
+		deviceResponses[i] = newDeviceResponse(device)
 	}
 
 	WriteAPIResponse(w, http.StatusOK, ListDevicesResponse{
@@ -88,6 +112,16 @@ func (s *Server) ListDevices(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// DeviceById dispatches requests for a single device by their method.
+func (s *Server) DeviceById(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPatch:
+		s.UpdateDevice(w, r)
+	default:
+		s.GetDeviceById(w, r)
+	}
+}
+
 // GetDeviceById fetches a specific device by its ID.
 func (s *Server) GetDeviceById(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -105,12 +139,122 @@ func (s *Server) GetDeviceById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := DeviceResponse{
-		ID:               device.ID.String(),
-		Label:            device.Label,
-		Algorithm:        device.Algorithm,
-		SignatureCounter: device.SignatureCounter,
+	WriteAPIResponse(w, http.StatusOK, newDeviceResponse(device))
+}
+
+// UpdateDeviceRequest represents the request to update a signature device. Omitted fields are left unchanged.
+// Metadata entries are merged into the device's metadata; entries set to null are removed.
+type UpdateDeviceRequest struct {
+	Label    *string            `json:"label,omitempty"`
+	Metadata map[string]*string `json:"metadata,omitempty"`
+	Status   *string            `json:"status,omitempty"`
+}
+
+// UpdateDevice applies a partial update to a signature device.
+// The status moves the device through its lifecycle: ACTIVE, SUSPENDED and finally DECOMMISSIONED.
+// The algorithm, keys and signature counter are immutable, so requests touching them are rejected.
+func (s *Server) UpdateDevice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, []string{
+			http.StatusText(http.StatusMethodNotAllowed),
+		})
+		return
+	}
+
+	var req UpdateDeviceRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&req)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, []string{
+			"Invalid request payload, only label, metadata and status can be updated",
+		})
+		return
+	}
+	if req.Label == nil && req.Metadata == nil && req.Status == nil {
+		WriteErrorResponse(w, http.StatusBadRequest, []string{"Nothing to update"})
+		return
+	}
+
+	update := service.DeviceUpdate{
+		Label:    req.Label,
+		Metadata: req.Metadata,
+	}
+	if req.Status != nil {
+		status, err := domain.ParseDeviceStatus(*req.Status)
+		if err != nil {
+			WriteErrorResponse(w, http.StatusBadRequest, []string{err.Error()})
+			return
+		}
+		update.Status = &status
+	}
+
+	device, err := s.DeviceService.UpdateDevice(r.PathValue("deviceId"), update)
+	writeDeviceResult(w, device, err)
+}
+
+// DeactivateDevice suspends a signature device, so that it refuses to sign until it is reactivated.
+func (s *Server) DeactivateDevice(w http.ResponseWriter, r *http.Request) {
+	s.handleStatusAction(w, r, domain.DeviceStatusSuspended)
+}
+
+// ReactivateDevice lets a suspended signature device sign again.
+func (s *Server) ReactivateDevice(w http.ResponseWriter, r *http.Request) {
+	s.handleStatusAction(w, r, domain.DeviceStatusActive)
+}
+
+// DecommissionDevice permanently retires a signature device.
+func (s *Server) DecommissionDevice(w http.ResponseWriter, r *http.Request) {
+	s.handleStatusAction(w, r, domain.DeviceStatusDecommissioned)
+}
+
+// handleStatusAction serves the dedicated POST endpoints of the device lifecycle.
+func (s *Server) handleStatusAction(w http.ResponseWriter, r *http.Request, status domain.DeviceStatus) {
+	if r.Method != http.MethodPost {
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, []string{
+			http.StatusText(http.StatusMethodNotAllowed),
+		})
+		return
+	}
+
+	device, err := s.DeviceService.ChangeDeviceStatus(r.PathValue("deviceId"), status)
+	writeDeviceResult(w, device, err)
+}
+
+// writeDeviceResult writes the outcome of a device update as an HTTP response.
+func writeDeviceResult(w http.ResponseWriter, device *domain.SignatureDevice, err error) {
+	if err != nil {
+		appErr := errors.FromError(err)
+		WriteErrorResponse(w, appErr.Code, []string{appErr.Message})
+		return
+	}
+
+	WriteAPIResponse(w, http.StatusOK, newDeviceResponse(device))
+}
+
+func newDeviceResponse(device *domain.SignatureDevice) DeviceResponse {
+	label, metadata := device.Details()
+	status, decommissionedAt := device.LifecycleState()
+
+	var decommissioned *time.Time
+	if status == domain.DeviceStatusDecommissioned {
+		decommissioned = &decommissionedAt
 	}
 
-	WriteAPIResponse(w, http.StatusOK, response)
+	return DeviceResponse{
+		ID:                device.ID.String(),
+		Label:             label,
+		Metadata:          metadata,
+		Algorithm:         device.Algorithm,
+		RSABits:           device.Parameters.RSABits,
+		Curve:             device.Parameters.Curve,
+		Hash:              device.Parameters.Hash,
+		SaltLength:        device.Parameters.SaltLength,
+		SignatureEncoding: device.Parameters.SignatureEncoding,
+		SignatureCounter:  device.SignatureCounter,
+		KeyGeneration:     device.CurrentKeyGeneration(),
+		SecuredDataFormat: string(device.SecuredDataFormat),
+		Status:            string(status),
+		DecommissionedAt:  decommissioned,
+	}
 }
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/errors"
+	"net/http"
+	"time"
+)
+
+// KeyGenerationResponse represents a key a device signs or signed with, and the counters it covers.
+type KeyGenerationResponse struct {
+	Generation int        `json:"generation"`
+	PublicKey  string     `json:"public_key"`
+	ValidFrom  int        `json:"valid_from_counter"`
+	ValidUntil *int       `json:"valid_until_counter,omitempty"`
+	RetiredAt  *time.Time `json:"retired_at,omitempty"`
+}
+
+// ListKeysResponse represents the key history of a device, ending with the current key.
+type ListKeysResponse struct {
+	Keys []KeyGenerationResponse `json:"keys"`
+}
+
+// RotateKeyResponse represents the outcome of a key rotation.
+type RotateKeyResponse struct {
+	KeyGeneration  int                     `json:"key_generation"`
+	RotationRecord TransactionResponse     `json:"rotation_record"`
+	Keys           []KeyGenerationResponse `json:"keys"`
+}
+
+// RotateKey replaces the key pair of a device. The rotation record announcing the new public key
+// is signed with the old key and becomes the next transaction of the device's chain.
+func (s *Server) RotateKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, []string{
+			http.StatusText(http.StatusMethodNotAllowed),
+		})
+		return
+	}
+
+	deviceId := r.PathValue("deviceId")
+
+	key, err := s.DeviceService.GenerateKey(deviceId)
+	if err != nil {
+		appErr := errors.FromError(err)
+		WriteErrorResponse(w, appErr.Code, []string{appErr.Message})
+		return
+	}
+
+	rotation, err := s.TransactionService.RotateKey(deviceId, key)
+	if err != nil {
+		appErr := errors.FromError(err)
+		WriteErrorResponse(w, appErr.Code, []string{appErr.Message})
+		return
+	}
+
+	device, exists := s.DeviceService.GetDevice(deviceId)
+	if !exists {
+		WriteErrorResponse(w, http.StatusNotFound, []string{"Device not found"})
+		return
+	}
+	keys, err := newKeyGenerationResponses(device.KeyGenerations())
+	if err != nil {
+		WriteInternalError(w)
+		return
+	}
+
+	WriteAPIResponse(w, http.StatusOK, RotateKeyResponse{
+		KeyGeneration:  rotation.Current.Generation,
+		RotationRecord: newTransactionResponse(rotation.Transaction),
+		Keys:           keys,
+	})
+}
+
+// ListKeys lists all keys of a device with the signature counters they cover,
+// so that signatures made before a key rotation can still be verified.
+func (s *Server) ListKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, []string{
+			http.StatusText(http.StatusMethodNotAllowed),
+		})
+		return
+	}
+
+	device, exists := s.DeviceService.GetDevice(r.PathValue("deviceId"))
+	if !exists {
+		WriteErrorResponse(w, http.StatusNotFound, []string{"Device not found"})
+		return
+	}
+
+	keys, err := newKeyGenerationResponses(device.KeyGenerations())
+	if err != nil {
+		WriteInternalError(w)
+		return
+	}
+
+	WriteAPIResponse(w, http.StatusOK, ListKeysResponse{Keys: keys})
+}
+
+func newKeyGenerationResponses(keys []domain.KeyGeneration) ([]KeyGenerationResponse, error) {
+	responses := make([]KeyGenerationResponse, len(keys))
+	for i, key := range keys {
+		publicKey, err := crypto.EncodePublicKeyPEM(key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		responses[i] = KeyGenerationResponse{
+			Generation: key.Generation,
+			PublicKey:  string(publicKey),
+			ValidFrom:  key.ValidFrom,
+		}
+		if key.ValidUntil != 0 {
+			validUntil, retiredAt := key.ValidUntil, key.RetiredAt
+			responses[i].ValidUntil = &validUntil
+			responses[i].RetiredAt = &retiredAt
+		}
+	}
+	return responses, nil
+}
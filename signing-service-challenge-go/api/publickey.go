@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/errors"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Media types used for public key content negotiation.
+const (
+	ContentTypePEM  = "application/x-pem-file"
+	ContentTypeDER  = "application/pkix-spki"
+	ContentTypeJWK  = "application/jwk+json"
+	ContentTypeJWKS = "application/jwk-set+json"
+)
+
+var publicKeyContentTypes = map[string]string{
+	service.PublicKeyFormatPEM: ContentTypePEM,
+	service.PublicKeyFormatDER: ContentTypeDER,
+	service.PublicKeyFormatJWK: ContentTypeJWK,
+}
+
+// GetPublicKey exports the public key of a device.
+// The format is chosen via the `format` query parameter (pem, der, jwk) or, if absent,
+// negotiated from the Accept header. PEM is returned by default.
+func (s *Server) GetPublicKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, []string{
+			http.StatusText(http.StatusMethodNotAllowed),
+		})
+		return
+	}
+
+	deviceId := r.PathValue("deviceId")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		var ok bool
+		format, ok = negotiatePublicKeyFormat(r.Header.Get("Accept"))
+		if !ok {
+			WriteErrorResponse(w, http.StatusNotAcceptable, []string{
+				"Supported media types are " + ContentTypePEM + ", " + ContentTypeDER + " and " + ContentTypeJWK,
+			})
+			return
+		}
+	}
+
+	encoded, err := s.DeviceService.ExportPublicKey(deviceId, strings.ToLower(format))
+	if err != nil {
+		appErr := errors.FromError(err)
+		WriteErrorResponse(w, appErr.Code, []string{appErr.Message})
+		return
+	}
+
+	w.Header().Set("Content-Type", publicKeyContentTypes[strings.ToLower(format)])
+	w.WriteHeader(http.StatusOK)
+	w.Write(encoded)
+}
+
+// ListPublicKeys returns a JWKS document with the public keys of all devices.
+func (s *Server) ListPublicKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, []string{
+			http.StatusText(http.StatusMethodNotAllowed),
+		})
+		return
+	}
+
+	jwks, err := s.DeviceService.ListPublicKeys()
+	if err != nil {
+		WriteInternalError(w)
+		return
+	}
+
+	bytes, err := json.Marshal(jwks)
+	if err != nil {
+		WriteInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentTypeJWKS)
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
+
+// negotiatePublicKeyFormat picks the first supported public key format listed in an Accept header.
+func negotiatePublicKeyFormat(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return service.PublicKeyFormatPEM, true
+	}
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+
+		switch mediaType {
+		case ContentTypePEM, "*/*", "application/*", "text/plain":
+			return service.PublicKeyFormatPEM, true
+		case ContentTypeDER, "application/octet-stream":
+			return service.PublicKeyFormatDER, true
+		case ContentTypeJWK, "application/json":
+			return service.PublicKeyFormatJWK, true
+		}
+	}
+
+	return "", false
+}
@@ -50,8 +50,21 @@ func (s *Server) Run() error {
 	// TODO: register further HandlerFuncs here ...
 	mux.Handle("/api/v0/devices", http.HandlerFunc(s.CreateSignatureDevice))
 	mux.Handle("/api/v0/devices/list", http.HandlerFunc(s.ListDevices))
-	mux.Handle("/api/v0/devices/{deviceId}", http.HandlerFunc(s.GetDeviceById))
+	mux.Handle("/api/v0/devices/{deviceId}", http.HandlerFunc(s.DeviceById))
+	mux.Handle("/api/v0/devices/{deviceId}/deactivate", http.HandlerFunc(s.DeactivateDevice))
+	mux.Handle("/api/v0/devices/{deviceId}/reactivate", http.HandlerFunc(s.ReactivateDevice))
+	mux.Handle("/api/v0/devices/{deviceId}/decommission", http.HandlerFunc(s.DecommissionDevice))
+	mux.Handle("/api/v0/devices/{deviceId}/rotate-key", http.HandlerFunc(s.RotateKey))
+	mux.Handle("/api/v0/devices/{deviceId}/keys", http.HandlerFunc(s.ListKeys))
+	mux.Handle("/api/v0/devices/{deviceId}/transactions", http.HandlerFunc(s.ListTransactions))
+	mux.Handle("/api/v0/devices/{deviceId}/transactions/{counter}", http.HandlerFunc(s.GetTransaction))
+	mux.Handle("/api/v0/devices/{deviceId}/verify", http.HandlerFunc(s.VerifySignature))
+	mux.Handle("/api/v0/devices/{deviceId}/audit", http.HandlerFunc(s.AuditDevice))
+	mux.Handle("/api/v0/devices/{deviceId}/public-key", http.HandlerFunc(s.GetPublicKey))
+	mux.Handle("/api/v0/devices/jwks", http.HandlerFunc(s.ListPublicKeys))
+	mux.Handle("/api/v0/algorithms", http.HandlerFunc(s.ListAlgorithms))
 	mux.Handle("/api/v0/transactions/{deviceId}/sign", http.HandlerFunc(s.SignTransaction))
+	mux.Handle("/api/v0/transactions/{deviceId}/sign-batch", http.HandlerFunc(s.SignTransactionBatch))
 
 	return http.ListenAndServe(s.listenAddress, mux)
 }
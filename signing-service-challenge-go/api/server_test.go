@@ -2,21 +2,36 @@ package api_test
 
 import (
 	"bytes"
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/infrastructure"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/mocks"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 )
 
 func setupServer() *api.Server {
 	deviceRepo := mocks.NewMockDeviceRepository()
-	deviceService := service.NewDeviceService(deviceRepo)
-	transactionService := service.NewTransactionService(deviceRepo)
+	transactionRepo := mocks.NewMockTransactionRepository()
+	deviceService := service.NewDeviceService(deviceRepo, crypto.DefaultRegistry)
+	transactionService := service.NewTransactionService(deviceRepo, transactionRepo)
 	return api.NewServer(":8086", deviceRepo, deviceService, transactionService)
 }
 
@@ -25,7 +40,20 @@ func setupRouter(s *api.Server) *http.ServeMux {
 	mux.Handle("/api/v0/health", http.HandlerFunc(s.Health))
 	mux.Handle("/api/v0/devices", http.HandlerFunc(s.CreateSignatureDevice))
 	mux.Handle("/api/v0/devices/list", http.HandlerFunc(s.ListDevices))
-	mux.Handle("/api/v0/devices/", http.HandlerFunc(s.GetDeviceById))
+	mux.Handle("/api/v0/devices/", http.HandlerFunc(s.DeviceById))
+	mux.Handle("/api/v0/devices/{deviceId}/deactivate", http.HandlerFunc(s.DeactivateDevice))
+	mux.Handle("/api/v0/devices/{deviceId}/reactivate", http.HandlerFunc(s.ReactivateDevice))
+	mux.Handle("/api/v0/devices/{deviceId}/decommission", http.HandlerFunc(s.DecommissionDevice))
+	mux.Handle("/api/v0/devices/{deviceId}/rotate-key", http.HandlerFunc(s.RotateKey))
+	mux.Handle("/api/v0/devices/{deviceId}/keys", http.HandlerFunc(s.ListKeys))
+	mux.Handle("/api/v0/devices/{deviceId}/transactions", http.HandlerFunc(s.ListTransactions))
+	mux.Handle("/api/v0/devices/{deviceId}/transactions/{counter}", http.HandlerFunc(s.GetTransaction))
+	mux.Handle("/api/v0/devices/{deviceId}/verify", http.HandlerFunc(s.VerifySignature))
+	mux.Handle("/api/v0/devices/{deviceId}/audit", http.HandlerFunc(s.AuditDevice))
+	mux.Handle("/api/v0/devices/{deviceId}/public-key", http.HandlerFunc(s.GetPublicKey))
+	mux.Handle("/api/v0/devices/jwks", http.HandlerFunc(s.ListPublicKeys))
+	mux.Handle("/api/v0/algorithms", http.HandlerFunc(s.ListAlgorithms))
+	mux.Handle("/api/v0/transactions/{deviceId}/sign-batch", http.HandlerFunc(s.SignTransactionBatch))
 	mux.Handle("/api/v0/transactions/", http.HandlerFunc(s.SignTransaction))
 	return mux
 }
@@ -89,6 +117,12 @@ func TestCreateSignatureDeviceSuccess(t *testing.T) {
 			label:          "Test RSA Device",
 			expectedStatus: http.StatusCreated,
 		},
+		{
+			name:           "Valid Ed25519 Device",
+			algorithm:      "ED25519",
+			label:          "Test Ed25519 Device",
+			expectedStatus: http.StatusCreated,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -241,12 +275,14 @@ func TestConcurrentTransactionSigning(t *testing.T) {
 			}
 			signRequestBody, err := json.Marshal(signRequest)
 			if err != nil {
-				t.Fatalf("Error marshalling sign transaction request: %v", err)
+				t.Errorf("Error marshalling sign transaction request: %v", err)
+				return
 			}
 
 			signReq, err := http.NewRequest(http.MethodPost, "/api/v0/transactions/{deviceId}/sign", bytes.NewBuffer(signRequestBody))
 			if err != nil {
-				t.Fatalf("Error creating sign transaction request: %v", err)
+				t.Errorf("Error creating sign transaction request: %v", err)
+				return
 			}
 
 			signReq.SetPathValue("deviceId", deviceId)
@@ -257,17 +293,19 @@ func TestConcurrentTransactionSigning(t *testing.T) {
 			router.ServeHTTP(signW, signReq)
 
 			if signW.Code != http.StatusOK {
-				t.Fatalf("Expected status code %d, got %d", http.StatusOK, signW.Code)
+				t.Errorf("Expected status code %d, got %d", http.StatusOK, signW.Code)
+				return
 			}
 
 			var signResponse struct {
 				Data api.SignTransactionResponse `json:"data"`
 			}
 			if err := json.NewDecoder(signW.Body).Decode(&signResponse); err != nil {
-				t.Fatalf("Error decoding sign transaction response: %v", err)
+				t.Errorf("Error decoding sign transaction response: %v", err)
+				return
 			}
 			if signResponse.Data.SignedData == "" || signResponse.Data.Signature == "" {
-				t.Fatalf("Expected non-empty signed data and signature")
+				t.Errorf("Expected non-empty signed data and signature")
 			}
 
 		}(i)
@@ -275,87 +313,1547 @@ func TestConcurrentTransactionSigning(t *testing.T) {
 
 	wg.Wait()
 }
-func TestGetDeviceById(t *testing.T) {
+func TestConcurrentSigningChainIntegrity(t *testing.T) {
+	const signatureCount = 2000
+
 	s := setupServer()
+	deviceId := createSignatureDeviceWithServer(t, s, "ECC", "Stress Test Device", http.StatusCreated)
+	router := setupRouter(s)
 
-	deviceId := createSignatureDeviceWithServer(t, s, "ECC", "Test ECC Device", http.StatusCreated)
+	responses := make([]api.SignTransactionResponse, signatureCount)
+	var wg sync.WaitGroup
+	for i := 0; i < signatureCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
 
-	t.Run("Valid Device ID", func(t *testing.T) {
-		getReq := httptest.NewRequest(http.MethodGet, "/api/v0/devices/{deviceId}", nil)
-		getReq.SetPathValue("deviceId", deviceId)
-		getW := httptest.NewRecorder()
+			signRequestBody, err := json.Marshal(api.SignTransactionRequest{
+				Data: fmt.Sprintf("Stress transaction %d", i),
+			})
+			if err != nil {
+				t.Errorf("Error marshalling sign transaction request: %v", err)
+				return
+			}
 
-		router := setupRouter(s)
-		router.ServeHTTP(getW, getReq)
+			signReq := httptest.NewRequest(http.MethodPost, "/api/v0/transactions/{deviceId}/sign", bytes.NewBuffer(signRequestBody))
+			signReq.SetPathValue("deviceId", deviceId)
+			signReq.Header.Set("Content-Type", "application/json")
+			signW := httptest.NewRecorder()
+			router.ServeHTTP(signW, signReq)
 
-		if getW.Code != http.StatusOK {
-			t.Fatalf("Expected status code %d, got %d", http.StatusOK, getW.Code)
+			if signW.Code != http.StatusOK {
+				t.Errorf("Expected status code %d, got %d", http.StatusOK, signW.Code)
+				return
+			}
+
+			var signResponse struct {
+				Data api.SignTransactionResponse `json:"data"`
+			}
+			if err := json.NewDecoder(signW.Body).Decode(&signResponse); err != nil {
+				t.Errorf("Error decoding sign transaction response: %v", err)
+				return
+			}
+			responses[i] = signResponse.Data
+		}(i)
+	}
+	wg.Wait()
+
+	if t.Failed() {
+		t.FailNow()
+	}
+
+	// Order the responses by the counter embedded in the secured data.
+	chain := make([]*api.SignTransactionResponse, signatureCount)
+	for i := range responses {
+		counterPart, _, found := strings.Cut(responses[i].SignedData, "_")
+		if !found {
+			t.Fatalf("Malformed signed data %q", responses[i].SignedData)
 		}
+		counter, err := strconv.Atoi(counterPart)
+		if err != nil {
+			t.Fatalf("Malformed signature counter in %q: %v", responses[i].SignedData, err)
+		}
+		if counter < 0 || counter >= signatureCount {
+			t.Fatalf("Signature counter %d out of range [0, %d)", counter, signatureCount)
+		}
+		if chain[counter] != nil {
+			t.Fatalf("Signature counter %d was issued more than once", counter)
+		}
+		chain[counter] = &responses[i]
+	}
 
-		var getResponse apiResponse
-		if err := json.NewDecoder(getW.Body).Decode(&getResponse); err != nil {
-			t.Fatalf("Error decoding get device response: %v", err)
+	// Every link has to reference the signature of its predecessor.
+	expectedLastSignature := base64.StdEncoding.EncodeToString([]byte(deviceId))
+	for counter, link := range chain {
+		lastSignature := link.SignedData[strings.LastIndex(link.SignedData, "_")+1:]
+		if lastSignature != expectedLastSignature {
+			t.Fatalf("Chain broken at counter %d: expected last signature %q, got %q",
+				counter, expectedLastSignature, lastSignature)
 		}
-		if getResponse.Data.ID != deviceId {
-			t.Fatalf("Expected device ID %s, got %s", deviceId, getResponse.Data.ID)
+		expectedLastSignature = link.Signature
+	}
+
+	device, exists := s.DeviceRepository.GetDeviceById(deviceId)
+	if !exists {
+		t.Fatalf("Expected device with ID %s to be stored", deviceId)
+	}
+	if device.SignatureCounter != signatureCount {
+		t.Fatalf("Expected signature counter %d, got %d", signatureCount, device.SignatureCounter)
+	}
+}
+func signTransactionWithServer(t *testing.T, s *api.Server, deviceId, data string, expectedStatus int) api.SignTransactionResponse {
+	signRequestBody, err := json.Marshal(api.SignTransactionRequest{Data: data})
+	if err != nil {
+		t.Fatalf("Error marshalling sign transaction request: %v", err)
+	}
+
+	signReq := httptest.NewRequest(http.MethodPost, "/api/v0/transactions/{deviceId}/sign", bytes.NewBuffer(signRequestBody))
+	signReq.SetPathValue("deviceId", deviceId)
+	signReq.Header.Set("Content-Type", "application/json")
+	signW := httptest.NewRecorder()
+
+	router := setupRouter(s)
+	router.ServeHTTP(signW, signReq)
+
+	if signW.Code != expectedStatus {
+		t.Fatalf("Expected status code %d, got %d", expectedStatus, signW.Code)
+	}
+
+	var signResponse struct {
+		Data api.SignTransactionResponse `json:"data"`
+	}
+	if expectedStatus == http.StatusOK {
+		if err := json.NewDecoder(signW.Body).Decode(&signResponse); err != nil {
+			t.Fatalf("Error decoding sign transaction response: %v", err)
+		}
+	}
+	return signResponse.Data
+}
+func TestSignTransactionPersistsTransactions(t *testing.T) {
+	deviceRepo := mocks.NewMockDeviceRepository()
+	transactionRepo := mocks.NewMockTransactionRepository()
+	s := api.NewServer(
+		":8086",
+		deviceRepo,
+		service.NewDeviceService(deviceRepo, crypto.DefaultRegistry),
+		service.NewTransactionService(deviceRepo, transactionRepo),
+	)
+	deviceId := createSignatureDeviceWithServer(t, s, "ECC", "Test ECC Device", http.StatusCreated)
+
+	var responses []api.SignTransactionResponse
+	for i := 0; i < 3; i++ {
+		responses = append(responses, signTransactionWithServer(t, s, deviceId, fmt.Sprintf("receipt %d", i), http.StatusOK))
+	}
+
+	transactions := transactionRepo.SavedTransactions[deviceId]
+	if len(transactions) != len(responses) {
+		t.Fatalf("Expected %d stored transactions, got %d", len(responses), len(transactions))
+	}
+	for i, transaction := range transactions {
+		if transaction.Counter != i {
+			t.Fatalf("Expected counter %d, got %d", i, transaction.Counter)
+		}
+		if transaction.Data != fmt.Sprintf("receipt %d", i) {
+			t.Fatalf("Expected raw data %q, got %q", fmt.Sprintf("receipt %d", i), transaction.Data)
+		}
+		if transaction.SecuredData != responses[i].SignedData {
+			t.Fatalf("Expected secured data %q, got %q", responses[i].SignedData, transaction.SecuredData)
+		}
+		if base64.StdEncoding.EncodeToString(transaction.Signature) != responses[i].Signature {
+			t.Fatalf("Stored signature of counter %d does not match the returned signature", i)
+		}
+		if transaction.CreatedAt.IsZero() {
+			t.Fatalf("Expected timestamp to be set for counter %d", i)
+		}
+	}
+
+	t.Run("Failed persistence leaves counter untouched", func(t *testing.T) {
+		transactionRepo.SaveError = fmt.Errorf("storage unavailable")
+		defer func() { transactionRepo.SaveError = nil }()
+
+		signTransactionWithServer(t, s, deviceId, "lost receipt", http.StatusInternalServerError)
+
+		device, _ := s.DeviceRepository.GetDeviceById(deviceId)
+		if device.SignatureCounter != len(responses) {
+			t.Fatalf("Expected signature counter %d, got %d", len(responses), device.SignatureCounter)
 		}
 	})
+}
+func TestSignTransactionRetriesOnVersionConflict(t *testing.T) {
+	deviceRepo := mocks.NewMockDeviceRepository()
+	transactionRepo := mocks.NewMockTransactionRepository()
+	s := api.NewServer(
+		":8086",
+		deviceRepo,
+		service.NewDeviceService(deviceRepo, crypto.DefaultRegistry),
+		service.NewTransactionService(deviceRepo, transactionRepo),
+	)
+	deviceId := createSignatureDeviceWithServer(t, s, "ED25519", "Test Device", http.StatusCreated)
 
-	t.Run("Invalid Device ID", func(t *testing.T) {
-		getReq := httptest.NewRequest(http.MethodGet, "/api/v0/devices/nonexistent", nil)
-		getW := httptest.NewRecorder()
+	t.Run("Conflicting device updates are retried", func(t *testing.T) {
+		deviceRepo.UpdateConflicts = 2
 
-		router := setupRouter(s)
-		router.ServeHTTP(getW, getReq)
+		signTransactionWithServer(t, s, deviceId, "receipt", http.StatusOK)
+		if deviceRepo.UpdateConflicts != 0 {
+			t.Fatalf("Expected all injected conflicts to be consumed, %d left", deviceRepo.UpdateConflicts)
+		}
+	})
 
-		if getW.Code != http.StatusNotFound {
-			t.Fatalf("Expected status code %d, got %d", http.StatusNotFound, getW.Code)
+	t.Run("Persistent conflicts are reported", func(t *testing.T) {
+		transactionRepo.SaveError = &infrastructure.VersionConflictError{DeviceID: deviceId, Stored: 2, Given: 1}
+		defer func() { transactionRepo.SaveError = nil }()
+
+		signTransactionWithServer(t, s, deviceId, "receipt", http.StatusConflict)
+
+		device, _ := s.DeviceRepository.GetDeviceById(deviceId)
+		if device.SignatureCounter != 1 {
+			t.Fatalf("Expected signature counter 1, got %d", device.SignatureCounter)
 		}
 	})
 }
-func TestListDevices(t *testing.T) {
+
+func signIdempotentWithServer(t *testing.T, s *api.Server, deviceId, data, key string, expectedStatus int) (api.SignTransactionResponse, bool) {
+	signRequestBody, err := json.Marshal(api.SignTransactionRequest{Data: data})
+	if err != nil {
+		t.Fatalf("Error marshalling sign transaction request: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/transactions/{deviceId}/sign", bytes.NewBuffer(signRequestBody))
+	req.SetPathValue("deviceId", deviceId)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(api.IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+
+	setupRouter(s).ServeHTTP(w, req)
+
+	if w.Code != expectedStatus {
+		t.Fatalf("Expected status code %d, got %d: %s", expectedStatus, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data api.SignTransactionResponse `json:"data"`
+	}
+	if expectedStatus == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Error decoding sign transaction response: %v", err)
+		}
+	}
+	return response.Data, w.Header().Get(api.IdempotentReplayedHeader) == "true"
+}
+
+func TestSignTransactionIdempotency(t *testing.T) {
 	s := setupServer()
+	deviceId := createSignatureDeviceWithServer(t, s, "ED25519", "Test Device", http.StatusCreated)
+	otherDeviceId := createSignatureDeviceWithServer(t, s, "ED25519", "Other Device", http.StatusCreated)
 
-	t.Run("No Devices", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, "/api/v0/devices/list", nil)
-		w := httptest.NewRecorder()
+	original, replayed := signIdempotentWithServer(t, s, deviceId, "receipt", "key-1", http.StatusOK)
+	if replayed {
+		t.Fatal("Expected the first request not to be marked as replayed")
+	}
 
-		router := setupRouter(s)
-		router.ServeHTTP(w, req)
+	t.Run("Retries return the original transaction", func(t *testing.T) {
+		retried, replayed := signIdempotentWithServer(t, s, deviceId, "receipt", "key-1", http.StatusOK)
+		if !replayed {
+			t.Error("Expected the retry to be marked as replayed")
+		}
+		if retried != original {
+			t.Fatalf("Expected the original transaction %+v, got %+v", original, retried)
+		}
 
-		if w.Code != http.StatusOK {
-			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		device, _ := s.DeviceRepository.GetDeviceById(deviceId)
+		if device.SignatureCounter != 1 {
+			t.Fatalf("Expected the retry not to advance the signature counter, got %d", device.SignatureCounter)
 		}
-		var response api.ListDevicesResponse
+	})
+
+	t.Run("Reusing a key with different data is rejected", func(t *testing.T) {
+		signIdempotentWithServer(t, s, deviceId, "other receipt", "key-1", http.StatusUnprocessableEntity)
+	})
+
+	t.Run("Keys are scoped per device", func(t *testing.T) {
+		signed, replayed := signIdempotentWithServer(t, s, otherDeviceId, "receipt", "key-1", http.StatusOK)
+		if replayed || signed.Counter != 0 {
+			t.Fatalf("Expected a new transaction on the other device, got %+v (replayed %v)", signed, replayed)
+		}
+	})
+
+	t.Run("New keys and requests without a key sign again", func(t *testing.T) {
+		signed, _ := signIdempotentWithServer(t, s, deviceId, "receipt", "key-2", http.StatusOK)
+		if signed.Counter != 1 {
+			t.Fatalf("Expected counter 1, got %d", signed.Counter)
+		}
+		if unkeyed := signTransactionWithServer(t, s, deviceId, "receipt", http.StatusOK); unkeyed.Counter != 2 {
+			t.Fatalf("Expected counter 2, got %d", unkeyed.Counter)
+		}
+	})
+
+	t.Run("Failed requests release their key", func(t *testing.T) {
+		signIdempotentWithServer(t, s, "unknown-device", "receipt", "key-3", http.StatusNotFound)
+		if _, err := s.DeviceService.ChangeDeviceStatus(deviceId, domain.DeviceStatusSuspended); err != nil {
+			t.Fatalf("Failed to suspend device: %v", err)
+		}
+		signIdempotentWithServer(t, s, deviceId, "receipt", "key-3", http.StatusConflict)
+		if _, err := s.DeviceService.ChangeDeviceStatus(deviceId, domain.DeviceStatusActive); err != nil {
+			t.Fatalf("Failed to reactivate device: %v", err)
+		}
+
+		signed, replayed := signIdempotentWithServer(t, s, deviceId, "receipt", "key-3", http.StatusOK)
+		if replayed || signed.Counter != 3 {
+			t.Fatalf("Expected a new transaction with counter 3, got %+v (replayed %v)", signed, replayed)
+		}
+	})
+
+	t.Run("Overlong keys are rejected", func(t *testing.T) {
+		signIdempotentWithServer(t, s, deviceId, "receipt", strings.Repeat("k", 256), http.StatusBadRequest)
+	})
+}
+
+func signBatchWithServer(t *testing.T, s *api.Server, deviceId string, data []string, expectedStatus int) []api.SignTransactionResponse {
+	requestBody, err := json.Marshal(api.SignTransactionBatchRequest{Data: data})
+	if err != nil {
+		t.Fatalf("Error marshalling sign batch request: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v0/transactions/"+deviceId+"/sign-batch", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	setupRouter(s).ServeHTTP(w, req)
+
+	if w.Code != expectedStatus {
+		t.Fatalf("Expected status code %d, got %d: %s", expectedStatus, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data api.SignTransactionBatchResponse `json:"data"`
+	}
+	if expectedStatus == http.StatusOK {
 		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
-			t.Fatalf("Error decoding list devices response: %v", err)
+			t.Fatalf("Error decoding sign batch response: %v", err)
 		}
-		if len(response.Devices) != 0 {
-			t.Fatalf("Expected no devices, got %d", len(response.Devices))
+	}
+	return response.Data.Transactions
+}
+
+func TestSignTransactionBatch(t *testing.T) {
+	deviceRepo := mocks.NewMockDeviceRepository()
+	transactionRepo := mocks.NewMockTransactionRepository()
+	s := api.NewServer(
+		":8086",
+		deviceRepo,
+		service.NewDeviceService(deviceRepo, crypto.DefaultRegistry),
+		service.NewTransactionService(deviceRepo, transactionRepo),
+	)
+	deviceId := createSignatureDeviceWithServer(t, s, "ECC", "Test Device", http.StatusCreated)
+	first := signTransactionWithServer(t, s, deviceId, "single", http.StatusOK)
+
+	t.Run("Items are signed as consecutive links of the chain", func(t *testing.T) {
+		data := []string{"receipt_1", "receipt 2", "receipt 3"}
+		signed := signBatchWithServer(t, s, deviceId, data, http.StatusOK)
+		if len(signed) != len(data) {
+			t.Fatalf("Expected %d transactions, got %d", len(data), len(signed))
+		}
+
+		lastSignature := first.Signature
+		for i, transaction := range signed {
+			expectedPrefix := fmt.Sprintf("%d_%s_", i+1, data[i])
+			if transaction.Counter != i+1 || transaction.SignedData != expectedPrefix+lastSignature {
+				t.Fatalf("Expected item %d to be signed as %q, got %+v", i, expectedPrefix+lastSignature, transaction)
+			}
+
+			signature, err := base64.StdEncoding.DecodeString(transaction.Signature)
+			if err != nil {
+				t.Fatalf("Error decoding signature: %v", err)
+			}
+			valid, err := s.TransactionService.VerifySignature(deviceId, transaction.SignedData, signature)
+			if err != nil || !valid {
+				t.Fatalf("Expected the signature of item %d to be valid, got %v (%v)", i, valid, err)
+			}
+			lastSignature = transaction.Signature
+		}
+
+		next := signTransactionWithServer(t, s, deviceId, "after", http.StatusOK)
+		if next.SignedData != "4_after_"+lastSignature {
+			t.Fatalf("Expected the chain to continue after the batch, got %s", next.SignedData)
+		}
+		if stored := transactionRepo.SavedTransactions[deviceId]; len(stored) != 5 {
+			t.Fatalf("Expected 5 stored transactions, got %d", len(stored))
 		}
 	})
 
-	t.Run("Multiple Devices", func(t *testing.T) {
-		s := setupServer()
-		router := setupRouter(s)
-		_ = createSignatureDeviceWithServer(t, s, "RSA", "Test RSA Device", http.StatusCreated)
-		_ = createSignatureDeviceWithServer(t, s, "ECC", "Test ECC Device", http.StatusCreated)
+	t.Run("Failed batches leave the chain untouched", func(t *testing.T) {
+		transactionRepo.SaveError = fmt.Errorf("disk full")
+		signBatchWithServer(t, s, deviceId, []string{"a", "b"}, http.StatusInternalServerError)
+		transactionRepo.SaveError = nil
 
-		req := httptest.NewRequest(http.MethodGet, "/api/v0/devices/list", nil)
+		device, _ := s.DeviceRepository.GetDeviceById(deviceId)
+		if device.SignatureCounter != 5 {
+			t.Fatalf("Expected signature counter 5, got %d", device.SignatureCounter)
+		}
+		if stored := transactionRepo.SavedTransactions[deviceId]; len(stored) != 5 {
+			t.Fatalf("Expected 5 stored transactions, got %d", len(stored))
+		}
+	})
+
+	t.Run("Empty and oversized batches are rejected", func(t *testing.T) {
+		signBatchWithServer(t, s, deviceId, nil, http.StatusBadRequest)
+		signBatchWithServer(t, s, deviceId, make([]string, service.MaxBatchSize+1), http.StatusBadRequest)
+	})
+
+	t.Run("Unknown and inactive devices are rejected", func(t *testing.T) {
+		signBatchWithServer(t, s, "unknown-device", []string{"a"}, http.StatusNotFound)
+
+		if _, err := s.DeviceService.ChangeDeviceStatus(deviceId, domain.DeviceStatusSuspended); err != nil {
+			t.Fatalf("Failed to suspend device: %v", err)
+		}
+		signBatchWithServer(t, s, deviceId, []string{"a"}, http.StatusConflict)
+	})
+}
+
+func TestSecuredDataFormats(t *testing.T) {
+	const data = "receipt_1:2"
+
+	testCases := []struct {
+		format         string
+		expectedFormat domain.SecuredDataFormat
+		securedData    func(lastSignature string) string
+	}{
+		{"", domain.SecuredDataFormatLegacy, func(lastSignature string) string {
+			return "1_receipt_1:2_" + lastSignature
+		}},
+		{"LENGTH_PREFIXED", domain.SecuredDataFormatLengthPrefixed, func(lastSignature string) string {
+			return "1:11:receipt_1:2:" + lastSignature
+		}},
+		{"JSON", domain.SecuredDataFormatJSON, func(lastSignature string) string {
+			return `{"counter":1,"data":"receipt_1:2","last_signature":"` + lastSignature + `"}`
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.expectedFormat), func(t *testing.T) {
+			s := setupServer()
+			router := setupRouter(s)
+
+			body, err := json.Marshal(api.CreateSignatureDeviceRequest{Algorithm: "ED25519", SecuredDataFormat: tc.format})
+			if err != nil {
+				t.Fatalf("Error marshalling create signature device request: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/v0/devices", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+			}
+			var created apiResponse
+			if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+				t.Fatalf("Error decoding response: %v", err)
+			}
+			deviceId := created.Data.ID
+
+			device, _ := s.DeviceService.GetDevice(deviceId)
+			if device.SecuredDataFormat != tc.expectedFormat {
+				t.Fatalf("Expected format %s, got %s", tc.expectedFormat, device.SecuredDataFormat)
+			}
+
+			first := signTransactionWithServer(t, s, deviceId, "first", http.StatusOK)
+			second := signTransactionWithServer(t, s, deviceId, data, http.StatusOK)
+			if expected := tc.securedData(first.Signature); second.SignedData != expected {
+				t.Fatalf("Expected secured data %s, got %s", expected, second.SignedData)
+			}
+
+			parsed, err := device.SecuredDataFormatter().Parse(second.SignedData)
+			if err != nil {
+				t.Fatalf("Failed to parse secured data: %v", err)
+			}
+			if parsed.Counter != 1 || parsed.Data != data ||
+				base64.StdEncoding.EncodeToString(parsed.LastSignature) != first.Signature {
+				t.Fatalf("Secured data was not decomposed correctly, got %+v", parsed)
+			}
+
+			signBatchWithServer(t, s, deviceId, []string{"batch_1", "batch:2"}, http.StatusOK)
+			key, err := s.DeviceService.GenerateKey(deviceId)
+			if err != nil {
+				t.Fatalf("Failed to generate key: %v", err)
+			}
+			if _, err := s.TransactionService.RotateKey(deviceId, key); err != nil {
+				t.Fatalf("Failed to rotate key: %v", err)
+			}
+			signTransactionWithServer(t, s, deviceId, "after rotation", http.StatusOK)
+
+			report, err := s.TransactionService.AuditDevice(deviceId)
+			if err != nil {
+				t.Fatalf("Failed to audit device: %v", err)
+			}
+			if !report.Valid || report.TransactionsChecked != 6 {
+				t.Fatalf("Expected an intact chain of 6 transactions, got %+v", report)
+			}
+
+			signature, _ := base64.StdEncoding.DecodeString(second.Signature)
+			valid, err := s.TransactionService.VerifySignature(deviceId, second.SignedData, signature)
+			if err != nil || !valid {
+				t.Fatalf("Expected the signature made before the rotation to verify, got %v (%v)", valid, err)
+			}
+		})
+	}
+
+	t.Run("Unknown Format", func(t *testing.T) {
+		body, err := json.Marshal(api.CreateSignatureDeviceRequest{Algorithm: "ED25519", SecuredDataFormat: "XML"})
+		if err != nil {
+			t.Fatalf("Error marshalling create signature device request: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/v0/devices", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		setupRouter(setupServer()).ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestSignTransactionPayload(t *testing.T) {
+	s := setupServer()
+	router := setupRouter(s)
+	deviceId := createSignatureDeviceWithServer(t, s, "ED25519", "Test Device", http.StatusCreated)
+
+	signPayload := func(t *testing.T, body, idempotencyKey string, expectedStatus int) api.SignTransactionResponse {
+		req := httptest.NewRequest(http.MethodPost, "/api/v0/transactions/{deviceId}/sign", strings.NewReader(body))
+		req.SetPathValue("deviceId", deviceId)
+		req.Header.Set("Content-Type", "application/json")
+		if idempotencyKey != "" {
+			req.Header.Set(api.IdempotencyKeyHeader, idempotencyKey)
+		}
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 
-		if w.Code != http.StatusOK {
-			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		if w.Code != expectedStatus {
+			t.Fatalf("Expected status code %d, got %d: %s", expectedStatus, w.Code, w.Body.String())
 		}
 
-		var wrappedResponse WrappedListDevicesResponse
-		if err := json.NewDecoder(w.Body).Decode(&wrappedResponse); err != nil {
-			t.Fatalf("Error decoding list devices response: %v", err)
+		var response struct {
+			Data api.SignTransactionResponse `json:"data"`
 		}
+		if expectedStatus == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding sign transaction response: %v", err)
+			}
+		}
+		return response.Data
+	}
 
-		if len(wrappedResponse.Data.Devices) != 2 {
-			t.Fatalf("Expected 2 devices, got %d", len(wrappedResponse.Data.Devices))
+	const canonical = `{"items":[{"name":"Café","price":4.5}],"till":7,"total":450}`
+
+	t.Run("Payloads are signed in canonical form", func(t *testing.T) {
+		signed := signPayload(t, `{"payload": {"total": 4.5e2, "till": 7.0,
+			"items": [ {"price": 4.50, "name": "Caf\u00e9"} ]}}`, "", http.StatusOK)
+		if signed.CanonicalPayload != canonical {
+			t.Fatalf("Expected canonical payload %s, got %s", canonical, signed.CanonicalPayload)
 		}
-	})
+		if !strings.HasPrefix(signed.SignedData, "0_"+canonical+"_") {
+			t.Fatalf("Expected the canonical payload to be signed, got %s", signed.SignedData)
+		}
+
+		transaction, err := s.TransactionService.GetTransaction(deviceId, 0)
+		if err != nil {
+			t.Fatalf("Failed to get transaction: %v", err)
+		}
+		if transaction.Data != canonical {
+			t.Fatalf("Expected the canonical payload to be stored as data, got %s", transaction.Data)
+		}
+	})
+
+	t.Run("Semantically equal payloads are retried idempotently", func(t *testing.T) {
+		original := signPayload(t, `{"payload": {"till": 7, "total": 450, "items": [{"name": "Café", "price": 4.5}]}}`,
+			"payload-key", http.StatusOK)
+		retried := signPayload(t, `{"payload":{"items":[{"price":4.5,"name":"Café"}],"total":450,"till":7}}`,
+			"payload-key", http.StatusOK)
+		if retried != original || original.Counter != 1 {
+			t.Fatalf("Expected the retry to return transaction %+v, got %+v", original, retried)
+		}
+	})
+
+	t.Run("Data strings are not canonicalized", func(t *testing.T) {
+		signed := signPayload(t, `{"data": "{ \"a\": 1 }"}`, "", http.StatusOK)
+		if signed.CanonicalPayload != "" || !strings.HasPrefix(signed.SignedData, `2_{ "a": 1 }_`) {
+			t.Fatalf("Expected the data string to be signed as given, got %+v", signed)
+		}
+	})
+
+	t.Run("Invalid payloads are rejected", func(t *testing.T) {
+		for _, body := range []string{
+			`{"payload": [1, 2]}`,
+			`{"payload": "receipt"}`,
+			`{"payload": null}`,
+			`{"payload": {"a": 1, "a": 2}}`,
+			`{"payload": {"a": 1e400}}`,
+			`{"data": "receipt", "payload": {"a": 1}}`,
+		} {
+			signPayload(t, body, "", http.StatusBadRequest)
+		}
+
+		device, _ := s.DeviceRepository.GetDeviceById(deviceId)
+		if device.SignatureCounter != 3 {
+			t.Fatalf("Expected signature counter 3, got %d", device.SignatureCounter)
+		}
+	})
+}
+
+func TestGetDeviceById(t *testing.T) {
+	s := setupServer()
+
+	deviceId := createSignatureDeviceWithServer(t, s, "ECC", "Test ECC Device", http.StatusCreated)
+
+	t.Run("Valid Device ID", func(t *testing.T) {
+		getReq := httptest.NewRequest(http.MethodGet, "/api/v0/devices/{deviceId}", nil)
+		getReq.SetPathValue("deviceId", deviceId)
+		getW := httptest.NewRecorder()
+
+		router := setupRouter(s)
+		router.ServeHTTP(getW, getReq)
+
+		if getW.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, getW.Code)
+		}
+
+		var getResponse apiResponse
+		if err := json.NewDecoder(getW.Body).Decode(&getResponse); err != nil {
+			t.Fatalf("Error decoding get device response: %v", err)
+		}
+		if getResponse.Data.ID != deviceId {
+			t.Fatalf("Expected device ID %s, got %s", deviceId, getResponse.Data.ID)
+		}
+	})
+
+	t.Run("Invalid Device ID", func(t *testing.T) {
+		getReq := httptest.NewRequest(http.MethodGet, "/api/v0/devices/nonexistent", nil)
+		getW := httptest.NewRecorder()
+
+		router := setupRouter(s)
+		router.ServeHTTP(getW, getReq)
+
+		if getW.Code != http.StatusNotFound {
+			t.Fatalf("Expected status code %d, got %d", http.StatusNotFound, getW.Code)
+		}
+	})
+}
+func updateDeviceWithServer(t *testing.T, s *api.Server, method, path, deviceId string, body []byte, expectedStatus int) api.DeviceResponse {
+	req := httptest.NewRequest(method, path, bytes.NewBuffer(body))
+	req.SetPathValue("deviceId", deviceId)
+	w := httptest.NewRecorder()
+	setupRouter(s).ServeHTTP(w, req)
+
+	if w.Code != expectedStatus {
+		t.Fatalf("Expected status code %d, got %d: %s", expectedStatus, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data api.DeviceResponse `json:"data"`
+	}
+	if expectedStatus == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Error decoding device response: %v", err)
+		}
+	}
+	return response.Data
+}
+
+func TestDeviceLifecycle(t *testing.T) {
+	s := setupServer()
+	deviceId := createSignatureDeviceWithServer(t, s, "ED25519", "Till 1", http.StatusCreated)
+	devicePath := "/api/v0/devices/" + deviceId
+
+	t.Run("Suspended devices refuse to sign", func(t *testing.T) {
+		device := updateDeviceWithServer(t, s, http.MethodPost, devicePath+"/deactivate", deviceId, nil, http.StatusOK)
+		if device.Status != string(domain.DeviceStatusSuspended) {
+			t.Fatalf("Expected status %s, got %s", domain.DeviceStatusSuspended, device.Status)
+		}
+
+		signTransactionWithServer(t, s, deviceId, "receipt", http.StatusConflict)
+	})
+
+	t.Run("Reactivated devices sign again", func(t *testing.T) {
+		updateDeviceWithServer(t, s, http.MethodPost, devicePath+"/reactivate", deviceId, nil, http.StatusOK)
+
+		signTransactionWithServer(t, s, deviceId, "receipt", http.StatusOK)
+	})
+
+	t.Run("Status can be changed via PATCH", func(t *testing.T) {
+		body := []byte(`{"status": "SUSPENDED"}`)
+		device := updateDeviceWithServer(t, s, http.MethodPatch, devicePath, deviceId, body, http.StatusOK)
+		if device.Status != string(domain.DeviceStatusSuspended) {
+			t.Fatalf("Expected status %s, got %s", domain.DeviceStatusSuspended, device.Status)
+		}
+
+		updateDeviceWithServer(t, s, http.MethodPatch, devicePath, deviceId, []byte(`{"status": "LOST"}`), http.StatusBadRequest)
+	})
+
+	t.Run("Decommissioning is irreversible", func(t *testing.T) {
+		device := updateDeviceWithServer(t, s, http.MethodPost, devicePath+"/decommission", deviceId, nil, http.StatusOK)
+		if device.Status != string(domain.DeviceStatusDecommissioned) || device.DecommissionedAt == nil {
+			t.Fatalf("Expected a recorded decommissioning, got %+v", device)
+		}
+
+		updateDeviceWithServer(t, s, http.MethodPost, devicePath+"/reactivate", deviceId, nil, http.StatusConflict)
+		updateDeviceWithServer(t, s, http.MethodPatch, devicePath, deviceId, []byte(`{"status": "ACTIVE"}`), http.StatusConflict)
+		signTransactionWithServer(t, s, deviceId, "receipt", http.StatusConflict)
+
+		stored, _ := s.DeviceRepository.GetDeviceById(deviceId)
+		if stored.SignatureCounter != 1 {
+			t.Fatalf("Expected signature counter 1, got %d", stored.SignatureCounter)
+		}
+	})
+
+	t.Run("Unknown device", func(t *testing.T) {
+		updateDeviceWithServer(t, s, http.MethodPost, "/api/v0/devices/nonexistent/deactivate", "nonexistent", nil, http.StatusNotFound)
+	})
+}
+
+func TestUpdateDeviceDetails(t *testing.T) {
+	s := setupServer()
+	deviceId := createSignatureDeviceWithServer(t, s, "ECC", "Till 1", http.StatusCreated)
+	devicePath := "/api/v0/devices/" + deviceId
+	signTransactionWithServer(t, s, deviceId, "receipt", http.StatusOK)
+
+	t.Run("Label and metadata are updated", func(t *testing.T) {
+		body := []byte(`{"label": "Till 2", "metadata": {"store_id": "S-17", "till_number": "2"}}`)
+		device := updateDeviceWithServer(t, s, http.MethodPatch, devicePath, deviceId, body, http.StatusOK)
+
+		expected := map[string]string{"store_id": "S-17", "till_number": "2"}
+		if device.Label != "Till 2" || !reflect.DeepEqual(device.Metadata, expected) {
+			t.Fatalf("Expected label %q and metadata %v, got %q and %v", "Till 2", expected, device.Label, device.Metadata)
+		}
+	})
+
+	t.Run("Metadata is merged and null removes entries", func(t *testing.T) {
+		body := []byte(`{"metadata": {"till_number": null, "region": "north"}}`)
+		device := updateDeviceWithServer(t, s, http.MethodPatch, devicePath, deviceId, body, http.StatusOK)
+
+		expected := map[string]string{"store_id": "S-17", "region": "north"}
+		if device.Label != "Till 2" || !reflect.DeepEqual(device.Metadata, expected) {
+			t.Fatalf("Expected label %q and metadata %v, got %q and %v", "Till 2", expected, device.Label, device.Metadata)
+		}
+	})
+
+	t.Run("Immutable fields are rejected", func(t *testing.T) {
+		for _, body := range []string{
+			`{"algorithm": "RSA"}`,
+			`{"signature_counter": 0}`,
+			`{"label": "Till 3", "public_key": "AAAA"}`,
+		} {
+			updateDeviceWithServer(t, s, http.MethodPatch, devicePath, deviceId, []byte(body), http.StatusBadRequest)
+		}
+
+		device, _ := s.DeviceRepository.GetDeviceById(deviceId)
+		if device.Label != "Till 2" || device.Algorithm != "ECC" || device.SignatureCounter != 1 {
+			t.Fatalf("Expected device to be unchanged, got label %q, algorithm %s and counter %d",
+				device.Label, device.Algorithm, device.SignatureCounter)
+		}
+	})
+
+	t.Run("Invalid updates", func(t *testing.T) {
+		for _, body := range []string{
+			`{}`,
+			`{"metadata": {"": "empty key"}}`,
+			`{"label": "` + strings.Repeat("x", 257) + `"}`,
+		} {
+			updateDeviceWithServer(t, s, http.MethodPatch, devicePath, deviceId, []byte(body), http.StatusBadRequest)
+		}
+	})
+
+	t.Run("Failed updates leave the device unchanged", func(t *testing.T) {
+		repository := s.DeviceRepository.(*mocks.MockDeviceRepository)
+		repository.UpdateError = errors.New("disk full")
+		defer func() { repository.UpdateError = nil }()
+
+		body := []byte(`{"label": "Till 3", "metadata": {"region": "south"}, "status": "SUSPENDED"}`)
+		updateDeviceWithServer(t, s, http.MethodPatch, devicePath, deviceId, body, http.StatusInternalServerError)
+
+		device, _ := s.DeviceRepository.GetDeviceById(deviceId)
+		status, _ := device.LifecycleState()
+		label, metadata := device.Details()
+		if label != "Till 2" || metadata["region"] != "north" || status != domain.DeviceStatusActive {
+			t.Fatalf("Expected the rejected update not to be applied, got label %q, metadata %v and status %s",
+				label, metadata, status)
+		}
+	})
+
+	t.Run("Signing continues the chain", func(t *testing.T) {
+		signTransactionWithServer(t, s, deviceId, "receipt", http.StatusOK)
+
+		device, _ := s.DeviceRepository.GetDeviceById(deviceId)
+		if device.SignatureCounter != 2 {
+			t.Fatalf("Expected signature counter 2, got %d", device.SignatureCounter)
+		}
+	})
+}
+
+func TestListDevices(t *testing.T) {
+	s := setupServer()
+
+	t.Run("No Devices", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v0/devices/list", nil)
+		w := httptest.NewRecorder()
+
+		router := setupRouter(s)
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+		var response api.ListDevicesResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Error decoding list devices response: %v", err)
+		}
+		if len(response.Devices) != 0 {
+			t.Fatalf("Expected no devices, got %d", len(response.Devices))
+		}
+	})
+
+	t.Run("Multiple Devices", func(t *testing.T) {
+		s := setupServer()
+		router := setupRouter(s)
+		_ = createSignatureDeviceWithServer(t, s, "RSA", "Test RSA Device", http.StatusCreated)
+		_ = createSignatureDeviceWithServer(t, s, "ECC", "Test ECC Device", http.StatusCreated)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v0/devices/list", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var wrappedResponse WrappedListDevicesResponse
+		if err := json.NewDecoder(w.Body).Decode(&wrappedResponse); err != nil {
+			t.Fatalf("Error decoding list devices response: %v", err)
+		}
+
+		if len(wrappedResponse.Data.Devices) != 2 {
+			t.Fatalf("Expected 2 devices, got %d", len(wrappedResponse.Data.Devices))
+		}
+	})
+}
+func TestListTransactions(t *testing.T) {
+	s := setupServer()
+	router := setupRouter(s)
+	deviceId := createSignatureDeviceWithServer(t, s, "ECC", "Test ECC Device", http.StatusCreated)
+
+	var signed []api.SignTransactionResponse
+	for i := 0; i < 5; i++ {
+		signed = append(signed, signTransactionWithServer(t, s, deviceId, fmt.Sprintf("receipt %d", i), http.StatusOK))
+	}
+
+	testCases := []struct {
+		name             string
+		query            string
+		expectedStatus   int
+		expectedCounters []int
+	}{
+		{
+			name:             "Default Page",
+			query:            "",
+			expectedStatus:   http.StatusOK,
+			expectedCounters: []int{0, 1, 2, 3, 4},
+		},
+		{
+			name:             "Second Page",
+			query:            "?offset=2&limit=2",
+			expectedStatus:   http.StatusOK,
+			expectedCounters: []int{2, 3},
+		},
+		{
+			name:             "Offset Beyond End",
+			query:            "?offset=10",
+			expectedStatus:   http.StatusOK,
+			expectedCounters: []int{},
+		},
+		{
+			name:           "Invalid Limit",
+			query:          "?limit=0",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid Offset",
+			query:          "?offset=abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+deviceId+"/transactions"+tc.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data api.ListTransactionsResponse `json:"data"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding list transactions response: %v", err)
+			}
+			if response.Data.Total != len(signed) {
+				t.Fatalf("Expected total %d, got %d", len(signed), response.Data.Total)
+			}
+			if len(response.Data.Transactions) != len(tc.expectedCounters) {
+				t.Fatalf("Expected %d transactions, got %d", len(tc.expectedCounters), len(response.Data.Transactions))
+			}
+			for i, transaction := range response.Data.Transactions {
+				counter := tc.expectedCounters[i]
+				if transaction.Counter != counter {
+					t.Fatalf("Expected counter %d, got %d", counter, transaction.Counter)
+				}
+				if transaction.SignedData != signed[counter].SignedData || transaction.Signature != signed[counter].Signature {
+					t.Fatalf("Transaction %d does not match the signed response", counter)
+				}
+			}
+		})
+	}
+
+	t.Run("Unknown Device", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v0/devices/nonexistent/transactions", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+func TestGetTransaction(t *testing.T) {
+	s := setupServer()
+	router := setupRouter(s)
+	deviceId := createSignatureDeviceWithServer(t, s, "RSA", "Test RSA Device", http.StatusCreated)
+	signed := signTransactionWithServer(t, s, deviceId, "receipt", http.StatusOK)
+
+	testCases := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{
+			name:           "Existing Transaction",
+			path:           "/api/v0/devices/" + deviceId + "/transactions/0",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Unknown Counter",
+			path:           "/api/v0/devices/" + deviceId + "/transactions/1",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Invalid Counter",
+			path:           "/api/v0/devices/" + deviceId + "/transactions/first",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown Device",
+			path:           "/api/v0/devices/nonexistent/transactions/0",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data api.TransactionResponse `json:"data"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding transaction response: %v", err)
+			}
+			if response.Data.DeviceID != deviceId || response.Data.Data != "receipt" {
+				t.Fatalf("Unexpected transaction %+v", response.Data)
+			}
+			if response.Data.SignedData != signed.SignedData || response.Data.Signature != signed.Signature {
+				t.Fatalf("Transaction does not match the signed response")
+			}
+		})
+	}
+}
+func TestVerifySignature(t *testing.T) {
+	for _, algorithm := range []string{"RSA", "RSA-PSS", "ECC", "ED25519"} {
+		t.Run(algorithm, func(t *testing.T) {
+			s := setupServer()
+			router := setupRouter(s)
+			deviceId := createSignatureDeviceWithServer(t, s, algorithm, "Test Device", http.StatusCreated)
+			otherDeviceId := createSignatureDeviceWithServer(t, s, algorithm, "Other Device", http.StatusCreated)
+			signed := signTransactionWithServer(t, s, deviceId, "receipt", http.StatusOK)
+
+			testCases := []struct {
+				name           string
+				deviceId       string
+				signedData     string
+				signature      string
+				expectedStatus int
+				expectedValid  bool
+			}{
+				{"Valid Signature", deviceId, signed.SignedData, signed.Signature, http.StatusOK, true},
+				{"Tampered Data", deviceId, signed.SignedData + "x", signed.Signature, http.StatusOK, false},
+				{"Other Device", otherDeviceId, signed.SignedData, signed.Signature, http.StatusOK, false},
+				{"Invalid Base64", deviceId, signed.SignedData, "%%%", http.StatusBadRequest, false},
+				{"Unknown Device", "nonexistent", signed.SignedData, signed.Signature, http.StatusNotFound, false},
+			}
+
+			for _, tc := range testCases {
+				t.Run(tc.name, func(t *testing.T) {
+					body, err := json.Marshal(api.VerifySignatureRequest{
+						SignedData: tc.signedData,
+						Signature:  tc.signature,
+					})
+					if err != nil {
+						t.Fatalf("Error marshalling verify request: %v", err)
+					}
+
+					req := httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+tc.deviceId+"/verify", bytes.NewBuffer(body))
+					req.Header.Set("Content-Type", "application/json")
+					w := httptest.NewRecorder()
+					router.ServeHTTP(w, req)
+
+					if w.Code != tc.expectedStatus {
+						t.Fatalf("Expected status code %d, got %d", tc.expectedStatus, w.Code)
+					}
+					if tc.expectedStatus != http.StatusOK {
+						return
+					}
+
+					var response struct {
+						Data api.VerifySignatureResponse `json:"data"`
+					}
+					if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+						t.Fatalf("Error decoding verify response: %v", err)
+					}
+					if response.Data.Valid != tc.expectedValid {
+						t.Fatalf("Expected valid=%t, got %t", tc.expectedValid, response.Data.Valid)
+					}
+				})
+			}
+		})
+	}
+}
+func TestRotateKey(t *testing.T) {
+	rotateKeyWithServer := func(t *testing.T, s *api.Server, deviceId string, expectedStatus int) api.RotateKeyResponse {
+		req := httptest.NewRequest(http.MethodPost, "/api/v0/devices/"+deviceId+"/rotate-key", nil)
+		w := httptest.NewRecorder()
+		setupRouter(s).ServeHTTP(w, req)
+
+		if w.Code != expectedStatus {
+			t.Fatalf("Expected status code %d, got %d: %s", expectedStatus, w.Code, w.Body.String())
+		}
+
+		var response struct {
+			Data api.RotateKeyResponse `json:"data"`
+		}
+		if expectedStatus == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding rotate key response: %v", err)
+			}
+		}
+		return response.Data
+	}
+
+	for _, algorithm := range []string{"RSA", "RSA-PSS", "ECC", "ED25519"} {
+		t.Run(algorithm, func(t *testing.T) {
+			s := setupServer()
+			deviceId := createSignatureDeviceWithServer(t, s, algorithm, "Test Device", http.StatusCreated)
+
+			signed := []api.SignTransactionResponse{signTransactionWithServer(t, s, deviceId, "before", http.StatusOK)}
+
+			rotation := rotateKeyWithServer(t, s, deviceId, http.StatusOK)
+			if rotation.KeyGeneration != 2 || rotation.RotationRecord.Counter != 1 {
+				t.Fatalf("Expected generation 2 announced at counter 1, got %+v", rotation)
+			}
+			if len(rotation.Keys) != 2 || rotation.Keys[0].ValidUntil == nil || *rotation.Keys[0].ValidUntil != 2 ||
+				rotation.Keys[1].ValidFrom != 2 || rotation.Keys[1].ValidUntil != nil {
+				t.Fatalf("Expected key 1 to cover counters 0-1 and key 2 to start at 2, got %+v", rotation.Keys)
+			}
+			signed = append(signed, api.SignTransactionResponse{
+				SignedData: rotation.RotationRecord.SignedData,
+				Signature:  rotation.RotationRecord.Signature,
+			})
+
+			signed = append(signed, signTransactionWithServer(t, s, deviceId, "after", http.StatusOK))
+			if !strings.HasPrefix(signed[2].SignedData, "2_after_") {
+				t.Fatalf("Expected the chain to continue at counter 2, got %s", signed[2].SignedData)
+			}
+
+			for counter, transaction := range signed {
+				signature, _ := base64.StdEncoding.DecodeString(transaction.Signature)
+				valid, err := s.TransactionService.VerifySignature(deviceId, transaction.SignedData, signature)
+				if err != nil || !valid {
+					t.Fatalf("Expected signature of counter %d to verify, got %t, %v", counter, valid, err)
+				}
+			}
+
+			report, err := s.TransactionService.AuditDevice(deviceId)
+			if err != nil {
+				t.Fatalf("Failed to audit device: %v", err)
+			}
+			if !report.Valid || report.TransactionsChecked != 3 {
+				t.Fatalf("Expected an intact chain of 3 transactions, got %+v", report)
+			}
+		})
+	}
+
+	t.Run("Inactive devices cannot rotate", func(t *testing.T) {
+		// Counts generated keys, which would be wasted on a device that cannot rotate.
+		algorithm, _ := crypto.DefaultRegistry.Get("ED25519")
+		counting := *algorithm
+		var generated int
+		counting.Generate = func(parameters crypto.Parameters) (*crypto.KeyPair, error) {
+			generated++
+			return algorithm.Generate(parameters)
+		}
+		registry := crypto.NewRegistry()
+		registry.MustRegister(counting)
+
+		deviceRepo := mocks.NewMockDeviceRepository()
+		s := api.NewServer(
+			":8086",
+			deviceRepo,
+			service.NewDeviceService(deviceRepo, registry),
+			service.NewTransactionService(deviceRepo, mocks.NewMockTransactionRepository()),
+		)
+		deviceId := createSignatureDeviceWithServer(t, s, "ED25519", "Test Device", http.StatusCreated)
+		updateDeviceWithServer(t, s, http.MethodPost, "/api/v0/devices/"+deviceId+"/deactivate", deviceId, nil, http.StatusOK)
+
+		generated = 0
+		rotateKeyWithServer(t, s, deviceId, http.StatusConflict)
+		if generated != 0 {
+			t.Fatalf("Expected no key to be generated for an inactive device, got %d", generated)
+		}
+		rotateKeyWithServer(t, s, "nonexistent", http.StatusNotFound)
+	})
+}
+
+func TestAuditDevice(t *testing.T) {
+	auditWithServer := func(t *testing.T, s *api.Server, deviceId string, expectedStatus int) api.AuditResponse {
+		req := httptest.NewRequest(http.MethodGet, "/api/v0/devices/"+deviceId+"/audit", nil)
+		w := httptest.NewRecorder()
+		setupRouter(s).ServeHTTP(w, req)
+
+		if w.Code != expectedStatus {
+			t.Fatalf("Expected status code %d, got %d", expectedStatus, w.Code)
+		}
+
+		var response struct {
+			Data api.AuditResponse `json:"data"`
+		}
+		if expectedStatus == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding audit response: %v", err)
+			}
+		}
+		return response.Data
+	}
+
+	testCases := []struct {
+		name             string
+		tamper           func(transaction domain.Transaction) domain.Transaction
+		expectedBrokenAt int
+	}{
+		{
+			name: "Intact Chain",
+		},
+		{
+			name: "Tampered Data",
+			tamper: func(transaction domain.Transaction) domain.Transaction {
+				transaction.Data = "forged receipt"
+				return transaction
+			},
+			expectedBrokenAt: 2,
+		},
+		{
+			name: "Tampered Secured Data",
+			tamper: func(transaction domain.Transaction) domain.Transaction {
+				transaction.SecuredData = strings.Replace(transaction.SecuredData, "receipt", "forged", 1)
+				return transaction
+			},
+			expectedBrokenAt: 2,
+		},
+		{
+			name: "Tampered Signature",
+			tamper: func(transaction domain.Transaction) domain.Transaction {
+				transaction.Signature = append([]byte{}, transaction.Signature...)
+				transaction.Signature[len(transaction.Signature)-1] ^= 0xff
+				return transaction
+			},
+			expectedBrokenAt: 2,
+		},
+	}
+
+	for _, algorithm := range []string{"RSA", "ECC", "ED25519"} {
+		for _, tc := range testCases {
+			t.Run(algorithm+" "+tc.name, func(t *testing.T) {
+				deviceRepo := mocks.NewMockDeviceRepository()
+				transactionRepo := mocks.NewMockTransactionRepository()
+				s := api.NewServer(
+					":8086",
+					deviceRepo,
+					service.NewDeviceService(deviceRepo, crypto.DefaultRegistry),
+					service.NewTransactionService(deviceRepo, transactionRepo),
+				)
+				deviceId := createSignatureDeviceWithServer(t, s, algorithm, "Test Device", http.StatusCreated)
+
+				emptyReport := auditWithServer(t, s, deviceId, http.StatusOK)
+				if !emptyReport.Valid || emptyReport.TransactionsChecked != 0 {
+					t.Fatalf("Expected an empty chain to be valid, got %+v", emptyReport)
+				}
+
+				for i := 0; i < 5; i++ {
+					signTransactionWithServer(t, s, deviceId, fmt.Sprintf("receipt %d", i), http.StatusOK)
+				}
+
+				if tc.tamper != nil {
+					tampered := tc.tamper(*transactionRepo.SavedTransactions[deviceId][tc.expectedBrokenAt])
+					transactionRepo.SavedTransactions[deviceId][tc.expectedBrokenAt] = &tampered
+				}
+
+				report := auditWithServer(t, s, deviceId, http.StatusOK)
+				if tc.tamper == nil {
+					if !report.Valid || report.BrokenAt != nil || report.TransactionsChecked != 5 {
+						t.Fatalf("Expected an intact chain of 5 transactions, got %+v", report)
+					}
+					return
+				}
+
+				if report.Valid || report.BrokenAt == nil || *report.BrokenAt != tc.expectedBrokenAt {
+					t.Fatalf("Expected chain to be broken at %d, got %+v", tc.expectedBrokenAt, report)
+				}
+				if report.Reason == "" {
+					t.Fatalf("Expected a reason for the broken chain")
+				}
+			})
+		}
+	}
+
+	t.Run("Missing Last Transaction", func(t *testing.T) {
+		deviceRepo := mocks.NewMockDeviceRepository()
+		transactionRepo := mocks.NewMockTransactionRepository()
+		s := api.NewServer(
+			":8086",
+			deviceRepo,
+			service.NewDeviceService(deviceRepo, crypto.DefaultRegistry),
+			service.NewTransactionService(deviceRepo, transactionRepo),
+		)
+		deviceId := createSignatureDeviceWithServer(t, s, "ED25519", "Test Device", http.StatusCreated)
+		for i := 0; i < 5; i++ {
+			signTransactionWithServer(t, s, deviceId, fmt.Sprintf("receipt %d", i), http.StatusOK)
+		}
+
+		transactions := transactionRepo.SavedTransactions[deviceId]
+		transactionRepo.SavedTransactions[deviceId] = transactions[:len(transactions)-1]
+
+		report := auditWithServer(t, s, deviceId, http.StatusOK)
+		if report.Valid || report.BrokenAt == nil || *report.BrokenAt != 4 {
+			t.Fatalf("Expected chain to be broken at the missing counter 4, got %+v", report)
+		}
+		if report.TransactionsChecked != 4 || report.Reason == "" {
+			t.Fatalf("Expected 4 checked transactions and a reason, got %+v", report)
+		}
+	})
+
+	t.Run("Unknown Device", func(t *testing.T) {
+		auditWithServer(t, setupServer(), "nonexistent", http.StatusNotFound)
+	})
+}
+func TestGetPublicKey(t *testing.T) {
+	s := setupServer()
+	router := setupRouter(s)
+	rsaDeviceId := createSignatureDeviceWithServer(t, s, "RSA", "Test RSA Device", http.StatusCreated)
+	eccDeviceId := createSignatureDeviceWithServer(t, s, "ECC", "Test ECC Device", http.StatusCreated)
+	edDeviceId := createSignatureDeviceWithServer(t, s, "ED25519", "Test Ed25519 Device", http.StatusCreated)
+
+	fetch := func(t *testing.T, path, accept string, expectedStatus int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != expectedStatus {
+			t.Fatalf("Expected status code %d, got %d", expectedStatus, w.Code)
+		}
+		return w
+	}
+
+	// parseDER decodes the DER public key of a device, which uses PKCS#1 for RSA and PKIX for ECC.
+	parseDER := func(t *testing.T, der []byte) interface{} {
+		if key, err := x509.ParsePKCS1PublicKey(der); err == nil {
+			return key
+		}
+		key, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			t.Fatalf("Error parsing DER public key: %v", err)
+		}
+		return key
+	}
+
+	for _, deviceId := range []string{rsaDeviceId, eccDeviceId, edDeviceId} {
+		device, _ := s.DeviceRepository.GetDeviceById(deviceId)
+		path := "/api/v0/devices/" + deviceId + "/public-key"
+
+		t.Run(device.Algorithm+" PEM", func(t *testing.T) {
+			for _, accept := range []string{"", api.ContentTypePEM} {
+				w := fetch(t, path, accept, http.StatusOK)
+				if w.Header().Get("Content-Type") != api.ContentTypePEM {
+					t.Fatalf("Expected content type %s, got %s", api.ContentTypePEM, w.Header().Get("Content-Type"))
+				}
+				block, _ := pem.Decode(w.Body.Bytes())
+				if block == nil {
+					t.Fatalf("Expected a PEM encoded public key")
+				}
+				if key := parseDER(t, block.Bytes); !device.PublicKey.(interface{ Equal(gocrypto.PublicKey) bool }).Equal(key) {
+					t.Fatalf("PEM public key does not match the device key")
+				}
+			}
+		})
+
+		t.Run(device.Algorithm+" DER", func(t *testing.T) {
+			for _, w := range []*httptest.ResponseRecorder{
+				fetch(t, path, api.ContentTypeDER, http.StatusOK),
+				fetch(t, path+"?format=der", "", http.StatusOK),
+			} {
+				if w.Header().Get("Content-Type") != api.ContentTypeDER {
+					t.Fatalf("Expected content type %s, got %s", api.ContentTypeDER, w.Header().Get("Content-Type"))
+				}
+				if key := parseDER(t, w.Body.Bytes()); !device.PublicKey.(interface{ Equal(gocrypto.PublicKey) bool }).Equal(key) {
+					t.Fatalf("DER public key does not match the device key")
+				}
+			}
+		})
+
+		t.Run(device.Algorithm+" JWK", func(t *testing.T) {
+			w := fetch(t, path, api.ContentTypeJWK, http.StatusOK)
+
+			var jwk map[string]string
+			if err := json.NewDecoder(w.Body).Decode(&jwk); err != nil {
+				t.Fatalf("Error decoding JWK: %v", err)
+			}
+			if jwk["kid"] != deviceId {
+				t.Fatalf("Expected kid %s, got %s", deviceId, jwk["kid"])
+			}
+
+			switch key := device.PublicKey.(type) {
+			case *rsa.PublicKey:
+				if jwk["kty"] != "RSA" || jwk["n"] != base64.RawURLEncoding.EncodeToString(key.N.Bytes()) || jwk["e"] != "AQAB" {
+					t.Fatalf("Unexpected RSA JWK %v", jwk)
+				}
+			case *ecdsa.PublicKey:
+				if jwk["kty"] != "EC" || jwk["crv"] != "P-384" || len(jwk["x"]) != 64 || len(jwk["y"]) != 64 {
+					t.Fatalf("Unexpected EC JWK %v", jwk)
+				}
+			case ed25519.PublicKey:
+				if jwk["kty"] != "OKP" || jwk["crv"] != "Ed25519" || jwk["x"] != base64.RawURLEncoding.EncodeToString(key) {
+					t.Fatalf("Unexpected OKP JWK %v", jwk)
+				}
+			}
+		})
+	}
+
+	t.Run("Not Acceptable", func(t *testing.T) {
+		fetch(t, "/api/v0/devices/"+rsaDeviceId+"/public-key", "image/png", http.StatusNotAcceptable)
+	})
+
+	t.Run("Unsupported Format", func(t *testing.T) {
+		fetch(t, "/api/v0/devices/"+rsaDeviceId+"/public-key?format=xml", "", http.StatusBadRequest)
+	})
+
+	t.Run("Unknown Device", func(t *testing.T) {
+		fetch(t, "/api/v0/devices/nonexistent/public-key", "", http.StatusNotFound)
+	})
+
+	t.Run("JWKS", func(t *testing.T) {
+		w := fetch(t, "/api/v0/devices/jwks", "", http.StatusOK)
+
+		var jwks struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&jwks); err != nil {
+			t.Fatalf("Error decoding JWKS: %v", err)
+		}
+		if len(jwks.Keys) != 3 {
+			t.Fatalf("Expected 3 keys, got %d", len(jwks.Keys))
+		}
+		kids := map[string]bool{}
+		for _, key := range jwks.Keys {
+			kids[key["kid"]] = true
+		}
+		if !kids[rsaDeviceId] || !kids[eccDeviceId] || !kids[edDeviceId] {
+			t.Fatalf("Expected keys of all devices, got %v", kids)
+		}
+	})
+}
+func TestListAlgorithms(t *testing.T) {
+	s := setupServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v0/algorithms", nil)
+	w := httptest.NewRecorder()
+	setupRouter(s).ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response struct {
+		Data api.ListAlgorithmsResponse `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding list algorithms response: %v", err)
+	}
+
+	var names []string
+	for _, algorithm := range response.Data.Algorithms {
+		names = append(names, algorithm.Name)
+		if algorithm.KeyType == "" || algorithm.SignatureScheme == "" {
+			t.Fatalf("Expected metadata for algorithm %s", algorithm.Name)
+		}
+	}
+	if strings.Join(names, ",") != "ECC,ED25519,RSA,RSA-PSS" {
+		t.Fatalf("Expected algorithms ECC, ED25519, RSA and RSA-PSS, got %v", names)
+	}
+}
+func TestCreateSignatureDeviceWithParameters(t *testing.T) {
+	testCases := []struct {
+		name           string
+		request        api.CreateSignatureDeviceRequest
+		expectedStatus int
+		expected       api.DeviceResponse
+	}{
+		{
+			name:           "RSA Defaults",
+			request:        api.CreateSignatureDeviceRequest{Algorithm: "RSA"},
+			expectedStatus: http.StatusCreated,
+			expected:       api.DeviceResponse{Algorithm: "RSA", RSABits: 2048, Hash: "SHA-256"},
+		},
+		{
+			name:           "RSA 3072 SHA-384",
+			request:        api.CreateSignatureDeviceRequest{Algorithm: "RSA", RSABits: 3072, Hash: "SHA-384"},
+			expectedStatus: http.StatusCreated,
+			expected:       api.DeviceResponse{Algorithm: "RSA", RSABits: 3072, Hash: "SHA-384"},
+		},
+		{
+			name:           "ECC P-256 SHA-512",
+			request:        api.CreateSignatureDeviceRequest{Algorithm: "ECC", Curve: "P-256", Hash: "SHA-512"},
+			expectedStatus: http.StatusCreated,
+			expected:       api.DeviceResponse{Algorithm: "ECC", Curve: "P-256", Hash: "SHA-512", SignatureEncoding: "DER"},
+		},
+		{
+			name:           "ECC P1363",
+			request:        api.CreateSignatureDeviceRequest{Algorithm: "ECC", Curve: "P-256", SignatureEncoding: "P1363"},
+			expectedStatus: http.StatusCreated,
+			expected:       api.DeviceResponse{Algorithm: "ECC", Curve: "P-256", Hash: "SHA-256", SignatureEncoding: "P1363"},
+		},
+		{
+			name:           "ECC Unknown Encoding",
+			request:        api.CreateSignatureDeviceRequest{Algorithm: "ECC", SignatureEncoding: "JSON"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "RSA-PSS Defaults",
+			request:        api.CreateSignatureDeviceRequest{Algorithm: "RSA-PSS"},
+			expectedStatus: http.StatusCreated,
+			expected:       api.DeviceResponse{Algorithm: "RSA-PSS", RSABits: 2048, Hash: "SHA-256", SaltLength: 32},
+		},
+		{
+			name:           "RSA-PSS Custom Salt",
+			request:        api.CreateSignatureDeviceRequest{Algorithm: "RSA-PSS", Hash: "SHA-512", SaltLength: 64},
+			expectedStatus: http.StatusCreated,
+			expected:       api.DeviceResponse{Algorithm: "RSA-PSS", RSABits: 2048, Hash: "SHA-512", SaltLength: 64},
+		},
+		{
+			name:           "RSA-PSS Oversized Salt",
+			request:        api.CreateSignatureDeviceRequest{Algorithm: "RSA-PSS", SaltLength: 512},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "RSA Salt",
+			request:        api.CreateSignatureDeviceRequest{Algorithm: "RSA", SaltLength: 32},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "RSA 512",
+			request:        api.CreateSignatureDeviceRequest{Algorithm: "RSA", RSABits: 512},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "ECC Unknown Curve",
+			request:        api.CreateSignatureDeviceRequest{Algorithm: "ECC", Curve: "P-192"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Ed25519 Hash",
+			request:        api.CreateSignatureDeviceRequest{Algorithm: "ED25519", Hash: "SHA-256"},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := setupServer()
+			router := setupRouter(s)
+
+			body, err := json.Marshal(tc.request)
+			if err != nil {
+				t.Fatalf("Error marshalling request: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/v0/devices", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.expectedStatus, w.Code, w.Body.String())
+			}
+			if tc.expectedStatus != http.StatusCreated {
+				return
+			}
+
+			var created apiResponse
+			if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+				t.Fatalf("Error decoding response: %v", err)
+			}
+
+			getReq := httptest.NewRequest(http.MethodGet, "/api/v0/devices/{deviceId}", nil)
+			getReq.SetPathValue("deviceId", created.Data.ID)
+			getW := httptest.NewRecorder()
+			router.ServeHTTP(getW, getReq)
+
+			var response struct {
+				Data api.DeviceResponse `json:"data"`
+			}
+			if err := json.NewDecoder(getW.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding get device response: %v", err)
+			}
+			tc.expected.ID = created.Data.ID
+			tc.expected.Status = string(domain.DeviceStatusActive)
+			tc.expected.KeyGeneration = 1
+			tc.expected.SecuredDataFormat = string(domain.SecuredDataFormatLegacy)
+			if !reflect.DeepEqual(response.Data, tc.expected) {
+				t.Fatalf("Expected device %+v, got %+v", tc.expected, response.Data)
+			}
+
+			signed := signTransactionWithServer(t, s, created.Data.ID, "receipt", http.StatusOK)
+			device, _ := s.DeviceRepository.GetDeviceById(created.Data.ID)
+			signature, _ := base64.StdEncoding.DecodeString(signed.Signature)
+			if valid, err := device.Verifier.Verify([]byte(signed.SignedData), signature); err != nil || !valid {
+				t.Fatalf("Expected signature to verify, got %t, %v", valid, err)
+			}
+		})
+	}
 }
@@ -1,23 +1,42 @@
 package api
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/errors"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // SignTransactionRequest represents the request to sign data with a signature device.
+// Instead of a data string, a JSON object can be given as payload; it is signed in its
+// RFC 8785 canonical form.
 type SignTransactionRequest struct {
-	Data string `json:"data"`
+	Data    string          `json:"data"`
+	Payload json.RawMessage `json:"payload,omitempty"`
 }
 
 // SignTransactionResponse represents the response after signing the transaction.
+// For payloads, the canonical form they were signed in is returned as well.
 type SignTransactionResponse struct {
-	SignedData string `json:"signed_data"`
-	Signature  string `json:"signature"`
+	Counter          int    `json:"signature_counter"`
+	SignedData       string `json:"signed_data"`
+	Signature        string `json:"signature"`
+	CanonicalPayload string `json:"canonical_payload,omitempty"`
 }
 
+// IdempotencyKeyHeader lets clients retry sign requests without signing the same data twice.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader marks responses that return a transaction signed by an earlier request.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
 // SignTransaction signs data using the specified signature device.
+// Requests carrying an Idempotency-Key header that was already used with the same data return
+// the original transaction instead of signing again.
 func (s *Server) SignTransaction(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		WriteErrorResponse(w, http.StatusMethodNotAllowed, []string{
@@ -35,7 +54,23 @@ func (s *Server) SignTransaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	signedData, signature, err := s.TransactionService.SignTransaction(deviceId, req.Data)
+	data := req.Data
+	if req.Payload != nil {
+		if req.Data != "" {
+			WriteErrorResponse(w, http.StatusBadRequest, []string{"Either data or payload must be given, not both"})
+			return
+		}
+		data, err = s.TransactionService.CanonicalizePayload(req.Payload)
+		if err != nil {
+			appErr := errors.FromError(err)
+			WriteErrorResponse(w, appErr.Code, []string{appErr.Message})
+			return
+		}
+	}
+
+	transaction, replayed, err := s.TransactionService.SignTransactionIdempotent(
+		deviceId, data, r.Header.Get(IdempotencyKeyHeader),
+	)
 
 	if err != nil {
 		appErr := errors.FromError(err)
@@ -43,9 +78,178 @@ func (s *Server) SignTransaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := SignTransactionResponse{
-		SignedData: signedData,
-		Signature:  signature,
+	if replayed {
+		w.Header().Set(IdempotentReplayedHeader, "true")
+	}
+	response := newSignTransactionResponse(transaction)
+	if req.Payload != nil {
+		response.CanonicalPayload = transaction.Data
 	}
 	WriteAPIResponse(w, http.StatusOK, response)
 }
+
+func newSignTransactionResponse(transaction *domain.Transaction) SignTransactionResponse {
+	return SignTransactionResponse{
+		Counter:    transaction.Counter,
+		SignedData: transaction.SecuredData,
+		Signature:  base64.StdEncoding.EncodeToString(transaction.Signature),
+	}
+}
+
+// SignTransactionBatchRequest represents the request to sign several data items in order.
+type SignTransactionBatchRequest struct {
+	Data []string `json:"data"`
+}
+
+// SignTransactionBatchResponse represents the transactions signed for a batch, in request order.
+type SignTransactionBatchResponse struct {
+	Transactions []SignTransactionResponse `json:"transactions"`
+}
+
+// SignTransactionBatch signs an ordered list of data items as consecutive transactions of a device,
+// e.g. receipts collected by a till while it was offline. Either all items are signed or none.
+func (s *Server) SignTransactionBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, []string{
+			http.StatusText(http.StatusMethodNotAllowed),
+		})
+		return
+	}
+
+	deviceId := r.PathValue("deviceId")
+
+	var req SignTransactionBatchRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, []string{"Invalid request payload"})
+		return
+	}
+
+	transactions, err := s.TransactionService.SignTransactions(deviceId, req.Data)
+	if err != nil {
+		appErr := errors.FromError(err)
+		WriteErrorResponse(w, appErr.Code, []string{appErr.Message})
+		return
+	}
+
+	responses := make([]SignTransactionResponse, len(transactions))
+	for i, transaction := range transactions {
+		responses[i] = newSignTransactionResponse(transaction)
+	}
+	WriteAPIResponse(w, http.StatusOK, SignTransactionBatchResponse{Transactions: responses})
+}
+
+const (
+	defaultTransactionPageSize = 50
+	maxTransactionPageSize     = 500
+)
+
+// TransactionResponse represents a signed transaction of a device.
+type TransactionResponse struct {
+	DeviceID   string    `json:"device_id"`
+	Counter    int       `json:"signature_counter"`
+	Data       string    `json:"data"`
+	SignedData string    `json:"signed_data"`
+	Signature  string    `json:"signature"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ListTransactionsResponse represents a page of a device's signed transactions.
+type ListTransactionsResponse struct {
+	Transactions []TransactionResponse `json:"transactions"`
+	Offset       int                   `json:"offset"`
+	Limit        int                   `json:"limit"`
+	Total        int                   `json:"total"`
+}
+
+// ListTransactions lists the signed transactions of a device ordered by signature counter.
+// The page is selected through the optional `offset` and `limit` query parameters.
+func (s *Server) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, []string{
+			http.StatusText(http.StatusMethodNotAllowed),
+		})
+		return
+	}
+
+	deviceId := r.PathValue("deviceId")
+
+	offset, err := parseQueryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		WriteErrorResponse(w, http.StatusBadRequest, []string{"Invalid offset"})
+		return
+	}
+	limit, err := parseQueryInt(r, "limit", defaultTransactionPageSize)
+	if err != nil || limit < 1 || limit > maxTransactionPageSize {
+		WriteErrorResponse(w, http.StatusBadRequest, []string{
+			fmt.Sprintf("Invalid limit, must be between 1 and %d", maxTransactionPageSize),
+		})
+		return
+	}
+
+	transactions, total, err := s.TransactionService.ListTransactions(deviceId, offset, limit)
+	if err != nil {
+		appErr := errors.FromError(err)
+		WriteErrorResponse(w, appErr.Code, []string{appErr.Message})
+		return
+	}
+
+	transactionResponses := make([]TransactionResponse, len(transactions))
+	for i, transaction := range transactions {
+		transactionResponses[i] = newTransactionResponse(transaction)
+	}
+
+	WriteAPIResponse(w, http.StatusOK, ListTransactionsResponse{
+		Transactions: transactionResponses,
+		Offset:       offset,
+		Limit:        limit,
+		Total:        total,
+	})
+}
+
+// GetTransaction fetches a device's signed transaction by its signature counter.
+func (s *Server) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, []string{
+			http.StatusText(http.StatusMethodNotAllowed),
+		})
+		return
+	}
+
+	deviceId := r.PathValue("deviceId")
+
+	counter, err := strconv.Atoi(r.PathValue("counter"))
+	if err != nil || counter < 0 {
+		WriteErrorResponse(w, http.StatusBadRequest, []string{"Invalid signature counter"})
+		return
+	}
+
+	transaction, err := s.TransactionService.GetTransaction(deviceId, counter)
+	if err != nil {
+		appErr := errors.FromError(err)
+		WriteErrorResponse(w, appErr.Code, []string{appErr.Message})
+		return
+	}
+
+	WriteAPIResponse(w, http.StatusOK, newTransactionResponse(transaction))
+}
+
+func newTransactionResponse(transaction *domain.Transaction) TransactionResponse {
+	return TransactionResponse{
+		DeviceID:   transaction.DeviceID.String(),
+		Counter:    transaction.Counter,
+		Data:       transaction.Data,
+		SignedData: transaction.SecuredData,
+		Signature:  base64.StdEncoding.EncodeToString(transaction.Signature),
+		CreatedAt:  transaction.CreatedAt,
+	}
+}
+
+// parseQueryInt reads an integer query parameter, falling back to a default when it is absent.
+func parseQueryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/errors"
+	"net/http"
+)
+
+// VerifySignatureRequest represents the request to verify a signature issued by a signature device.
+type VerifySignatureRequest struct {
+	SignedData string `json:"signed_data"`
+	Signature  string `json:"signature"`
+}
+
+// VerifySignatureResponse represents the outcome of a signature verification.
+type VerifySignatureResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// VerifySignature checks a base64 encoded signature against the public key of the specified device.
+func (s *Server) VerifySignature(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, []string{
+			http.StatusText(http.StatusMethodNotAllowed),
+		})
+		return
+	}
+
+	deviceId := r.PathValue("deviceId")
+
+	var req VerifySignatureRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		WriteErrorResponse(w, http.StatusBadRequest, []string{"Invalid request payload"})
+		return
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(req.Signature)
+	if err != nil || len(signature) == 0 {
+		WriteErrorResponse(w, http.StatusBadRequest, []string{"Signature must be a non-empty base64 encoded string"})
+		return
+	}
+
+	valid, err := s.TransactionService.VerifySignature(deviceId, req.SignedData, signature)
+	if err != nil {
+		appErr := errors.FromError(err)
+		WriteErrorResponse(w, appErr.Code, []string{appErr.Message})
+		return
+	}
+
+	WriteAPIResponse(w, http.StatusOK, VerifySignatureResponse{Valid: valid})
+}
@@ -0,0 +1,80 @@
+// Command remote-signer is a key custody process: it holds the private keys of signature
+// devices and signs on behalf of the signing service over gRPC.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/remote"
+	"google.golang.org/grpc"
+)
+
+// KEKEnv holds the base64 encoded key-encryption key if no key file is given. It differs from the
+// signing service's variable, so that both processes do not pick up the same key by accident.
+const KEKEnv = "REMOTE_SIGNER_KEK"
+
+func main() {
+	listenAddress := flag.String("listen", ":8700", "address to serve gRPC on")
+	keyDir := flag.String("key-dir", "keys", "directory keys are stored in; empty keeps them in memory only")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file the server presents")
+	tlsKey := flag.String("tls-key", "", "TLS private key file")
+	tlsClientCA := flag.String("tls-client-ca", "", "CA certificate client certificates have to be issued by")
+	insecureLoopback := flag.Bool("insecure-loopback", false, "serve without TLS and client authentication; only allowed on a loopback address")
+	kekFile := flag.String("kek-file", "", "file holding the base64 encoded key-encryption key key files are sealed with")
+	flag.Parse()
+
+	algorithms := crypto.DefaultRegistry
+	if *kekFile != "" || os.Getenv(KEKEnv) != "" {
+		var (
+			kek *crypto.KeyEncryptionKey
+			err error
+		)
+		if *kekFile != "" {
+			kek, err = crypto.LoadKeyEncryptionKey(*kekFile)
+		} else {
+			kek, err = crypto.KeyEncryptionKeyFromEnv(KEKEnv)
+		}
+		if err != nil {
+			log.Fatal("Could not load key-encryption key: ", err)
+		}
+		algorithms = crypto.NewSealedRegistry(crypto.DefaultRegistry, crypto.NewKeySealer(kek))
+	}
+
+	custody, err := remote.NewServer(algorithms, *keyDir)
+	if err != nil {
+		log.Fatal("Could not load keys: ", err)
+	}
+
+	// Anyone who can reach the server can sign with its keys, so clients have to authenticate
+	// with a certificate unless the server is only reachable from this host.
+	var options []grpc.ServerOption
+	if *insecureLoopback {
+		if !remote.IsLoopbackAddress(*listenAddress) {
+			log.Fatal("-insecure-loopback requires a loopback listen address such as 127.0.0.1:8700, got ", *listenAddress)
+		}
+		log.Println("Serving without TLS and client authentication on a loopback address")
+	} else {
+		creds, err := remote.ServerCredentials(*tlsCert, *tlsKey, *tlsClientCA)
+		if err != nil {
+			log.Fatal("Could not set up mutual TLS: ", err)
+		}
+		options = append(options, grpc.Creds(creds))
+	}
+
+	listener, err := net.Listen("tcp", *listenAddress)
+	if err != nil {
+		log.Fatal("Could not listen on ", *listenAddress, ": ", err)
+	}
+
+	server := grpc.NewServer(options...)
+	remote.RegisterKeyCustodyServer(server, custody)
+
+	log.Println("Key custody server listening on", *listenAddress)
+	if err := server.Serve(listener); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,275 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+)
+
+func init() {
+	DefaultRegistry.MustRegister(Algorithm{
+		Name: "RSA",
+		Metadata: AlgorithmMetadata{
+			Description:     "RSA signatures",
+			KeyType:         "RSA",
+			SignatureScheme: "PKCS#1 v1.5",
+		},
+		DefaultParameters: Parameters{RSABits: DefaultRSABits, Hash: "SHA-256"},
+		Generate: func(parameters Parameters) (*KeyPair, error) {
+			keyPair, err := (&RSAGenerator{Bits: parameters.RSABits}).Generate()
+			if err != nil {
+				return nil, err
+			}
+			return &KeyPair{Public: keyPair.Public, Private: keyPair.Private}, nil
+		},
+		NewSigner: func(privateKey interface{}, parameters Parameters) (Signer, error) {
+			key, ok := privateKey.(*rsa.PrivateKey)
+			if !ok {
+				return nil, keyTypeError("RSA", privateKey)
+			}
+			hash, err := ParseHash(parameters.Hash)
+			if err != nil {
+				return nil, err
+			}
+			return &RSASigner{PrivateKey: key, Hash: hash}, nil
+		},
+		NewVerifier: func(publicKey interface{}, parameters Parameters) (Verifier, error) {
+			key, ok := publicKey.(*rsa.PublicKey)
+			if !ok {
+				return nil, keyTypeError("RSA", publicKey)
+			}
+			hash, err := ParseHash(parameters.Hash)
+			if err != nil {
+				return nil, err
+			}
+			return &RSAVerifier{PublicKey: key, Hash: hash}, nil
+		},
+		Marshaler: rsaKeyMarshaler{},
+		JWA: func(parameters Parameters) string {
+			return jwaByHash("RS", parameters.Hash)
+		},
+	})
+
+	DefaultRegistry.MustRegister(Algorithm{
+		Name: "RSA-PSS",
+		Metadata: AlgorithmMetadata{
+			Description:     "RSA signatures with probabilistic padding",
+			KeyType:         "RSA",
+			SignatureScheme: "RSASSA-PSS with MGF1",
+		},
+		DefaultParameters: Parameters{RSABits: DefaultRSABits, Hash: "SHA-256", SaltLength: 32},
+		Generate: func(parameters Parameters) (*KeyPair, error) {
+			keyPair, err := (&RSAGenerator{Bits: parameters.RSABits}).Generate()
+			if err != nil {
+				return nil, err
+			}
+			return &KeyPair{Public: keyPair.Public, Private: keyPair.Private}, nil
+		},
+		NewSigner: func(privateKey interface{}, parameters Parameters) (Signer, error) {
+			key, ok := privateKey.(*rsa.PrivateKey)
+			if !ok {
+				return nil, keyTypeError("RSA-PSS", privateKey)
+			}
+			hash, err := ParseHash(parameters.Hash)
+			if err != nil {
+				return nil, err
+			}
+			return &RSAPSSSigner{PrivateKey: key, Hash: hash, SaltLength: parameters.SaltLength}, nil
+		},
+		NewVerifier: func(publicKey interface{}, parameters Parameters) (Verifier, error) {
+			key, ok := publicKey.(*rsa.PublicKey)
+			if !ok {
+				return nil, keyTypeError("RSA-PSS", publicKey)
+			}
+			hash, err := ParseHash(parameters.Hash)
+			if err != nil {
+				return nil, err
+			}
+			return &RSAPSSVerifier{PublicKey: key, Hash: hash, SaltLength: parameters.SaltLength}, nil
+		},
+		Marshaler: rsaKeyMarshaler{},
+		JWA: func(parameters Parameters) string {
+			// JOSE fixes the PSS salt length to the hash size.
+			hash, err := ParseHash(parameters.Hash)
+			if err != nil || (parameters.SaltLength != 0 && parameters.SaltLength != hash.Size()) {
+				return ""
+			}
+			return jwaByHash("PS", parameters.Hash)
+		},
+	})
+
+	DefaultRegistry.MustRegister(Algorithm{
+		Name: "ECC",
+		Metadata: AlgorithmMetadata{
+			Description:     "Elliptic curve signatures on NIST curves",
+			KeyType:         "EC",
+			SignatureScheme: "ECDSA",
+		},
+		DefaultParameters: Parameters{Curve: "P-384", Hash: "SHA-256", SignatureEncoding: SignatureEncodingDER},
+		Generate: func(parameters Parameters) (*KeyPair, error) {
+			curve, err := ParseCurve(parameters.Curve)
+			if err != nil {
+				return nil, err
+			}
+			keyPair, err := (&ECCGenerator{Curve: curve}).Generate()
+			if err != nil {
+				return nil, err
+			}
+			return &KeyPair{Public: keyPair.Public, Private: keyPair.Private}, nil
+		},
+		NewSigner: func(privateKey interface{}, parameters Parameters) (Signer, error) {
+			key, ok := privateKey.(*ecdsa.PrivateKey)
+			if !ok {
+				return nil, keyTypeError("ECC", privateKey)
+			}
+			hash, err := ParseHash(parameters.Hash)
+			if err != nil {
+				return nil, err
+			}
+			return &ECCSigner{PrivateKey: key, Hash: hash, Encoding: parameters.SignatureEncoding}, nil
+		},
+		NewVerifier: func(publicKey interface{}, parameters Parameters) (Verifier, error) {
+			key, ok := publicKey.(*ecdsa.PublicKey)
+			if !ok {
+				return nil, keyTypeError("ECC", publicKey)
+			}
+			hash, err := ParseHash(parameters.Hash)
+			if err != nil {
+				return nil, err
+			}
+			return &ECCVerifier{PublicKey: key, Hash: hash, Encoding: parameters.SignatureEncoding}, nil
+		},
+		Marshaler: eccKeyMarshaler{},
+		JWA: func(parameters Parameters) string {
+			// JOSE only defines ECDSA with matching curve and hash sizes and r||s encoded signatures.
+			if parameters.SignatureEncoding != SignatureEncodingP1363 {
+				return ""
+			}
+			switch {
+			case parameters.Curve == "P-256" && parameters.Hash == "SHA-256":
+				return "ES256"
+			case parameters.Curve == "P-384" && parameters.Hash == "SHA-384":
+				return "ES384"
+			case parameters.Curve == "P-521" && parameters.Hash == "SHA-512":
+				return "ES512"
+			default:
+				return ""
+			}
+		},
+	})
+
+	DefaultRegistry.MustRegister(Algorithm{
+		Name: "ED25519",
+		Metadata: AlgorithmMetadata{
+			Description:     "Edwards-curve signatures on Curve25519",
+			KeyType:         "OKP",
+			SignatureScheme: "Ed25519",
+		},
+		Generate: func(Parameters) (*KeyPair, error) {
+			keyPair, err := (&Ed25519Generator{}).Generate()
+			if err != nil {
+				return nil, err
+			}
+			return &KeyPair{Public: keyPair.Public, Private: keyPair.Private}, nil
+		},
+		NewSigner: func(privateKey interface{}, _ Parameters) (Signer, error) {
+			key, ok := privateKey.(ed25519.PrivateKey)
+			if !ok {
+				return nil, keyTypeError("ED25519", privateKey)
+			}
+			return &Ed25519Signer{PrivateKey: key}, nil
+		},
+		NewVerifier: func(publicKey interface{}, _ Parameters) (Verifier, error) {
+			key, ok := publicKey.(ed25519.PublicKey)
+			if !ok {
+				return nil, keyTypeError("ED25519", publicKey)
+			}
+			return &Ed25519Verifier{PublicKey: key}, nil
+		},
+		Marshaler: ed25519KeyMarshaler{},
+		JWA: func(Parameters) string {
+			return "EdDSA"
+		},
+	})
+}
+
+// jwaByHash derives a JOSE algorithm name such as RS256 from a prefix and a hash function name.
+func jwaByHash(prefix, hash string) string {
+	switch hash {
+	case "", "SHA-256":
+		return prefix + "256"
+	case "SHA-384":
+		return prefix + "384"
+	case "SHA-512":
+		return prefix + "512"
+	default:
+		return ""
+	}
+}
+
+func keyTypeError(algorithm string, key interface{}) error {
+	return fmt.Errorf("unsupported key type %T for algorithm %s", key, algorithm)
+}
+
+// rsaKeyMarshaler adapts RSAMarshaler to the KeyMarshaler interface.
+type rsaKeyMarshaler struct{}
+
+func (rsaKeyMarshaler) Marshal(keyPair KeyPair) ([]byte, []byte, error) {
+	privateKey, ok := keyPair.Private.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, keyTypeError("RSA", keyPair.Private)
+	}
+	marshaler := NewRSAMarshaler()
+	return marshaler.Marshal(RSAKeyPair{Public: &privateKey.PublicKey, Private: privateKey})
+}
+
+func (rsaKeyMarshaler) Unmarshal(privateKeyBytes []byte) (*KeyPair, error) {
+	marshaler := NewRSAMarshaler()
+	keyPair, err := marshaler.Unmarshal(privateKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{Public: keyPair.Public, Private: keyPair.Private}, nil
+}
+
+// eccKeyMarshaler adapts ECCMarshaler to the KeyMarshaler interface.
+type eccKeyMarshaler struct{}
+
+func (eccKeyMarshaler) Marshal(keyPair KeyPair) ([]byte, []byte, error) {
+	privateKey, ok := keyPair.Private.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, nil, keyTypeError("ECC", keyPair.Private)
+	}
+	return NewECCMarshaler().Encode(ECCKeyPair{Public: &privateKey.PublicKey, Private: privateKey})
+}
+
+func (eccKeyMarshaler) Unmarshal(privateKeyBytes []byte) (*KeyPair, error) {
+	keyPair, err := NewECCMarshaler().Decode(privateKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{Public: keyPair.Public, Private: keyPair.Private}, nil
+}
+
+// ed25519KeyMarshaler adapts Ed25519Marshaler to the KeyMarshaler interface.
+type ed25519KeyMarshaler struct{}
+
+func (ed25519KeyMarshaler) Marshal(keyPair KeyPair) ([]byte, []byte, error) {
+	privateKey, ok := keyPair.Private.(ed25519.PrivateKey)
+	if !ok {
+		return nil, nil, keyTypeError("ED25519", keyPair.Private)
+	}
+	return NewEd25519Marshaler().Encode(Ed25519KeyPair{
+		Public:  privateKey.Public().(ed25519.PublicKey),
+		Private: privateKey,
+	})
+}
+
+func (ed25519KeyMarshaler) Unmarshal(privateKeyBytes []byte) (*KeyPair, error) {
+	keyPair, err := NewEd25519Marshaler().Decode(privateKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{Public: keyPair.Public, Private: keyPair.Private}, nil
+}
@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"testing"
 )
@@ -18,8 +19,16 @@ func TestRSAGenerator(t *testing.T) {
 
 	privateKey := keyPair.Private
 
-	if privateKey.N.BitLen() != 512 {
-		t.Fatalf("Expected RSA key with 512 bits, got %d bits", privateKey.N.BitLen())
+	if privateKey.N.BitLen() != DefaultRSABits {
+		t.Fatalf("Expected RSA key with %d bits, got %d bits", DefaultRSABits, privateKey.N.BitLen())
+	}
+
+	keyPair, err = (&RSAGenerator{Bits: 3072}).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key pair: %v", err)
+	}
+	if keyPair.Private.N.BitLen() != 3072 {
+		t.Fatalf("Expected RSA key with 3072 bits, got %d bits", keyPair.Private.N.BitLen())
 	}
 }
 
@@ -39,4 +48,50 @@ func TestECCGenerator(t *testing.T) {
 	if privateKey.Curve != elliptic.P384() {
 		t.Fatalf("Expected curve P-384, got %v", privateKey.Curve)
 	}
+
+	keyPair, err = (&ECCGenerator{Curve: elliptic.P521()}).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate ECC key pair: %v", err)
+	}
+	if keyPair.Private.Curve != elliptic.P521() {
+		t.Fatalf("Expected curve P-521, got %v", keyPair.Private.Curve)
+	}
+}
+
+// TestEd25519Generator tests the Ed25519 key generation logic.
+func TestEd25519Generator(t *testing.T) {
+	generator := &Ed25519Generator{}
+	keyPair, err := generator.Generate()
+
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key pair: %v", err)
+	}
+	if keyPair == nil || keyPair.Private == nil || keyPair.Public == nil {
+		t.Fatalf("Expected non-nil Ed25519 key pair, got nil")
+	}
+	if len(keyPair.Public) != ed25519.PublicKeySize {
+		t.Fatalf("Expected public key of %d bytes, got %d", ed25519.PublicKeySize, len(keyPair.Public))
+	}
+}
+
+// TestEd25519Marshaler tests that an encoded Ed25519 key pair decodes to the same keys.
+func TestEd25519Marshaler(t *testing.T) {
+	keyPair, err := (&Ed25519Generator{}).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key pair: %v", err)
+	}
+
+	marshaler := NewEd25519Marshaler()
+	_, encodedPrivate, err := marshaler.Encode(*keyPair)
+	if err != nil {
+		t.Fatalf("Failed to encode Ed25519 key pair: %v", err)
+	}
+
+	decoded, err := marshaler.Decode(encodedPrivate)
+	if err != nil {
+		t.Fatalf("Failed to decode Ed25519 key pair: %v", err)
+	}
+	if !decoded.Private.Equal(keyPair.Private) || !decoded.Public.Equal(keyPair.Public) {
+		t.Fatalf("Decoded Ed25519 key pair does not match the original")
+	}
 }
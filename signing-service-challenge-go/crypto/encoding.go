@@ -0,0 +1,64 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+)
+
+// ECDSA signature encodings.
+const (
+	// SignatureEncodingDER is the ASN.1 DER SEQUENCE { r INTEGER, s INTEGER }.
+	SignatureEncodingDER = "DER"
+	// SignatureEncodingP1363 is the fixed-length concatenation r||s of IEEE P1363, as used by JWS.
+	SignatureEncodingP1363 = "P1363"
+)
+
+// encodeECDSASignature serializes r and s in the given encoding, defaulting to DER.
+func encodeECDSASignature(publicKey *ecdsa.PublicKey, encoding string, r, s *big.Int) ([]byte, error) {
+	switch encoding {
+	case "", SignatureEncodingDER:
+		return asn1.Marshal(struct {
+			R, S *big.Int
+		}{r, s})
+	case SignatureEncodingP1363:
+		size := curveByteSize(publicKey)
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+		return signature, nil
+	default:
+		return nil, fmt.Errorf("unsupported signature encoding %s", encoding)
+	}
+}
+
+// decodeECDSASignature parses r and s from the given encoding, defaulting to DER.
+// It reports false for malformed signatures.
+func decodeECDSASignature(publicKey *ecdsa.PublicKey, encoding string, signature []byte) (*big.Int, *big.Int, bool, error) {
+	switch encoding {
+	case "", SignatureEncodingDER:
+		var esig struct {
+			R, S *big.Int
+		}
+		rest, err := asn1.Unmarshal(signature, &esig)
+		if err != nil || len(rest) != 0 {
+			return nil, nil, false, nil
+		}
+		return esig.R, esig.S, true, nil
+	case SignatureEncodingP1363:
+		size := curveByteSize(publicKey)
+		if len(signature) != 2*size {
+			return nil, nil, false, nil
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return r, s, true, nil
+	default:
+		return nil, nil, false, fmt.Errorf("unsupported signature encoding %s", encoding)
+	}
+}
+
+func curveByteSize(publicKey *ecdsa.PublicKey) int {
+	return (publicKey.Curve.Params().BitSize + 7) / 8
+}
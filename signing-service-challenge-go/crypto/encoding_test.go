@@ -0,0 +1,66 @@
+package crypto_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	cryptoLib "github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"math/big"
+	"testing"
+)
+
+// TestECCSignatureEncodings tests DER and IEEE P1363 encoded ECDSA signatures.
+func TestECCSignatureEncodings(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		t.Run(curve.Params().Name, func(t *testing.T) {
+			key, err := ecdsa.GenerateKey(curve, rand.Reader)
+			if err != nil {
+				t.Fatalf("Failed to generate ECC key: %v", err)
+			}
+			data := []byte("Test data for ECC signing")
+			size := (curve.Params().BitSize + 7) / 8
+
+			signer := &cryptoLib.ECCSigner{PrivateKey: key, Encoding: cryptoLib.SignatureEncodingP1363}
+			signature, err := signer.Sign(data)
+			if err != nil {
+				t.Fatalf("Failed to sign data: %v", err)
+			}
+			if len(signature) != 2*size {
+				t.Fatalf("Expected P1363 signature of %d bytes, got %d", 2*size, len(signature))
+			}
+
+			// The halves of a P1363 signature are the raw big-endian r and s values.
+			hashed := sha256.Sum256(data)
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if !ecdsa.Verify(&key.PublicKey, hashed[:], r, s) {
+				t.Fatalf("Expected r||s to verify with the standard library")
+			}
+
+			p1363 := &cryptoLib.ECCVerifier{PublicKey: &key.PublicKey, Encoding: cryptoLib.SignatureEncodingP1363}
+			der := &cryptoLib.ECCVerifier{PublicKey: &key.PublicKey, Encoding: cryptoLib.SignatureEncodingDER}
+
+			if valid, err := p1363.Verify(data, signature); err != nil || !valid {
+				t.Fatalf("Expected P1363 signature to verify, got %t, %v", valid, err)
+			}
+			if valid, _ := der.Verify(data, signature); valid {
+				t.Fatalf("Expected P1363 signature not to verify as DER")
+			}
+			if valid, _ := p1363.Verify(data, signature[1:]); valid {
+				t.Fatalf("Expected truncated P1363 signature not to verify")
+			}
+
+			derSignature, err := (&cryptoLib.ECCSigner{PrivateKey: key}).Sign(data)
+			if err != nil {
+				t.Fatalf("Failed to sign data: %v", err)
+			}
+			if valid, err := der.Verify(data, derSignature); err != nil || !valid {
+				t.Fatalf("Expected DER signature to verify, got %t, %v", valid, err)
+			}
+			if valid, _ := p1363.Verify(data, derSignature); valid {
+				t.Fatalf("Expected DER signature not to verify as P1363")
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// KeyEncryptionKeySize is the size of a key-encryption key in bytes (AES-256).
+const KeyEncryptionKeySize = 32
+
+const sealedPrivateKeyPEMType = "SEALED_PRIVATE_KEY"
+
+// KeyEncryptionKey is an AES-256 key that wraps the data keys private keys are sealed with.
+type KeyEncryptionKey struct {
+	// ID identifies the key in sealed private keys without revealing it.
+	ID  string
+	key []byte
+}
+
+// NewKeyEncryptionKey creates a KeyEncryptionKey from raw key material.
+func NewKeyEncryptionKey(key []byte) (*KeyEncryptionKey, error) {
+	if len(key) != KeyEncryptionKeySize {
+		return nil, fmt.Errorf("key-encryption key must be %d bytes, got %d", KeyEncryptionKeySize, len(key))
+	}
+
+	fingerprint := sha256.Sum256(key)
+	return &KeyEncryptionKey{
+		ID:  hex.EncodeToString(fingerprint[:8]),
+		key: bytes.Clone(key),
+	}, nil
+}
+
+// ParseKeyEncryptionKey decodes a base64 encoded key-encryption key.
+func ParseKeyEncryptionKey(encoded string) (*KeyEncryptionKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key-encryption key is not valid base64: %w", err)
+	}
+	return NewKeyEncryptionKey(key)
+}
+
+// LoadKeyEncryptionKey reads a base64 encoded key-encryption key from a file.
+func LoadKeyEncryptionKey(path string) (*KeyEncryptionKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyEncryptionKey(string(content))
+}
+
+// KeyEncryptionKeyFromEnv reads a base64 encoded key-encryption key from an environment variable.
+func KeyEncryptionKeyFromEnv(name string) (*KeyEncryptionKey, error) {
+	encoded, exists := os.LookupEnv(name)
+	if !exists {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return ParseKeyEncryptionKey(encoded)
+}
+
+// SealedPrivateKey is a private key encrypted at rest with envelope encryption: the encoded key is
+// encrypted with AES-GCM under a random data key, which is in turn wrapped under a key-encryption key.
+// The public key is kept in the clear, so verifying never requires unsealing.
+type SealedPrivateKey struct {
+	mu         sync.RWMutex
+	keyID      string
+	wrappedKey []byte
+	ciphertext []byte
+
+	publicKeyPEM []byte
+	PublicKey    interface{}
+}
+
+// KeyID returns the ID of the key-encryption key the data key is currently wrapped under.
+func (k *SealedPrivateKey) KeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.keyID
+}
+
+// Encode encodes the sealed key as a PEM block to be written on disk.
+func (k *SealedPrivateKey) Encode() []byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type: sealedPrivateKeyPEMType,
+		Headers: map[string]string{
+			"Kek-Id":      k.keyID,
+			"Wrapped-Key": base64.StdEncoding.EncodeToString(k.wrappedKey),
+			"Public-Key":  base64.StdEncoding.EncodeToString(k.publicKeyPEM),
+		},
+		Bytes: k.ciphertext,
+	})
+}
+
+// IsSealedPrivateKey reports whether encoded holds a sealed rather than a plaintext private key.
+func IsSealedPrivateKey(encoded []byte) bool {
+	block, _ := pem.Decode(encoded)
+	return block != nil && block.Type == sealedPrivateKeyPEMType
+}
+
+// DecodeSealedPrivateKey decodes a sealed key written by Encode.
+func DecodeSealedPrivateKey(encoded []byte) (*SealedPrivateKey, error) {
+	block, _ := pem.Decode(encoded)
+	if block == nil || block.Type != sealedPrivateKeyPEMType {
+		return nil, errors.New("failed to decode sealed private key")
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(block.Headers["Wrapped-Key"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key: %w", err)
+	}
+	publicKeyPEM, err := base64.StdEncoding.DecodeString(block.Headers["Public-Key"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	publicKey, err := ParsePublicKeyPEM(publicKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SealedPrivateKey{
+		keyID:        block.Headers["Kek-Id"],
+		wrappedKey:   wrappedKey,
+		ciphertext:   block.Bytes,
+		publicKeyPEM: publicKeyPEM,
+		PublicKey:    publicKey,
+	}, nil
+}
+
+// KeySealer seals private keys under its current key-encryption key.
+// Keys sealed under a previous key-encryption key can still be opened and re-wrapped.
+type KeySealer struct {
+	mu      sync.RWMutex
+	current *KeyEncryptionKey
+	keys    map[string]*KeyEncryptionKey
+}
+
+// NewKeySealer creates a KeySealer that seals under current and additionally opens keys sealed under previous.
+func NewKeySealer(current *KeyEncryptionKey, previous ...*KeyEncryptionKey) *KeySealer {
+	sealer := &KeySealer{
+		current: current,
+		keys:    map[string]*KeyEncryptionKey{current.ID: current},
+	}
+	for _, kek := range previous {
+		sealer.keys[kek.ID] = kek
+	}
+	return sealer
+}
+
+// CurrentKeyID returns the ID of the key-encryption key new keys are sealed under.
+func (s *KeySealer) CurrentKeyID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.current.ID
+}
+
+// Rotate makes next the current key-encryption key. The previous one is kept to open existing keys
+// until they are re-wrapped.
+func (s *KeySealer) Rotate(next *KeyEncryptionKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current = next
+	s.keys[next.ID] = next
+}
+
+// Seal encrypts an encoded private key under a fresh data key. The public key stays readable.
+func (s *KeySealer) Seal(privateKeyPEM, publicKeyPEM []byte) (*SealedPrivateKey, error) {
+	publicKey, err := ParsePublicKeyPEM(publicKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	defer clear(dataKey)
+
+	// The public key is authenticated along with the private key, so they cannot be mixed up.
+	ciphertext, err := sealAESGCM(dataKey, privateKeyPEM, publicKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	kek := s.current
+	s.mu.RUnlock()
+
+	wrappedKey, err := sealAESGCM(kek.key, dataKey, []byte(kek.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &SealedPrivateKey{
+		keyID:        kek.ID,
+		wrappedKey:   wrappedKey,
+		ciphertext:   ciphertext,
+		publicKeyPEM: bytes.Clone(publicKeyPEM),
+		PublicKey:    publicKey,
+	}, nil
+}
+
+// Open decrypts a sealed private key. The caller should clear the returned bytes once done with them.
+func (s *KeySealer) Open(key *SealedPrivateKey) ([]byte, error) {
+	key.mu.RLock()
+	defer key.mu.RUnlock()
+
+	dataKey, err := s.unwrap(key.keyID, key.wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(dataKey)
+
+	return openAESGCM(dataKey, key.ciphertext, key.publicKeyPEM)
+}
+
+// Rewrap re-wraps the data key of a sealed private key under the current key-encryption key.
+// The encrypted private key itself is left untouched. It reports whether the key was changed.
+func (s *KeySealer) Rewrap(key *SealedPrivateKey) (bool, error) {
+	key.mu.Lock()
+	defer key.mu.Unlock()
+
+	s.mu.RLock()
+	kek := s.current
+	s.mu.RUnlock()
+
+	if key.keyID == kek.ID {
+		return false, nil
+	}
+
+	dataKey, err := s.unwrap(key.keyID, key.wrappedKey)
+	if err != nil {
+		return false, err
+	}
+	defer clear(dataKey)
+
+	wrappedKey, err := sealAESGCM(kek.key, dataKey, []byte(kek.ID))
+	if err != nil {
+		return false, err
+	}
+
+	key.keyID = kek.ID
+	key.wrappedKey = wrappedKey
+	return true, nil
+}
+
+func (s *KeySealer) unwrap(keyID string, wrappedKey []byte) ([]byte, error) {
+	s.mu.RLock()
+	kek, exists := s.keys[keyID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("private key is sealed under unknown key-encryption key %s", keyID)
+	}
+
+	dataKey, err := openAESGCM(kek.key, wrappedKey, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// sealAESGCM encrypts plaintext with AES-GCM and prepends the random nonce.
+func sealAESGCM(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// openAESGCM decrypts the output of sealAESGCM.
+func openAESGCM(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ParsePublicKeyPEM parses a public key encoded by one of the marshalers of this package.
+func ParsePublicKeyPEM(encoded []byte) (interface{}, error) {
+	block, _ := pem.Decode(encoded)
+	if block == nil {
+		return nil, errors.New("failed to decode public key")
+	}
+	if publicKey, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return publicKey, nil
+	}
+	return x509.ParsePKCS1PublicKey(block.Bytes)
+}
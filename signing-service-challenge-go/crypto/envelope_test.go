@@ -0,0 +1,139 @@
+package crypto_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	cryptoLib "github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+func newTestKEK(t *testing.T) *cryptoLib.KeyEncryptionKey {
+	key := make([]byte, cryptoLib.KeyEncryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("Failed to generate key-encryption key: %v", err)
+	}
+	kek, err := cryptoLib.NewKeyEncryptionKey(key)
+	if err != nil {
+		t.Fatalf("Failed to create key-encryption key: %v", err)
+	}
+	return kek
+}
+
+// TestSealedRegistry tests that sealed keys sign, verify and survive a marshal round trip for every algorithm.
+func TestSealedRegistry(t *testing.T) {
+	registry := cryptoLib.NewSealedRegistry(cryptoLib.DefaultRegistry, cryptoLib.NewKeySealer(newTestKEK(t)))
+
+	for _, algorithm := range registry.List() {
+		t.Run(algorithm.Name, func(t *testing.T) {
+			keyPair, err := algorithm.Generate(algorithm.DefaultParameters)
+			if err != nil {
+				t.Fatalf("Failed to generate key pair: %v", err)
+			}
+			if _, ok := keyPair.Private.(*cryptoLib.SealedPrivateKey); !ok {
+				t.Fatalf("Expected a sealed private key, got %T", keyPair.Private)
+			}
+
+			_, privateKey, err := algorithm.Marshaler.Marshal(*keyPair)
+			if err != nil {
+				t.Fatalf("Failed to marshal key pair: %v", err)
+			}
+			if !cryptoLib.IsSealedPrivateKey(privateKey) || strings.Contains(string(privateKey), "-----BEGIN RSA_PRIVATE_KEY") {
+				t.Fatalf("Expected the private key to be written sealed, got:\n%s", privateKey)
+			}
+
+			restored, err := algorithm.Marshaler.Unmarshal(privateKey)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal key pair: %v", err)
+			}
+
+			signer, err := algorithm.NewSigner(restored.Private, algorithm.DefaultParameters)
+			if err != nil {
+				t.Fatalf("Failed to create signer: %v", err)
+			}
+			verifier, err := algorithm.NewVerifier(restored.Public, algorithm.DefaultParameters)
+			if err != nil {
+				t.Fatalf("Failed to create verifier: %v", err)
+			}
+
+			signature, err := signer.Sign([]byte("data"))
+			if err != nil {
+				t.Fatalf("Failed to sign: %v", err)
+			}
+			valid, err := verifier.Verify([]byte("data"), signature)
+			if err != nil || !valid {
+				t.Fatalf("Expected signature to verify, got %v (%v)", valid, err)
+			}
+		})
+	}
+}
+
+func TestKeySealerRotation(t *testing.T) {
+	oldKEK, newKEK := newTestKEK(t), newTestKEK(t)
+	sealer := cryptoLib.NewKeySealer(oldKEK)
+
+	algorithm, _ := cryptoLib.DefaultRegistry.Get("ED25519")
+	keyPair, err := algorithm.Generate(algorithm.DefaultParameters)
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	publicKey, privateKey, err := algorithm.Marshaler.Marshal(*keyPair)
+	if err != nil {
+		t.Fatalf("Failed to marshal key pair: %v", err)
+	}
+
+	sealed, err := sealer.Seal(privateKey, publicKey)
+	if err != nil {
+		t.Fatalf("Failed to seal private key: %v", err)
+	}
+	ciphertext := sealed.Encode()
+
+	sealer.Rotate(newKEK)
+	changed, err := sealer.Rewrap(sealed)
+	if err != nil || !changed {
+		t.Fatalf("Expected the key to be re-wrapped, got %v (%v)", changed, err)
+	}
+	if sealed.KeyID() != newKEK.ID {
+		t.Errorf("Expected key-encryption key %s, got %s", newKEK.ID, sealed.KeyID())
+	}
+
+	decoded, err := cryptoLib.DecodeSealedPrivateKey(sealed.Encode())
+	if err != nil {
+		t.Fatalf("Failed to decode sealed key: %v", err)
+	}
+
+	// Only the new key-encryption key is needed once the key was re-wrapped.
+	opened, err := cryptoLib.NewKeySealer(newKEK).Open(decoded)
+	if err != nil {
+		t.Fatalf("Failed to open re-wrapped key: %v", err)
+	}
+	if !bytes.Equal(opened, privateKey) {
+		t.Error("Opened private key does not match the sealed one")
+	}
+
+	stale, err := cryptoLib.DecodeSealedPrivateKey(ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to decode sealed key: %v", err)
+	}
+	if _, err := cryptoLib.NewKeySealer(newKEK).Open(stale); err == nil {
+		t.Error("Expected opening a key wrapped under an unknown key-encryption key to fail")
+	}
+	if _, err := cryptoLib.NewKeySealer(newTestKEK(t), oldKEK).Open(stale); err != nil {
+		t.Errorf("Expected the previous key-encryption key to open the key, got %v", err)
+	}
+}
+
+func TestParseKeyEncryptionKey(t *testing.T) {
+	if _, err := cryptoLib.ParseKeyEncryptionKey("c2hvcnQ="); err == nil {
+		t.Error("Expected a short key-encryption key to be rejected")
+	}
+
+	kek, err := cryptoLib.ParseKeyEncryptionKey(" MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n")
+	if err != nil {
+		t.Fatalf("Failed to parse key-encryption key: %v", err)
+	}
+	if len(kek.ID) != 16 {
+		t.Errorf("Expected a 16 character key ID, got %q", kek.ID)
+	}
+}
@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"fmt"
+	"slices"
+)
+
+// Parameters tune the keys and signatures of a signature device.
+// An algorithm supports a parameter if and only if its default parameters set it.
+type Parameters struct {
+	RSABits int
+	Curve   string
+	Hash    string
+	// SaltLength is the RSA-PSS salt length in bytes.
+	SaltLength int
+	// SignatureEncoding is the ECDSA signature encoding, SignatureEncodingDER or SignatureEncodingP1363.
+	SignatureEncoding string
+}
+
+// Policy restricts the parameters devices may be created with.
+type Policy struct {
+	RSABits            []int
+	Curves             []string
+	Hashes             []string
+	SignatureEncodings []string
+}
+
+// DefaultPolicy only admits key sizes, curves and hash functions considered secure for production use.
+var DefaultPolicy = Policy{
+	RSABits:            []int{2048, 3072, 4096},
+	Curves:             []string{"P-256", "P-384", "P-521"},
+	Hashes:             []string{"SHA-256", "SHA-384", "SHA-512"},
+	SignatureEncodings: []string{SignatureEncodingDER, SignatureEncodingP1363},
+}
+
+// Resolve fills the unset fields of the requested parameters with the algorithm's defaults
+// and checks the result against the policy.
+func (p Policy) Resolve(algorithm *Algorithm, requested Parameters) (Parameters, error) {
+	resolved := algorithm.DefaultParameters
+
+	if requested.RSABits != 0 {
+		if resolved.RSABits == 0 {
+			return Parameters{}, fmt.Errorf("rsa_bits is not supported by algorithm %s", algorithm.Name)
+		}
+		resolved.RSABits = requested.RSABits
+	}
+	if requested.Curve != "" {
+		if resolved.Curve == "" {
+			return Parameters{}, fmt.Errorf("curve is not supported by algorithm %s", algorithm.Name)
+		}
+		resolved.Curve = requested.Curve
+	}
+	if requested.Hash != "" {
+		if resolved.Hash == "" {
+			return Parameters{}, fmt.Errorf("hash is not supported by algorithm %s", algorithm.Name)
+		}
+		resolved.Hash = requested.Hash
+	}
+	if requested.SignatureEncoding != "" {
+		if resolved.SignatureEncoding == "" {
+			return Parameters{}, fmt.Errorf("signature_encoding is not supported by algorithm %s", algorithm.Name)
+		}
+		resolved.SignatureEncoding = requested.SignatureEncoding
+	}
+	if requested.SaltLength != 0 {
+		if resolved.SaltLength == 0 {
+			return Parameters{}, fmt.Errorf("salt_length is not supported by algorithm %s", algorithm.Name)
+		}
+		resolved.SaltLength = requested.SaltLength
+	}
+
+	if resolved.RSABits != 0 && !slices.Contains(p.RSABits, resolved.RSABits) {
+		return Parameters{}, fmt.Errorf("rsa_bits %d is not allowed, must be one of %v", resolved.RSABits, p.RSABits)
+	}
+	if resolved.Curve != "" && !slices.Contains(p.Curves, resolved.Curve) {
+		return Parameters{}, fmt.Errorf("curve %s is not allowed, must be one of %v", resolved.Curve, p.Curves)
+	}
+	if resolved.Hash != "" && !slices.Contains(p.Hashes, resolved.Hash) {
+		return Parameters{}, fmt.Errorf("hash %s is not allowed, must be one of %v", resolved.Hash, p.Hashes)
+	}
+	if resolved.SignatureEncoding != "" && !slices.Contains(p.SignatureEncodings, resolved.SignatureEncoding) {
+		return Parameters{}, fmt.Errorf(
+			"signature_encoding %s is not allowed, must be one of %v",
+			resolved.SignatureEncoding, p.SignatureEncodings,
+		)
+	}
+	if resolved.SaltLength != 0 {
+		// The salt has to fit into the encoded message next to the hash, see RFC 8017 section 9.1.1.
+		hash, err := ParseHash(resolved.Hash)
+		if err != nil {
+			return Parameters{}, err
+		}
+		maxSaltLength := (resolved.RSABits+7)/8 - hash.Size() - 2
+		if resolved.SaltLength < 1 || resolved.SaltLength > maxSaltLength {
+			return Parameters{}, fmt.Errorf("salt_length %d is not allowed, must be between 1 and %d", resolved.SaltLength, maxSaltLength)
+		}
+	}
+
+	return resolved, nil
+}
+
+// ParseHash maps a hash function name to its crypto.Hash. An empty name selects SHA-256.
+func ParseHash(name string) (crypto.Hash, error) {
+	switch name {
+	case "", "SHA-256":
+		return crypto.SHA256, nil
+	case "SHA-384":
+		return crypto.SHA384, nil
+	case "SHA-512":
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported hash function %s", name)
+	}
+}
+
+// ParseCurve maps a NIST curve name to its elliptic.Curve. An empty name selects P-384.
+func ParseCurve(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "", "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported curve %s", name)
+	}
+}
+
+// digest hashes data with the given hash function, defaulting to SHA-256.
+func digest(hash crypto.Hash, data []byte) (crypto.Hash, []byte, error) {
+	if hash == 0 {
+		hash = crypto.SHA256
+	}
+	if !hash.Available() {
+		return 0, nil, fmt.Errorf("hash function %s is not available", hash)
+	}
+
+	h := hash.New()
+	h.Write(data)
+	return hash, h.Sum(nil), nil
+}
@@ -0,0 +1,184 @@
+package crypto_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	cryptoLib "github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"testing"
+)
+
+// TestPolicyResolve tests defaulting and validation of device parameters.
+func TestPolicyResolve(t *testing.T) {
+	testCases := []struct {
+		name        string
+		algorithm   string
+		requested   cryptoLib.Parameters
+		expected    cryptoLib.Parameters
+		expectError bool
+	}{
+		{
+			name:      "RSA Defaults",
+			algorithm: "RSA",
+			expected:  cryptoLib.Parameters{RSABits: 2048, Hash: "SHA-256"},
+		},
+		{
+			name:      "RSA Custom",
+			algorithm: "RSA",
+			requested: cryptoLib.Parameters{RSABits: 4096, Hash: "SHA-512"},
+			expected:  cryptoLib.Parameters{RSABits: 4096, Hash: "SHA-512"},
+		},
+		{
+			name:        "RSA Weak Key",
+			algorithm:   "RSA",
+			requested:   cryptoLib.Parameters{RSABits: 512},
+			expectError: true,
+		},
+		{
+			name:        "RSA Curve",
+			algorithm:   "RSA",
+			requested:   cryptoLib.Parameters{Curve: "P-256"},
+			expectError: true,
+		},
+		{
+			name:      "RSA-PSS Defaults",
+			algorithm: "RSA-PSS",
+			expected:  cryptoLib.Parameters{RSABits: 2048, Hash: "SHA-256", SaltLength: 32},
+		},
+		{
+			name:      "RSA-PSS Maximum Salt",
+			algorithm: "RSA-PSS",
+			requested: cryptoLib.Parameters{SaltLength: 222},
+			expected:  cryptoLib.Parameters{RSABits: 2048, Hash: "SHA-256", SaltLength: 222},
+		},
+		{
+			name:        "RSA-PSS Oversized Salt",
+			algorithm:   "RSA-PSS",
+			requested:   cryptoLib.Parameters{SaltLength: 223},
+			expectError: true,
+		},
+		{
+			name:        "RSA Salt",
+			algorithm:   "RSA",
+			requested:   cryptoLib.Parameters{SaltLength: 32},
+			expectError: true,
+		},
+		{
+			name:      "ECC Defaults",
+			algorithm: "ECC",
+			expected:  cryptoLib.Parameters{Curve: "P-384", Hash: "SHA-256", SignatureEncoding: "DER"},
+		},
+		{
+			name:      "ECC Custom",
+			algorithm: "ECC",
+			requested: cryptoLib.Parameters{Curve: "P-521", Hash: "SHA-512", SignatureEncoding: "P1363"},
+			expected:  cryptoLib.Parameters{Curve: "P-521", Hash: "SHA-512", SignatureEncoding: "P1363"},
+		},
+		{
+			name:        "ECC Unknown Curve",
+			algorithm:   "ECC",
+			requested:   cryptoLib.Parameters{Curve: "secp256k1"},
+			expectError: true,
+		},
+		{
+			name:        "ECC Unknown Encoding",
+			algorithm:   "ECC",
+			requested:   cryptoLib.Parameters{SignatureEncoding: "BER"},
+			expectError: true,
+		},
+		{
+			name:        "RSA Encoding",
+			algorithm:   "RSA",
+			requested:   cryptoLib.Parameters{SignatureEncoding: "P1363"},
+			expectError: true,
+		},
+		{
+			name:        "ECC Unknown Hash",
+			algorithm:   "ECC",
+			requested:   cryptoLib.Parameters{Hash: "MD5"},
+			expectError: true,
+		},
+		{
+			name:      "Ed25519 Defaults",
+			algorithm: "ED25519",
+			expected:  cryptoLib.Parameters{},
+		},
+		{
+			name:        "Ed25519 Hash",
+			algorithm:   "ED25519",
+			requested:   cryptoLib.Parameters{Hash: "SHA-512"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			algorithm, _ := cryptoLib.DefaultRegistry.Get(tc.algorithm)
+
+			resolved, err := cryptoLib.DefaultPolicy.Resolve(algorithm, tc.requested)
+			if tc.expectError {
+				if err == nil {
+					t.Fatalf("Expected an error, got parameters %+v", resolved)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resolved != tc.expected {
+				t.Fatalf("Expected parameters %+v, got %+v", tc.expected, resolved)
+			}
+		})
+	}
+}
+
+// TestSignersWithHash tests that signers and verifiers honour the configured hash function.
+func TestSignersWithHash(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	eccKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECC key: %v", err)
+	}
+
+	testCases := []struct {
+		name       string
+		signer     cryptoLib.Signer
+		verifier   cryptoLib.Verifier
+		mismatched cryptoLib.Verifier
+	}{
+		{
+			name:       "RSA SHA-512",
+			signer:     &cryptoLib.RSASigner{PrivateKey: rsaKey, Hash: crypto.SHA512},
+			verifier:   &cryptoLib.RSAVerifier{PublicKey: &rsaKey.PublicKey, Hash: crypto.SHA512},
+			mismatched: &cryptoLib.RSAVerifier{PublicKey: &rsaKey.PublicKey},
+		},
+		{
+			name:       "ECC SHA-384",
+			signer:     &cryptoLib.ECCSigner{PrivateKey: eccKey, Hash: crypto.SHA384},
+			verifier:   &cryptoLib.ECCVerifier{PublicKey: &eccKey.PublicKey, Hash: crypto.SHA384},
+			mismatched: &cryptoLib.ECCVerifier{PublicKey: &eccKey.PublicKey},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := []byte("Test data for " + tc.name)
+			signature, err := tc.signer.Sign(data)
+			if err != nil {
+				t.Fatalf("Failed to sign data: %v", err)
+			}
+
+			if valid, err := tc.verifier.Verify(data, signature); err != nil || !valid {
+				t.Fatalf("Expected signature to verify, got %t, %v", valid, err)
+			}
+			if valid, _ := tc.mismatched.Verify(data, signature); valid {
+				t.Fatalf("Expected signature not to verify with a different hash function")
+			}
+		})
+	}
+}
@@ -0,0 +1,522 @@
+//go:build pkcs11
+
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+const pkcs11KeyReferencePEMType = "PKCS11_KEY_REFERENCE"
+
+// PKCS11Token is a logged-in session on a PKCS#11 token, e.g. a smart card, an HSM or SoftHSM.
+// PKCS#11 sessions must not be used concurrently, so all operations are serialized.
+type PKCS11Token struct {
+	mu      sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+}
+
+// OpenPKCS11Token loads the PKCS#11 module at modulePath and logs into the token with the given label.
+func OpenPKCS11Token(modulePath, tokenLabel, pin string) (*PKCS11Token, error) {
+	ctx := pkcs11.New(modulePath)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %s", modulePath)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module: %w", err)
+	}
+
+	token, err := openPKCS11Session(ctx, tokenLabel, pin)
+	if err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+	return token, nil
+}
+
+func openPKCS11Session(ctx *pkcs11.Ctx, tokenLabel, pin string) (*PKCS11Token, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PKCS#11 slots: %w", err)
+	}
+
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil || info.Label != tokenLabel {
+			continue
+		}
+
+		session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open PKCS#11 session: %w", err)
+		}
+		if err := ctx.Login(session, pkcs11.CKU_USER, pin); err != nil {
+			ctx.CloseSession(session)
+			return nil, fmt.Errorf("failed to log into PKCS#11 token %s: %w", tokenLabel, err)
+		}
+		return &PKCS11Token{ctx: ctx, session: session}, nil
+	}
+
+	return nil, fmt.Errorf("PKCS#11 token %s not found", tokenLabel)
+}
+
+// Close logs out and unloads the PKCS#11 module.
+func (t *PKCS11Token) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.ctx.Logout(t.session)
+	t.ctx.CloseSession(t.session)
+	err := t.ctx.Finalize()
+	t.ctx.Destroy()
+	return err
+}
+
+// PKCS11PrivateKey references a private key that never leaves its PKCS#11 token.
+type PKCS11PrivateKey struct {
+	Token *PKCS11Token
+	// ID is the CKA_ID shared by the private and the public key object.
+	ID        []byte
+	PublicKey interface{}
+}
+
+// RegisterPKCS11Algorithms adds "RSA-PKCS11" and "ECC-PKCS11" to the registry. Devices created with
+// them generate and keep their private keys on the token; only signing is delegated to it, while
+// signatures are verified in software with the exported public key.
+func RegisterPKCS11Algorithms(registry *Registry, token *PKCS11Token) error {
+	marshaler := &pkcs11KeyMarshaler{token: token}
+
+	err := registry.Register(Algorithm{
+		Name: "RSA-PKCS11",
+		Metadata: AlgorithmMetadata{
+			Description:     "RSA signatures with keys on a PKCS#11 token",
+			KeyType:         "RSA",
+			SignatureScheme: "PKCS#1 v1.5",
+		},
+		DefaultParameters: Parameters{RSABits: DefaultRSABits, Hash: "SHA-256"},
+		Generate: func(parameters Parameters) (*KeyPair, error) {
+			return token.generateRSA(parameters.RSABits)
+		},
+		NewSigner: func(privateKey interface{}, parameters Parameters) (Signer, error) {
+			key, ok := privateKey.(*PKCS11PrivateKey)
+			if !ok {
+				return nil, keyTypeError("RSA-PKCS11", privateKey)
+			}
+			hash, err := ParseHash(parameters.Hash)
+			if err != nil {
+				return nil, err
+			}
+			return &PKCS11RSASigner{Key: key, Hash: hash}, nil
+		},
+		NewVerifier: func(publicKey interface{}, parameters Parameters) (Verifier, error) {
+			key, ok := publicKey.(*rsa.PublicKey)
+			if !ok {
+				return nil, keyTypeError("RSA-PKCS11", publicKey)
+			}
+			hash, err := ParseHash(parameters.Hash)
+			if err != nil {
+				return nil, err
+			}
+			return &RSAVerifier{PublicKey: key, Hash: hash}, nil
+		},
+		Marshaler: marshaler,
+		JWA: func(parameters Parameters) string {
+			return jwaByHash("RS", parameters.Hash)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return registry.Register(Algorithm{
+		Name: "ECC-PKCS11",
+		Metadata: AlgorithmMetadata{
+			Description:     "Elliptic curve signatures with keys on a PKCS#11 token",
+			KeyType:         "EC",
+			SignatureScheme: "ECDSA",
+		},
+		DefaultParameters: Parameters{Curve: "P-256", Hash: "SHA-256", SignatureEncoding: SignatureEncodingDER},
+		Generate: func(parameters Parameters) (*KeyPair, error) {
+			curve, err := ParseCurve(parameters.Curve)
+			if err != nil {
+				return nil, err
+			}
+			return token.generateECC(curve)
+		},
+		NewSigner: func(privateKey interface{}, parameters Parameters) (Signer, error) {
+			key, ok := privateKey.(*PKCS11PrivateKey)
+			if !ok {
+				return nil, keyTypeError("ECC-PKCS11", privateKey)
+			}
+			hash, err := ParseHash(parameters.Hash)
+			if err != nil {
+				return nil, err
+			}
+			return &PKCS11ECCSigner{Key: key, Hash: hash, Encoding: parameters.SignatureEncoding}, nil
+		},
+		NewVerifier: func(publicKey interface{}, parameters Parameters) (Verifier, error) {
+			key, ok := publicKey.(*ecdsa.PublicKey)
+			if !ok {
+				return nil, keyTypeError("ECC-PKCS11", publicKey)
+			}
+			hash, err := ParseHash(parameters.Hash)
+			if err != nil {
+				return nil, err
+			}
+			return &ECCVerifier{PublicKey: key, Hash: hash, Encoding: parameters.SignatureEncoding}, nil
+		},
+		Marshaler: marshaler,
+	})
+}
+
+// PKCS11RSASigner signs with an RSA key on a PKCS#11 token using CKM_RSA_PKCS.
+type PKCS11RSASigner struct {
+	Key *PKCS11PrivateKey
+	// Hash is applied to the data before signing, SHA-256 if zero.
+	Hash crypto.Hash
+}
+
+func (s *PKCS11RSASigner) Sign(dataToBeSigned []byte) ([]byte, error) {
+	hash, hashed, err := digest(s.Hash, dataToBeSigned)
+	if err != nil {
+		return nil, err
+	}
+	prefix, ok := pkcs1DigestInfoPrefixes[hash]
+	if !ok {
+		return nil, fmt.Errorf("hash function %s is not supported for PKCS#11 RSA signatures", hash)
+	}
+
+	return s.Key.Token.sign(s.Key.ID, pkcs11.CKM_RSA_PKCS, append(bytes.Clone(prefix), hashed...))
+}
+
+// PKCS11ECCSigner signs with an EC key on a PKCS#11 token using CKM_ECDSA.
+type PKCS11ECCSigner struct {
+	Key *PKCS11PrivateKey
+	// Hash is applied to the data before signing, SHA-256 if zero.
+	Hash crypto.Hash
+	// Encoding is the signature encoding, SignatureEncodingDER if empty.
+	Encoding string
+}
+
+func (s *PKCS11ECCSigner) Sign(dataToBeSigned []byte) ([]byte, error) {
+	publicKey, ok := s.Key.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, keyTypeError("ECC-PKCS11", s.Key.PublicKey)
+	}
+	_, hashed, err := digest(s.Hash, dataToBeSigned)
+	if err != nil {
+		return nil, err
+	}
+
+	// CKM_ECDSA returns r||s.
+	signature, err := s.Key.Token.sign(s.Key.ID, pkcs11.CKM_ECDSA, hashed)
+	if err != nil {
+		return nil, err
+	}
+	size := len(signature) / 2
+	r := new(big.Int).SetBytes(signature[:size])
+	sValue := new(big.Int).SetBytes(signature[size:])
+	return encodeECDSASignature(publicKey, s.Encoding, r, sValue)
+}
+
+// pkcs1DigestInfoPrefixes are the DER encoded DigestInfo headers CKM_RSA_PKCS expects before the digest.
+var pkcs1DigestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// pkcs11CurveOIDs are the named curve OIDs used as CKA_EC_PARAMS.
+var pkcs11CurveOIDs = map[elliptic.Curve]asn1.ObjectIdentifier{
+	elliptic.P256(): {1, 2, 840, 10045, 3, 1, 7},
+	elliptic.P384(): {1, 3, 132, 0, 34},
+	elliptic.P521(): {1, 3, 132, 0, 35},
+}
+
+func (t *PKCS11Token) generateRSA(bits int) (*KeyPair, error) {
+	if bits == 0 {
+		bits = DefaultRSABits
+	}
+	id, err := newPKCS11KeyID()
+	if err != nil {
+		return nil, err
+	}
+
+	publicTemplate := append(pkcs11KeyTemplate(pkcs11.CKO_PUBLIC_KEY, pkcs11.CKK_RSA, id),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, bits),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{0x01, 0x00, 0x01}),
+	)
+	privateTemplate := append(pkcs11KeyTemplate(pkcs11.CKO_PRIVATE_KEY, pkcs11.CKK_RSA, id), pkcs11PrivateKeyAttributes()...)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	publicHandle, _, err := t.ctx.GenerateKeyPair(t.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil)},
+		publicTemplate, privateTemplate,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate RSA key pair on token: %w", err)
+	}
+
+	publicKey, err := t.readRSAPublicKey(publicHandle)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{Public: publicKey, Private: &PKCS11PrivateKey{Token: t, ID: id, PublicKey: publicKey}}, nil
+}
+
+func (t *PKCS11Token) generateECC(curve elliptic.Curve) (*KeyPair, error) {
+	oid, ok := pkcs11CurveOIDs[curve]
+	if !ok {
+		return nil, fmt.Errorf("curve %s is not supported on PKCS#11 tokens", curve.Params().Name)
+	}
+	ecParams, err := asn1.Marshal(oid)
+	if err != nil {
+		return nil, err
+	}
+	id, err := newPKCS11KeyID()
+	if err != nil {
+		return nil, err
+	}
+
+	publicTemplate := append(pkcs11KeyTemplate(pkcs11.CKO_PUBLIC_KEY, pkcs11.CKK_EC, id),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, ecParams),
+	)
+	privateTemplate := append(pkcs11KeyTemplate(pkcs11.CKO_PRIVATE_KEY, pkcs11.CKK_EC, id), pkcs11PrivateKeyAttributes()...)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	publicHandle, _, err := t.ctx.GenerateKeyPair(t.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		publicTemplate, privateTemplate,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate EC key pair on token: %w", err)
+	}
+
+	publicKey, err := t.readECCPublicKey(publicHandle, curve)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{Public: publicKey, Private: &PKCS11PrivateKey{Token: t, ID: id, PublicKey: publicKey}}, nil
+}
+
+// sign signs input with the private key identified by id.
+func (t *PKCS11Token) sign(id []byte, mechanism uint, input []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	handle, err := t.findKey(pkcs11.CKO_PRIVATE_KEY, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.ctx.SignInit(t.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, handle); err != nil {
+		return nil, fmt.Errorf("failed to initialize signing on token: %w", err)
+	}
+	signature, err := t.ctx.Sign(t.session, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign on token: %w", err)
+	}
+	return signature, nil
+}
+
+// findKey looks up a key object by class and CKA_ID. The caller must hold t.mu.
+func (t *PKCS11Token) findKey(class uint, id []byte) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+	}
+	if err := t.ctx.FindObjectsInit(t.session, template); err != nil {
+		return 0, err
+	}
+	handles, _, err := t.ctx.FindObjects(t.session, 1)
+	finalErr := t.ctx.FindObjectsFinal(t.session)
+	if err != nil {
+		return 0, err
+	}
+	if finalErr != nil {
+		return 0, finalErr
+	}
+	if len(handles) == 0 {
+		return 0, fmt.Errorf("key %s not found on token", hex.EncodeToString(id))
+	}
+	return handles[0], nil
+}
+
+// loadPublicKey reads the public key with the given CKA_ID from the token.
+func (t *PKCS11Token) loadPublicKey(id []byte) (interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	handle, err := t.findKey(pkcs11.CKO_PUBLIC_KEY, id)
+	if err != nil {
+		return nil, err
+	}
+	attributes, err := t.ctx.GetAttributeValue(t.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key type from token: %w", err)
+	}
+	// CK_ULONG values are returned in native byte order, so compare with an encoded value.
+	if bytes.Equal(attributes[0].Value, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA).Value) {
+		return t.readRSAPublicKey(handle)
+	}
+
+	attributes, err = t.ctx.GetAttributeValue(t.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read EC parameters from token: %w", err)
+	}
+
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(attributes[0].Value, &oid); err != nil {
+		return nil, fmt.Errorf("invalid EC parameters on token: %w", err)
+	}
+	for curve, curveOID := range pkcs11CurveOIDs {
+		if curveOID.Equal(oid) {
+			return t.readECCPublicKey(handle, curve)
+		}
+	}
+	return nil, fmt.Errorf("unsupported curve %s on token", oid)
+}
+
+// readRSAPublicKey reads an RSA public key object. The caller must hold t.mu.
+func (t *PKCS11Token) readRSAPublicKey(handle pkcs11.ObjectHandle) (*rsa.PublicKey, error) {
+	attributes, err := t.ctx.GetAttributeValue(t.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RSA public key from token: %w", err)
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(attributes[0].Value),
+		E: int(new(big.Int).SetBytes(attributes[1].Value).Int64()),
+	}, nil
+}
+
+// readECCPublicKey reads an EC public key object. The caller must hold t.mu.
+func (t *PKCS11Token) readECCPublicKey(handle pkcs11.ObjectHandle, curve elliptic.Curve) (*ecdsa.PublicKey, error) {
+	attributes, err := t.ctx.GetAttributeValue(t.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read EC public key from token: %w", err)
+	}
+
+	// CKA_EC_POINT is a DER OCTET STRING holding the uncompressed point.
+	var point []byte
+	if _, err := asn1.Unmarshal(attributes[0].Value, &point); err != nil {
+		return nil, fmt.Errorf("invalid EC point on token: %w", err)
+	}
+	x, y := elliptic.Unmarshal(curve, point)
+	if x == nil {
+		return nil, errors.New("invalid EC point on token")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// pkcs11KeyMarshaler stores a reference to the key on the token instead of the key itself.
+type pkcs11KeyMarshaler struct {
+	token *PKCS11Token
+}
+
+func (m *pkcs11KeyMarshaler) Marshal(keyPair KeyPair) ([]byte, []byte, error) {
+	key, ok := keyPair.Private.(*PKCS11PrivateKey)
+	if !ok {
+		return nil, nil, keyTypeError("PKCS#11", keyPair.Private)
+	}
+
+	// The public key is encoded like the RSAMarshaler and ECCMarshaler do.
+	var publicKey []byte
+	switch public := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		publicKey = pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA_PUBLIC_KEY",
+			Bytes: x509.MarshalPKCS1PublicKey(public),
+		})
+	case *ecdsa.PublicKey:
+		publicKeyBytes, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return nil, nil, err
+		}
+		publicKey = pem.EncodeToMemory(&pem.Block{
+			Type:  "PUBLIC_KEY",
+			Bytes: publicKeyBytes,
+		})
+	default:
+		return nil, nil, keyTypeError("PKCS#11", key.PublicKey)
+	}
+
+	reference := pem.EncodeToMemory(&pem.Block{
+		Type:  pkcs11KeyReferencePEMType,
+		Bytes: key.ID,
+	})
+	return publicKey, reference, nil
+}
+
+func (m *pkcs11KeyMarshaler) Unmarshal(privateKeyBytes []byte) (*KeyPair, error) {
+	block, _ := pem.Decode(privateKeyBytes)
+	if block == nil || block.Type != pkcs11KeyReferencePEMType {
+		return nil, errors.New("failed to decode PKCS#11 key reference")
+	}
+
+	publicKey, err := m.token.loadPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{
+		Public:  publicKey,
+		Private: &PKCS11PrivateKey{Token: m.token, ID: block.Bytes, PublicKey: publicKey},
+	}, nil
+}
+
+func pkcs11KeyTemplate(class, keyType uint, id []byte) []*pkcs11.Attribute {
+	return []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+	}
+}
+
+// pkcs11PrivateKeyAttributes keep private keys on the token.
+func pkcs11PrivateKeyAttributes() []*pkcs11.Attribute {
+	return []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+	}
+}
+
+func newPKCS11KeyID() ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
@@ -0,0 +1,88 @@
+//go:build pkcs11
+
+package crypto_test
+
+import (
+	"os"
+	"testing"
+
+	cryptoLib "github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+// The PKCS#11 tests run against SoftHSM, e.g.
+//
+//	softhsm2-util --init-token --free --label signing-test --pin 1234 --so-pin 0000
+//	PKCS11_MODULE=/usr/lib/softhsm/libsofthsm2.so PKCS11_TOKEN=signing-test PKCS11_PIN=1234 \
+//		go test -tags pkcs11 ./crypto/
+func openTestToken(t *testing.T) *cryptoLib.PKCS11Token {
+	module := os.Getenv("PKCS11_MODULE")
+	if module == "" {
+		t.Skip("PKCS11_MODULE is not set")
+	}
+
+	token, err := cryptoLib.OpenPKCS11Token(module, os.Getenv("PKCS11_TOKEN"), os.Getenv("PKCS11_PIN"))
+	if err != nil {
+		t.Fatalf("Failed to open PKCS#11 token: %v", err)
+	}
+	t.Cleanup(func() { token.Close() })
+	return token
+}
+
+func TestPKCS11Algorithms(t *testing.T) {
+	registry := cryptoLib.NewRegistry()
+	if err := cryptoLib.RegisterPKCS11Algorithms(registry, openTestToken(t)); err != nil {
+		t.Fatalf("Failed to register PKCS#11 algorithms: %v", err)
+	}
+
+	tests := []struct {
+		algorithm  string
+		parameters cryptoLib.Parameters
+	}{
+		{"RSA-PKCS11", cryptoLib.Parameters{RSABits: 2048, Hash: "SHA-256"}},
+		{"RSA-PKCS11", cryptoLib.Parameters{RSABits: 2048, Hash: "SHA-512"}},
+		{"ECC-PKCS11", cryptoLib.Parameters{Curve: "P-256", Hash: "SHA-256", SignatureEncoding: cryptoLib.SignatureEncodingDER}},
+		{"ECC-PKCS11", cryptoLib.Parameters{Curve: "P-384", Hash: "SHA-384", SignatureEncoding: cryptoLib.SignatureEncodingP1363}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm+"/"+tt.parameters.Hash, func(t *testing.T) {
+			algorithm, exists := registry.Get(tt.algorithm)
+			if !exists {
+				t.Fatalf("Expected algorithm %s to be registered", tt.algorithm)
+			}
+
+			keyPair, err := algorithm.Generate(tt.parameters)
+			if err != nil {
+				t.Fatalf("Failed to generate key pair on token: %v", err)
+			}
+
+			// Restore the key from its stored reference, as a repository would.
+			_, reference, err := algorithm.Marshaler.Marshal(*keyPair)
+			if err != nil {
+				t.Fatalf("Failed to marshal key reference: %v", err)
+			}
+			restored, err := algorithm.Marshaler.Unmarshal(reference)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal key reference: %v", err)
+			}
+
+			signer, err := algorithm.NewSigner(restored.Private, tt.parameters)
+			if err != nil {
+				t.Fatalf("Failed to create signer: %v", err)
+			}
+			verifier, err := algorithm.NewVerifier(keyPair.Public, tt.parameters)
+			if err != nil {
+				t.Fatalf("Failed to create verifier: %v", err)
+			}
+
+			signature, err := signer.Sign([]byte("data"))
+			if err != nil {
+				t.Fatalf("Failed to sign on token: %v", err)
+			}
+			valid, err := verifier.Verify([]byte("data"), signature)
+			if err != nil || !valid {
+				t.Fatalf("Expected token signature to verify in software, got %v (%v)", valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+)
+
+// JWK is the RFC 7517 JSON Web Key representation of a public key.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	// RSA public key parameters.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Elliptic curve and octet key pair public key parameters.
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKSet is the RFC 7517 JSON Web Key Set document.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// DecodePEM returns the DER bytes wrapped by a PEM encoded key.
+func DecodePEM(encoded []byte) ([]byte, error) {
+	block, _ := pem.Decode(encoded)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block")
+	}
+	return block.Bytes, nil
+}
+
+// EncodePublicKeyPEM encodes a public key as a PKIX "PUBLIC KEY" PEM block, which ParsePublicKeyPEM reads back.
+func EncodePublicKeyPEM(publicKey interface{}) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// EncodePublicKeyJWK converts a public key into its JWK representation identified by keyID.
+// The "alg" member is left to the caller, as a key type may be used by several algorithms.
+func EncodePublicKeyJWK(publicKey interface{}, keyID string) (*JWK, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return &JWK{
+			KeyType: "RSA",
+			KeyID:   keyID,
+			Use:     "sig",
+			N:       base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		// Coordinates are left-padded to the full size of the curve as required by RFC 7518.
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return &JWK{
+			KeyType: "EC",
+			KeyID:   keyID,
+			Use:     "sig",
+			Curve:   key.Curve.Params().Name,
+			X:       base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+			Y:       base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return &JWK{
+			KeyType: "OKP",
+			KeyID:   keyID,
+			Use:     "sig",
+			Curve:   "Ed25519",
+			X:       base64.RawURLEncoding.EncodeToString(key),
+		}, nil
+	default:
+		return nil, errors.New("unsupported public key type")
+	}
+}
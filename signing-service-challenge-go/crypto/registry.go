@@ -0,0 +1,135 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// KeyPair is an algorithm-agnostic DTO that holds a private and a public key.
+type KeyPair struct {
+	Public  interface{}
+	Private interface{}
+}
+
+// KeyMarshaler can encode and decode the key pair of a specific algorithm.
+type KeyMarshaler interface {
+	// Marshal encodes a key pair to be written on disk.
+	// It returns the public and the private key as a byte slice.
+	Marshal(keyPair KeyPair) ([]byte, []byte, error)
+	// Unmarshal assembles a key pair from an encoded private key.
+	Unmarshal(privateKeyBytes []byte) (*KeyPair, error)
+}
+
+// AlgorithmMetadata describes a signature algorithm to API clients.
+type AlgorithmMetadata struct {
+	Description     string
+	KeyType         string
+	SignatureScheme string
+}
+
+// Algorithm bundles everything needed to create and operate signature devices of one algorithm.
+type Algorithm struct {
+	Name              string
+	Metadata          AlgorithmMetadata
+	DefaultParameters Parameters
+	Generate          func(parameters Parameters) (*KeyPair, error)
+	NewSigner         func(privateKey interface{}, parameters Parameters) (Signer, error)
+	NewVerifier       func(publicKey interface{}, parameters Parameters) (Verifier, error)
+	Marshaler         KeyMarshaler
+	// JWA returns the RFC 7518 "alg" value for the given parameters, if there is one. Optional.
+	JWA func(parameters Parameters) string
+}
+
+// Registry holds the signature algorithms that devices can be created with.
+// The first algorithm registered for a key type is the default of that type, which keys are
+// used with if no algorithm is named.
+type Registry struct {
+	mu         sync.RWMutex
+	algorithms map[string]*Algorithm
+	defaults   map[string]*Algorithm
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		algorithms: make(map[string]*Algorithm),
+		defaults:   make(map[string]*Algorithm),
+	}
+}
+
+// DefaultRegistry contains all algorithms built into this package.
+var DefaultRegistry = NewRegistry()
+
+// Register adds an algorithm to the registry under its name.
+func (r *Registry) Register(algorithm Algorithm) error {
+	if algorithm.Name == "" {
+		return errors.New("algorithm name must not be empty")
+	}
+	if algorithm.Generate == nil || algorithm.NewSigner == nil ||
+		algorithm.NewVerifier == nil || algorithm.Marshaler == nil {
+		return fmt.Errorf("algorithm %s is incomplete", algorithm.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.algorithms[algorithm.Name]; exists {
+		return fmt.Errorf("algorithm %s is already registered", algorithm.Name)
+	}
+
+	r.algorithms[algorithm.Name] = &algorithm
+	if _, exists := r.defaults[algorithm.Metadata.KeyType]; !exists {
+		r.defaults[algorithm.Metadata.KeyType] = &algorithm
+	}
+	return nil
+}
+
+// MustRegister adds an algorithm to the registry and panics if that fails.
+func (r *Registry) MustRegister(algorithm Algorithm) {
+	if err := r.Register(algorithm); err != nil {
+		panic(err)
+	}
+}
+
+// Get looks up an algorithm by its name.
+func (r *Registry) Get(name string) (*Algorithm, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	algorithm, exists := r.algorithms[name]
+	return algorithm, exists
+}
+
+// List returns all registered algorithms ordered by name.
+func (r *Registry) List() []*Algorithm {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	algorithms := make([]*Algorithm, 0, len(r.algorithms))
+	for _, algorithm := range r.algorithms {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Slice(algorithms, func(i, j int) bool {
+		return algorithms[i].Name < algorithms[j].Name
+	})
+
+	return algorithms
+}
+
+// Defaults returns the default algorithm of every key type ordered by name.
+func (r *Registry) Defaults() []*Algorithm {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	algorithms := make([]*Algorithm, 0, len(r.defaults))
+	for _, algorithm := range r.defaults {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Slice(algorithms, func(i, j int) bool {
+		return algorithms[i].Name < algorithms[j].Name
+	})
+
+	return algorithms
+}
@@ -0,0 +1,87 @@
+package crypto_test
+
+import (
+	cryptoLib "github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"testing"
+)
+
+// TestDefaultRegistry tests that every built-in algorithm can generate, sign, verify and marshal.
+func TestDefaultRegistry(t *testing.T) {
+	for _, name := range []string{"RSA", "ECC", "ED25519"} {
+		t.Run(name, func(t *testing.T) {
+			algorithm, exists := cryptoLib.DefaultRegistry.Get(name)
+			if !exists {
+				t.Fatalf("Expected algorithm %s to be registered", name)
+			}
+
+			keyPair, err := algorithm.Generate(algorithm.DefaultParameters)
+			if err != nil {
+				t.Fatalf("Failed to generate key pair: %v", err)
+			}
+
+			signer, err := algorithm.NewSigner(keyPair.Private, algorithm.DefaultParameters)
+			if err != nil {
+				t.Fatalf("Failed to get signer: %v", err)
+			}
+			data := []byte("Test data for " + name)
+			signature, err := signer.Sign(data)
+			if err != nil {
+				t.Fatalf("Failed to sign data: %v", err)
+			}
+
+			_, encodedPrivate, err := algorithm.Marshaler.Marshal(*keyPair)
+			if err != nil {
+				t.Fatalf("Failed to marshal key pair: %v", err)
+			}
+			decoded, err := algorithm.Marshaler.Unmarshal(encodedPrivate)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal key pair: %v", err)
+			}
+
+			verifier, err := algorithm.NewVerifier(decoded.Public, algorithm.DefaultParameters)
+			if err != nil {
+				t.Fatalf("Failed to get verifier: %v", err)
+			}
+			valid, err := verifier.Verify(data, signature)
+			if err != nil || !valid {
+				t.Fatalf("Expected signature to verify with the unmarshaled public key, got %t, %v", valid, err)
+			}
+		})
+	}
+}
+
+// TestRegistryRegister tests registration rules of the Registry.
+func TestRegistryRegister(t *testing.T) {
+	rsa, _ := cryptoLib.DefaultRegistry.Get("RSA")
+	registry := cryptoLib.NewRegistry()
+
+	if err := registry.Register(*rsa); err != nil {
+		t.Fatalf("Failed to register algorithm: %v", err)
+	}
+	if err := registry.Register(*rsa); err == nil {
+		t.Fatalf("Expected duplicate registration to fail")
+	}
+	if err := registry.Register(cryptoLib.Algorithm{Name: "INCOMPLETE"}); err == nil {
+		t.Fatalf("Expected incomplete algorithm registration to fail")
+	}
+
+	alias := *rsa
+	alias.Name = "ALIAS"
+	if err := registry.Register(alias); err != nil {
+		t.Fatalf("Failed to register algorithm: %v", err)
+	}
+
+	algorithms := registry.List()
+	if len(algorithms) != 2 || algorithms[0].Name != "ALIAS" || algorithms[1].Name != "RSA" {
+		t.Fatalf("Expected algorithms ALIAS and RSA in order, got %d algorithms", len(algorithms))
+	}
+	if _, exists := registry.Get("ECC"); exists {
+		t.Fatalf("Expected ECC not to be registered")
+	}
+
+	// The algorithm registered first stays the default of its key type.
+	defaults := registry.Defaults()
+	if len(defaults) != 1 || defaults[0].Name != "RSA" {
+		t.Fatalf("Expected RSA as the only default algorithm, got %d algorithms", len(defaults))
+	}
+}
@@ -0,0 +1,122 @@
+package crypto_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	cryptoLib "github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"testing"
+)
+
+// rsaPSSVectorPublicKey is the public key of the RSA-PSS test vectors below.
+// The vectors were produced independently with OpenSSL:
+//
+//	openssl dgst -sha256 -sigopt rsa_padding_mode:pss -sigopt rsa_pss_saltlen:32 -sign key.pem
+const rsaPSSVectorPublicKey = `-----BEGIN RSA PUBLIC KEY-----
+MIIBCgKCAQEA2qsIqiUr2gkdxaWxo9QwIQVLTKGRtF3e3uQOWl9JJntzqW6UIDs/
+Tnmv+gm99Y8GE9GoZ4N7TIErYX7tKVo0tyqbVq91+zisfbetDEJy8zcAHcieJ6fs
+qMI3jnCUE5jD4M6hhOUL3OU7+UMhZBlnGL2fsWfCoqW+L9E0FgA6MMpvBk8q70SN
+4BJRPG3rp/VRq5uD0bvHZeAVoHmV8/4N0n1nYp/Q6eQN+ecmzWeVeeBw6oJqlg71
+Mec+uRkFLL/DKAPQPMPqwwba6AdC6MAQi4jWVcy1IfJg29G0Z5A5eSrgETVRV0FU
+zduYPAL6/6PJ7ORg3sxfopIAfTDDa8ZTpwIDAQAB
+-----END RSA PUBLIC KEY-----
+`
+
+var rsaPSSVectors = []struct {
+	name       string
+	hash       crypto.Hash
+	saltLength int
+	message    string
+	signature  string
+}{
+	{
+		name:       "SHA-256 Salt 32",
+		hash:       crypto.SHA256,
+		saltLength: 32,
+		message:    "0_receipt_ZGV2aWNl",
+		signature: "VF/O9gPduVrF8fauFXK3pRvSMCQx/k5ANvgim0UO6t2cnbGMO3YuT8ILh8TuKD9RlpvEfzJ42i2GZNl3v8WHZUrsVJ0pPl8toZh3h6WQ" +
+			"IVJOlk2bTOeEmkyJ1KzUzLb8gJJtd/KMKFfWzXZHr6a/udc/35jGgyaC9ktbnGPKSdmJoKalBu0VrlfXY6NM4aQQE/6AmmfNm89VDlhg" +
+			"4PvVBBXnVY+6bFgKknMHKrxEdQ96Q2Zi63Ci871BLZzMaUNilJBofXuyExZLhuwT0jXekn2/r72N9coX3yhvxHW/ZgkH+j2PTaAokyZZ" +
+			"shV/vHFL+GPU4x7FnWHQeCIFxuR5pQ==",
+	},
+	{
+		name:       "SHA-512 Salt 20",
+		hash:       crypto.SHA512,
+		saltLength: 20,
+		message:    "0_receipt_ZGV2aWNl",
+		signature: "GI2aFQnwGBRDviUw8hxxj/KbxBFNZJZYvPfxrHM+UquSsrtFDAC5Lr2RF1rOQvrcpM8LmIA2K+sxd5X7zDlmkf8aVqbPhGa7j7BMLyOe" +
+			"4zDXEALbC562leLxqnOmjoaCKjiFkcEN1G4v3fHzRIeu18QLM4VQ5fBHruRx8yrD4+9Z+nqvo2VFqQYCQaVddAYkh8lx/SvzZSGI6Xrq" +
+			"ue2uw3HLjFLEaEhYmyt/EUKTnEUU7rThnYEOp5jkf6FzVNCgvLAHiMB2YfbhxQUaktmPGKg2D8Hze7JRX0yDaXi4lrdvSPtNgDqwYjOv" +
+			"upzwsorTDnpOE0R2/BYRDrJO9zh8BA==",
+	},
+}
+
+// TestRSAPSSVerifierVectors tests the RSA-PSS verifier against externally generated signatures.
+func TestRSAPSSVerifierVectors(t *testing.T) {
+	block, _ := pem.Decode([]byte(rsaPSSVectorPublicKey))
+	publicKey, err := x509.ParsePKCS1PublicKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse test vector public key: %v", err)
+	}
+
+	for _, vector := range rsaPSSVectors {
+		t.Run(vector.name, func(t *testing.T) {
+			signature, err := base64.StdEncoding.DecodeString(vector.signature)
+			if err != nil {
+				t.Fatalf("Failed to decode test vector signature: %v", err)
+			}
+
+			verifier := &cryptoLib.RSAPSSVerifier{PublicKey: publicKey, Hash: vector.hash, SaltLength: vector.saltLength}
+			if valid, err := verifier.Verify([]byte(vector.message), signature); err != nil || !valid {
+				t.Fatalf("Expected test vector to verify, got %t, %v", valid, err)
+			}
+
+			if valid, _ := verifier.Verify([]byte(vector.message+"x"), signature); valid {
+				t.Fatalf("Expected tampered message not to verify")
+			}
+
+			wrongSalt := &cryptoLib.RSAPSSVerifier{PublicKey: publicKey, Hash: vector.hash, SaltLength: vector.saltLength + 1}
+			if valid, _ := wrongSalt.Verify([]byte(vector.message), signature); valid {
+				t.Fatalf("Expected signature not to verify with a different salt length")
+			}
+
+			pkcs1 := &cryptoLib.RSAVerifier{PublicKey: publicKey, Hash: vector.hash}
+			if valid, _ := pkcs1.Verify([]byte(vector.message), signature); valid {
+				t.Fatalf("Expected PSS signature not to verify as PKCS#1 v1.5")
+			}
+		})
+	}
+}
+
+// TestRSAPSS_Signer tests RSA-PSS signing functionality.
+func TestRSAPSS_Signer(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	data := []byte("Test data for RSA-PSS signing")
+
+	signer := &cryptoLib.RSAPSSSigner{PrivateKey: rsaKey, Hash: crypto.SHA384, SaltLength: 48}
+
+	first, err := signer.Sign(data)
+	if err != nil {
+		t.Fatalf("Failed to sign data: %v", err)
+	}
+	second, err := signer.Sign(data)
+	if err != nil {
+		t.Fatalf("Failed to sign data: %v", err)
+	}
+	if string(first) == string(second) {
+		t.Fatalf("Expected randomized PSS signatures to differ")
+	}
+
+	hashed := crypto.SHA384.New()
+	hashed.Write(data)
+	err = rsa.VerifyPSS(&rsaKey.PublicKey, crypto.SHA384, hashed.Sum(nil), first, &rsa.PSSOptions{SaltLength: 48})
+	if err != nil {
+		t.Fatalf("RSA-PSS signature verification failed: %v", err)
+	}
+}
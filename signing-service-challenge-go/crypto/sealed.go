@@ -0,0 +1,126 @@
+package crypto
+
+// NewSealedRegistry returns a registry with all algorithms of base, changed so that private keys are
+// sealed by sealer as soon as they are generated or loaded. Devices then hold a *SealedPrivateKey
+// instead of the plaintext key, marshalers write the sealed form, and signers unseal the key only
+// for the duration of a single signature.
+func NewSealedRegistry(base *Registry, sealer *KeySealer) *Registry {
+	registry := NewRegistry()
+	// The defaults go first, so that they stay the defaults of their key types.
+	for _, algorithm := range base.Defaults() {
+		registry.MustRegister(sealAlgorithm(*algorithm, sealer))
+	}
+	for _, algorithm := range base.List() {
+		if _, exists := registry.Get(algorithm.Name); !exists {
+			registry.MustRegister(sealAlgorithm(*algorithm, sealer))
+		}
+	}
+	return registry
+}
+
+func sealAlgorithm(algorithm Algorithm, sealer *KeySealer) Algorithm {
+	inner := algorithm
+	marshaler := &sealedKeyMarshaler{inner: inner.Marshaler, sealer: sealer}
+
+	algorithm.Marshaler = marshaler
+	algorithm.Generate = func(parameters Parameters) (*KeyPair, error) {
+		keyPair, err := inner.Generate(parameters)
+		if err != nil {
+			return nil, err
+		}
+		sealed, err := marshaler.seal(*keyPair)
+		if err != nil {
+			return nil, err
+		}
+		return &KeyPair{Public: keyPair.Public, Private: sealed}, nil
+	}
+	algorithm.NewSigner = func(privateKey interface{}, parameters Parameters) (Signer, error) {
+		sealed, ok := privateKey.(*SealedPrivateKey)
+		if !ok {
+			return inner.NewSigner(privateKey, parameters)
+		}
+		return &SealedSigner{Key: sealed, sealer: sealer, algorithm: inner, parameters: parameters}, nil
+	}
+	return algorithm
+}
+
+// sealedKeyMarshaler writes private keys in sealed form. Plaintext keys written by an
+// unsealed registry can still be read; they are sealed while loading.
+type sealedKeyMarshaler struct {
+	inner  KeyMarshaler
+	sealer *KeySealer
+}
+
+func (m *sealedKeyMarshaler) Marshal(keyPair KeyPair) ([]byte, []byte, error) {
+	sealed, ok := keyPair.Private.(*SealedPrivateKey)
+	if !ok {
+		var err error
+		if sealed, err = m.seal(keyPair); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sealed.mu.RLock()
+	publicKey := sealed.publicKeyPEM
+	sealed.mu.RUnlock()
+
+	return publicKey, sealed.Encode(), nil
+}
+
+func (m *sealedKeyMarshaler) Unmarshal(privateKeyBytes []byte) (*KeyPair, error) {
+	if IsSealedPrivateKey(privateKeyBytes) {
+		sealed, err := DecodeSealedPrivateKey(privateKeyBytes)
+		if err != nil {
+			return nil, err
+		}
+		return &KeyPair{Public: sealed.PublicKey, Private: sealed}, nil
+	}
+
+	keyPair, err := m.inner.Unmarshal(privateKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := m.seal(*keyPair)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{Public: keyPair.Public, Private: sealed}, nil
+}
+
+// seal encodes a plaintext key pair with the inner marshaler and seals the private key.
+func (m *sealedKeyMarshaler) seal(keyPair KeyPair) (*SealedPrivateKey, error) {
+	publicKey, privateKey, err := m.inner.Marshal(keyPair)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(privateKey)
+
+	return m.sealer.Seal(privateKey, publicKey)
+}
+
+// SealedSigner signs with a sealed private key. The key is unsealed and parsed for every
+// signature and the decrypted encoding is cleared right after.
+type SealedSigner struct {
+	Key        *SealedPrivateKey
+	sealer     *KeySealer
+	algorithm  Algorithm
+	parameters Parameters
+}
+
+func (s *SealedSigner) Sign(dataToBeSigned []byte) ([]byte, error) {
+	privateKey, err := s.sealer.Open(s.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(privateKey)
+
+	keyPair, err := s.algorithm.Marshaler.Unmarshal(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := s.algorithm.NewSigner(keyPair.Private, s.parameters)
+	if err != nil {
+		return nil, err
+	}
+	return signer.Sign(dataToBeSigned)
+}
@@ -3,12 +3,10 @@ package crypto
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
-	"encoding/asn1"
 	"errors"
-	"math/big"
 )
 
 // Signer defines a contract for different types of signing implementations.
@@ -21,38 +19,82 @@ type Signer interface {
 // RSASigner implements the Signer interface for RSA.
 type RSASigner struct {
 	PrivateKey *rsa.PrivateKey
+	// Hash is applied to the data before signing, SHA-256 if zero.
+	Hash crypto.Hash
+}
+
+// RSAPSSSigner implements the Signer interface for RSA with the PSS signature scheme.
+type RSAPSSSigner struct {
+	PrivateKey *rsa.PrivateKey
+	// Hash is applied to the data before signing, SHA-256 if zero.
+	Hash crypto.Hash
+	// SaltLength is the salt length in bytes, the hash size if zero.
+	SaltLength int
 }
 
 // ECCSigner implements the Signer interface for ECC.
 type ECCSigner struct {
 	PrivateKey *ecdsa.PrivateKey
+	// Hash is applied to the data before signing, SHA-256 if zero.
+	Hash crypto.Hash
+	// Encoding is the signature encoding, SignatureEncodingDER if empty.
+	Encoding string
+}
+
+// Ed25519Signer implements the Signer interface for Ed25519.
+type Ed25519Signer struct {
+	PrivateKey ed25519.PrivateKey
 }
 
 // Sign generates an RSA signature for the given data using the RSA private key.
 func (s *RSASigner) Sign(dataToBeSigned []byte) ([]byte, error) {
-	// Hash the data using SHA-256 before signing.
-	hashed := sha256.Sum256(dataToBeSigned)
-	signature, err := rsa.SignPKCS1v15(rand.Reader, s.PrivateKey, crypto.SHA256, hashed[:])
+	hash, hashed, err := digest(s.Hash, dataToBeSigned)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.PrivateKey, hash, hashed)
 	if err != nil {
 		return nil, err
 	}
 	return signature, nil
 }
 
+// Sign generates an RSA-PSS signature for the given data using the RSA private key.
+func (s *RSAPSSSigner) Sign(dataToBeSigned []byte) ([]byte, error) {
+	hash, hashed, err := digest(s.Hash, dataToBeSigned)
+	if err != nil {
+		return nil, err
+	}
+
+	return rsa.SignPSS(rand.Reader, s.PrivateKey, hash, hashed, &rsa.PSSOptions{
+		SaltLength: pssSaltLength(s.SaltLength),
+		Hash:       hash,
+	})
+}
+
+// pssSaltLength maps an unset salt length to the hash size.
+func pssSaltLength(saltLength int) int {
+	if saltLength == 0 {
+		return rsa.PSSSaltLengthEqualsHash
+	}
+	return saltLength
+}
+
 // Sign generates an ECC signature for the given data using the ECC private key.
 func (s *ECCSigner) Sign(dataToBeSigned []byte) ([]byte, error) {
-	// Hash the data using SHA-256 before signing.
-	hashed := sha256.Sum256(dataToBeSigned)
+	_, hashed, err := digest(s.Hash, dataToBeSigned)
+	if err != nil {
+		return nil, err
+	}
 
-	r, sInt, err := ecdsa.Sign(rand.Reader, s.PrivateKey, hashed[:])
+	r, sInt, err := ecdsa.Sign(rand.Reader, s.PrivateKey, hashed)
 	if err != nil {
 		return nil, err
 	}
 
 	// Combine r and s into a single byte slice for easier handling.
-	signature, err := asn1.Marshal(struct {
-		R, S *big.Int
-	}{r, sInt})
+	signature, err := encodeECDSASignature(&s.PrivateKey.PublicKey, s.Encoding, r, sInt)
 	if err != nil {
 		return nil, err
 	}
@@ -60,14 +102,20 @@ func (s *ECCSigner) Sign(dataToBeSigned []byte) ([]byte, error) {
 	return signature, nil
 }
 
+// Sign generates an Ed25519 signature for the given data.
+// Ed25519 hashes the message internally, so the data is signed as is.
+func (s *Ed25519Signer) Sign(dataToBeSigned []byte) ([]byte, error) {
+	return ed25519.Sign(s.PrivateKey, dataToBeSigned), nil
+}
+
 // GetSigner Helper function to determine the correct signer based on the key type.
+// The key is used with the default algorithm of its type in the DefaultRegistry, e.g. RSA
+// rather than RSA-PSS for RSA keys, independent of how the algorithms are named.
 func GetSigner(privateKey interface{}) (Signer, error) {
-	switch key := privateKey.(type) {
-	case *rsa.PrivateKey:
-		return &RSASigner{PrivateKey: key}, nil
-	case *ecdsa.PrivateKey:
-		return &ECCSigner{PrivateKey: key}, nil
-	default:
-		return nil, errors.New("unsupported private key type")
+	for _, algorithm := range DefaultRegistry.Defaults() {
+		if signer, err := algorithm.NewSigner(privateKey, algorithm.DefaultParameters); err == nil {
+			return signer, nil
+		}
 	}
+	return nil, errors.New("unsupported private key type")
 }
@@ -3,6 +3,7 @@ package crypto_test
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -78,6 +79,26 @@ func TestECC_Signer(t *testing.T) {
 	}
 }
 
+// TestEd25519_Signer tests Ed25519 signing functionality.
+func TestEd25519_Signer(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	data := []byte("Test data for Ed25519 signing")
+
+	signer := &cryptoLib.Ed25519Signer{PrivateKey: privateKey}
+
+	signature, err := signer.Sign(data)
+	if err != nil {
+		t.Fatalf("Failed to sign data: %v", err)
+	}
+
+	if !ed25519.Verify(publicKey, data, signature) {
+		t.Fatalf("Ed25519 signature verification failed")
+	}
+}
+
 // TestGetSigner_RSA tests the GetSigner function for RSA keys.
 func TestGetSigner_RSA(t *testing.T) {
 	rsaKey, err := rsa.GenerateKey(rand.Reader, 512)
@@ -112,6 +133,61 @@ func TestGetSigner_ECC(t *testing.T) {
 	}
 }
 
+// TestGetSigner_Ed25519 tests the GetSigner function for Ed25519 keys.
+func TestGetSigner_Ed25519(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+
+	signer, err := cryptoLib.GetSigner(privateKey)
+	if err != nil {
+		t.Fatalf("Failed to get Ed25519 signer: %v", err)
+	}
+
+	if _, ok := signer.(*cryptoLib.Ed25519Signer); !ok {
+		t.Fatalf("Expected Ed25519Signer, got %T", signer)
+	}
+}
+
+// TestGetSigner_UsesDefaultAlgorithm tests that GetSigner and GetVerifier use the default algorithm
+// of a key type even if another algorithm accepting the key sorts before it.
+func TestGetSigner_UsesDefaultAlgorithm(t *testing.T) {
+	rsaPSS, _ := cryptoLib.DefaultRegistry.Get("RSA-PSS")
+	registry := cryptoLib.NewRegistry()
+	for _, algorithm := range cryptoLib.DefaultRegistry.List() {
+		registry.MustRegister(*algorithm)
+	}
+	shadowing := *rsaPSS
+	shadowing.Name = "AAA-RSA-PSS"
+	registry.MustRegister(shadowing)
+
+	defaultRegistry := cryptoLib.DefaultRegistry
+	cryptoLib.DefaultRegistry = registry
+	defer func() { cryptoLib.DefaultRegistry = defaultRegistry }()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	signer, err := cryptoLib.GetSigner(rsaKey)
+	if err != nil {
+		t.Fatalf("Failed to get RSA signer: %v", err)
+	}
+	if _, ok := signer.(*cryptoLib.RSASigner); !ok {
+		t.Fatalf("Expected RSASigner, got %T", signer)
+	}
+
+	verifier, err := cryptoLib.GetVerifier(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to get RSA verifier: %v", err)
+	}
+	if _, ok := verifier.(*cryptoLib.RSAVerifier); !ok {
+		t.Fatalf("Expected RSAVerifier, got %T", verifier)
+	}
+}
+
 // TestGetSigner_UnsupportedKey tests the GetSigner function for unsupported key types.
 func TestGetSigner_UnsupportedKey(t *testing.T) {
 	unsupportedKey := &x509.Certificate{}
@@ -0,0 +1,104 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+)
+
+// Verifier defines a contract for checking signatures produced by a Signer.
+type Verifier interface {
+	Verify(signedData []byte, signature []byte) (bool, error)
+}
+
+// RSAVerifier implements the Verifier interface for RSA PKCS#1 v1.5 signatures.
+type RSAVerifier struct {
+	PublicKey *rsa.PublicKey
+	// Hash is the hash function the signer applied, SHA-256 if zero.
+	Hash crypto.Hash
+}
+
+// RSAPSSVerifier implements the Verifier interface for RSA-PSS signatures.
+type RSAPSSVerifier struct {
+	PublicKey *rsa.PublicKey
+	// Hash is the hash function the signer applied, SHA-256 if zero.
+	Hash crypto.Hash
+	// SaltLength is the salt length in bytes the signer used, the hash size if zero.
+	SaltLength int
+}
+
+// ECCVerifier implements the Verifier interface for ECDSA signatures.
+type ECCVerifier struct {
+	PublicKey *ecdsa.PublicKey
+	// Hash is the hash function the signer applied, SHA-256 if zero.
+	Hash crypto.Hash
+	// Encoding is the signature encoding, SignatureEncodingDER if empty.
+	Encoding string
+}
+
+// Ed25519Verifier implements the Verifier interface for Ed25519 signatures.
+type Ed25519Verifier struct {
+	PublicKey ed25519.PublicKey
+}
+
+// Verify checks an RSA signature as produced by RSASigner.Sign.
+func (v *RSAVerifier) Verify(signedData []byte, signature []byte) (bool, error) {
+	hash, hashed, err := digest(v.Hash, signedData)
+	if err != nil {
+		return false, err
+	}
+
+	err = rsa.VerifyPKCS1v15(v.PublicKey, hash, hashed, signature)
+	return err == nil, nil
+}
+
+// Verify checks an RSA-PSS signature as produced by RSAPSSSigner.Sign.
+func (v *RSAPSSVerifier) Verify(signedData []byte, signature []byte) (bool, error) {
+	hash, hashed, err := digest(v.Hash, signedData)
+	if err != nil {
+		return false, err
+	}
+
+	err = rsa.VerifyPSS(v.PublicKey, hash, hashed, signature, &rsa.PSSOptions{
+		SaltLength: pssSaltLength(v.SaltLength),
+		Hash:       hash,
+	})
+	return err == nil, nil
+}
+
+// Verify checks an ECC signature as produced by ECCSigner.Sign.
+func (v *ECCVerifier) Verify(signedData []byte, signature []byte) (bool, error) {
+	r, s, wellFormed, err := decodeECDSASignature(v.PublicKey, v.Encoding, signature)
+	if err != nil {
+		return false, err
+	}
+	if !wellFormed {
+		// A malformed signature is simply not a valid one.
+		return false, nil
+	}
+
+	_, hashed, err := digest(v.Hash, signedData)
+	if err != nil {
+		return false, err
+	}
+
+	return ecdsa.Verify(v.PublicKey, hashed, r, s), nil
+}
+
+// Verify checks an Ed25519 signature as produced by Ed25519Signer.Sign.
+func (v *Ed25519Verifier) Verify(signedData []byte, signature []byte) (bool, error) {
+	return ed25519.Verify(v.PublicKey, signedData, signature), nil
+}
+
+// GetVerifier Helper function to determine the correct verifier based on the key type.
+// The key is used with the default algorithm of its type in the DefaultRegistry, like in GetSigner.
+func GetVerifier(publicKey interface{}) (Verifier, error) {
+	for _, algorithm := range DefaultRegistry.Defaults() {
+		if verifier, err := algorithm.NewVerifier(publicKey, algorithm.DefaultParameters); err == nil {
+			return verifier, nil
+		}
+	}
+	return nil, errors.New("unsupported public key type")
+}
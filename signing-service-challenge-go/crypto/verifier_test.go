@@ -0,0 +1,144 @@
+package crypto_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	cryptoLib "github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"testing"
+)
+
+// TestVerifiers tests that signatures are accepted by the matching verifier only.
+func TestVerifiers(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 512)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 512)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	eccKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECC key: %v", err)
+	}
+	otherECCKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECC key: %v", err)
+	}
+	edPublicKey, edPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	otherEdPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+
+	testCases := []struct {
+		name          string
+		signer        cryptoLib.Signer
+		verifier      cryptoLib.Verifier
+		otherVerifier cryptoLib.Verifier
+	}{
+		{
+			name:          "RSA",
+			signer:        &cryptoLib.RSASigner{PrivateKey: rsaKey},
+			verifier:      &cryptoLib.RSAVerifier{PublicKey: &rsaKey.PublicKey},
+			otherVerifier: &cryptoLib.RSAVerifier{PublicKey: &otherRSAKey.PublicKey},
+		},
+		{
+			name:          "ECC",
+			signer:        &cryptoLib.ECCSigner{PrivateKey: eccKey},
+			verifier:      &cryptoLib.ECCVerifier{PublicKey: &eccKey.PublicKey},
+			otherVerifier: &cryptoLib.ECCVerifier{PublicKey: &otherECCKey.PublicKey},
+		},
+		{
+			name:          "Ed25519",
+			signer:        &cryptoLib.Ed25519Signer{PrivateKey: edPrivateKey},
+			verifier:      &cryptoLib.Ed25519Verifier{PublicKey: edPublicKey},
+			otherVerifier: &cryptoLib.Ed25519Verifier{PublicKey: otherEdPublicKey},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := []byte("0_receipt_ZGV2aWNl")
+			signature, err := tc.signer.Sign(data)
+			if err != nil {
+				t.Fatalf("Failed to sign data: %v", err)
+			}
+
+			checks := []struct {
+				name      string
+				verifier  cryptoLib.Verifier
+				data      []byte
+				signature []byte
+				expected  bool
+			}{
+				{"Valid Signature", tc.verifier, data, signature, true},
+				{"Tampered Data", tc.verifier, []byte("1_receipt_ZGV2aWNl"), signature, false},
+				{"Wrong Key", tc.otherVerifier, data, signature, false},
+				{"Malformed Signature", tc.verifier, data, []byte("not a signature"), false},
+			}
+
+			for _, check := range checks {
+				valid, err := check.verifier.Verify(check.data, check.signature)
+				if err != nil {
+					t.Fatalf("%s: unexpected error: %v", check.name, err)
+				}
+				if valid != check.expected {
+					t.Fatalf("%s: expected valid=%t, got %t", check.name, check.expected, valid)
+				}
+			}
+		})
+	}
+}
+
+// TestGetVerifier tests the GetVerifier function for supported and unsupported key types.
+func TestGetVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 512)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	eccKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECC key: %v", err)
+	}
+
+	verifier, err := cryptoLib.GetVerifier(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to get RSA verifier: %v", err)
+	}
+	if _, ok := verifier.(*cryptoLib.RSAVerifier); !ok {
+		t.Fatalf("Expected RSAVerifier, got %T", verifier)
+	}
+
+	verifier, err = cryptoLib.GetVerifier(&eccKey.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to get ECC verifier: %v", err)
+	}
+	if _, ok := verifier.(*cryptoLib.ECCVerifier); !ok {
+		t.Fatalf("Expected ECCVerifier, got %T", verifier)
+	}
+
+	edPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	verifier, err = cryptoLib.GetVerifier(edPublicKey)
+	if err != nil {
+		t.Fatalf("Failed to get Ed25519 verifier: %v", err)
+	}
+	if _, ok := verifier.(*cryptoLib.Ed25519Verifier); !ok {
+		t.Fatalf("Expected Ed25519Verifier, got %T", verifier)
+	}
+
+	verifier, err = cryptoLib.GetVerifier(&x509.Certificate{})
+	if verifier != nil || err == nil {
+		t.Fatalf("Expected error for unsupported key type, got %T, %v", verifier, err)
+	}
+}
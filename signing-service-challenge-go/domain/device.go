@@ -2,9 +2,9 @@ package domain
 
 // TODO: signature device domain model ...
 import (
-	"encoding/base64"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/google/uuid"
@@ -15,40 +15,270 @@ type SignatureDevice struct {
 	ID               uuid.UUID
 	Label            string
 	Algorithm        string
+	Parameters       crypto.Parameters
 	SignatureCounter int
 	LastSignature    []byte
 	PrivateKey       interface{}
 	PublicKey        interface{}
 	Signer           crypto.Signer
+	Verifier         crypto.Verifier
+	// Metadata holds arbitrary key/value pairs describing the device, such as a store ID or till number.
+	Metadata map[string]string
+	// Version is the version of the stored record this device was read from or last written as.
+	// Repositories reject updates of a device whose version is no longer the stored one.
+	Version int
+	// Status is the lifecycle state of the device; only active devices sign.
+	Status DeviceStatus
+	// DecommissionedAt records when the device was decommissioned.
+	DecommissionedAt time.Time
+	// KeyGeneration numbers the current key pair, starting at 1; KeyValidFrom is the first counter it signed.
+	KeyGeneration int
+	KeyValidFrom  int
+	// RetiredKeys are the keys the device signed with before its key was rotated, oldest first.
+	RetiredKeys []KeyGeneration
+	// SecuredDataFormat is the layout of the secured data the device signs; it is fixed at creation.
+	SecuredDataFormat SecuredDataFormat
 }
 
-// BuildSignData generates the secured data string for signing.
-// This includes the signature counter, transaction data, and last signature (if any).
-func (device *SignatureDevice) BuildSignData(data string) (string, error) {
+// Details returns the device's label and a copy of its metadata.
+func (device *SignatureDevice) Details() (string, map[string]string) {
 	device.mu.Lock()
 	defer device.mu.Unlock()
 
-	// Use the device ID if no signature has been made yet
-	var signature []byte
-	if device.SignatureCounter == 0 {
-		signature = []byte(device.ID.String())
-	} else {
-		signature = device.LastSignature
+	return device.Label, copyMetadata(device.Metadata)
+}
+
+// UpdateDetails changes the label, if given, and merges the given metadata into the device's metadata.
+// Metadata keys with a nil value are removed.
+func (device *SignatureDevice) UpdateDetails(label *string, metadata map[string]*string) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	if label != nil {
+		device.Label = *label
+	}
+	if len(metadata) == 0 {
+		return
+	}
+
+	merged := copyMetadata(device.Metadata)
+	if merged == nil {
+		merged = make(map[string]string, len(metadata))
+	}
+	for key, value := range metadata {
+		if value == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = *value
+		}
+	}
+	if len(merged) == 0 {
+		merged = nil
+	}
+	device.Metadata = merged
+}
+
+// SignatureState returns a consistent snapshot of the signature counter and the last signature.
+func (device *SignatureDevice) SignatureState() (int, []byte) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	return device.SignatureCounter, device.LastSignature
+}
+
+// SyncSignatureState adopts a signature state persisted elsewhere, e.g. by another service instance.
+// The state is only ever moved forward, so a stale snapshot cannot roll back the counter.
+func (device *SignatureDevice) SyncSignatureState(counter int, lastSignature []byte) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	if counter > device.SignatureCounter {
+		device.SignatureCounter = counter
+		device.LastSignature = lastSignature
 	}
+}
+
+// CurrentVersion returns the version of the stored record the device is based on.
+func (device *SignatureDevice) CurrentVersion() int {
+	device.mu.Lock()
+	defer device.mu.Unlock()
 
-	encodedSignature := base64.StdEncoding.EncodeToString(signature)
-	securedData := fmt.Sprintf("%d_%s_%s", device.SignatureCounter, data, encodedSignature)
+	return device.Version
+}
 
-	return securedData, nil
+// SyncVersion records that the device now matches the given stored version.
+// Like the signature state, the version is only ever moved forward.
+func (device *SignatureDevice) SyncVersion(version int) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	if version > device.Version {
+		device.Version = version
+	}
 }
 
-// CommitSignature updates the signature device's state with the newly created signature.
-func (device *SignatureDevice) CommitSignature(signature []byte) error {
+// Sign builds the secured data, signs it and commits the resulting signature
+// as a single critical section, so that concurrent callers can never reserve
+// the same signature counter.
+// The optional persist callback is invoked with the new transaction before the
+// counter is advanced; if it fails, the device state is left untouched.
+func (device *SignatureDevice) Sign(data string, persist func(*Transaction) error) (*Transaction, error) {
 	device.mu.Lock()
 	defer device.mu.Unlock()
 
+	if status := device.status(); status != DeviceStatusActive {
+		return nil, &InactiveDeviceError{DeviceID: device.ID, Status: status}
+	}
+	if device.Signer == nil {
+		return nil, fmt.Errorf("device %s has no signer", device.ID)
+	}
+
+	securedData := device.buildSignData(data)
+
+	signature, err := device.Signer.Sign([]byte(securedData))
+	if err != nil {
+		return nil, err
+	}
+
+	transaction := &Transaction{
+		DeviceID:    device.ID,
+		Counter:     device.SignatureCounter,
+		Data:        data,
+		SecuredData: securedData,
+		Signature:   signature,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	if persist != nil {
+		if err := persist(transaction); err != nil {
+			return nil, err
+		}
+	}
+
+	device.commitSignature(signature)
+
+	return transaction, nil
+}
+
+// SignBatch signs the given data items as consecutive links of the device's chain in a single critical
+// section, following the same chaining rules as Sign. The persist callback is invoked with all
+// transactions at once; if signing any item or persisting fails, the device state is left untouched.
+func (device *SignatureDevice) SignBatch(data []string, persist func([]*Transaction) error) ([]*Transaction, error) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	if status := device.status(); status != DeviceStatusActive {
+		return nil, &InactiveDeviceError{DeviceID: device.ID, Status: status}
+	}
+	if device.Signer == nil {
+		return nil, fmt.Errorf("device %s has no signer", device.ID)
+	}
+
+	var (
+		transactions  = make([]*Transaction, len(data))
+		counter       = device.SignatureCounter
+		lastSignature = device.LastSignature
+		createdAt     = time.Now().UTC()
+	)
+	for i, item := range data {
+		securedData := device.formatSecuredData(counter, item, lastSignature)
+
+		signature, err := device.Signer.Sign([]byte(securedData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign item %d: %w", i, err)
+		}
+
+		transactions[i] = &Transaction{
+			DeviceID:    device.ID,
+			Counter:     counter,
+			Data:        item,
+			SecuredData: securedData,
+			Signature:   signature,
+			CreatedAt:   createdAt,
+		}
+		counter++
+		lastSignature = signature
+	}
+
+	if persist != nil {
+		if err := persist(transactions); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, transaction := range transactions {
+		device.commitSignature(transaction.Signature)
+	}
+
+	return transactions, nil
+}
+
+// buildSignData assembles the secured data string for the next signature. The caller must hold device.mu.
+func (device *SignatureDevice) buildSignData(data string) string {
+	return device.formatSecuredData(device.SignatureCounter, data, device.LastSignature)
+}
+
+// formatSecuredData assembles the secured data string for the given position in the device's signature chain
+// in the device's format. For the first signature (counter 0) the device ID takes the place of the last
+// signature. The caller must hold device.mu.
+func (device *SignatureDevice) formatSecuredData(counter int, data string, lastSignature []byte) string {
+	return device.SecuredDataFormat.Formatter().Format(SecuredData{
+		Counter:       counter,
+		Data:          data,
+		LastSignature: ChainReference(device.ID, counter, lastSignature),
+	})
+}
+
+// SecuredDataFormatter returns the formatter of the layout the device signs secured data in.
+func (device *SignatureDevice) SecuredDataFormatter() SecuredDataFormatter {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	return device.SecuredDataFormat.Formatter()
+}
+
+// Copy returns a detached copy of the device, e.g. to prepare changes that only take effect once
+// they have been stored. Keys, signers and signatures are shared, as they are never modified in place.
+func (device *SignatureDevice) Copy() *SignatureDevice {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	return &SignatureDevice{
+		ID:                device.ID,
+		Label:             device.Label,
+		Algorithm:         device.Algorithm,
+		Parameters:        device.Parameters,
+		SignatureCounter:  device.SignatureCounter,
+		LastSignature:     device.LastSignature,
+		PrivateKey:        device.PrivateKey,
+		PublicKey:         device.PublicKey,
+		Signer:            device.Signer,
+		Verifier:          device.Verifier,
+		Metadata:          copyMetadata(device.Metadata),
+		Version:           device.Version,
+		Status:            device.Status,
+		DecommissionedAt:  device.DecommissionedAt,
+		KeyGeneration:     device.KeyGeneration,
+		KeyValidFrom:      device.KeyValidFrom,
+		RetiredKeys:       append([]KeyGeneration(nil), device.RetiredKeys...),
+		SecuredDataFormat: device.SecuredDataFormat,
+	}
+}
+
+// copyMetadata returns a copy of metadata, or nil if it is empty.
+func copyMetadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		copied[key] = value
+	}
+	return copied
+}
+
+// commitSignature advances the signature chain. The caller must hold device.mu.
+func (device *SignatureDevice) commitSignature(signature []byte) {
 	device.SignatureCounter++
 	device.LastSignature = signature
-
-	return nil
 }
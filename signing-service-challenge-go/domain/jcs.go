@@ -0,0 +1,226 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// CanonicalizeJSON returns the RFC 8785 (JCS) canonical form of a JSON text: object members sorted by
+// the UTF-16 code units of their names, numbers written like ECMAScript does and no insignificant whitespace,
+// so that semantically equal JSON always yields the same bytes. Duplicate member names and numbers outside
+// the range of IEEE 754 doubles are rejected.
+func CanonicalizeJSON(data []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	value, err := readCanonicalValue(decoder)
+	if err != nil {
+		return "", err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return "", errors.New("unexpected data after the JSON value")
+	}
+
+	var buffer bytes.Buffer
+	if err := writeCanonicalValue(&buffer, value); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// canonicalMember is a member of a JSON object read by readCanonicalValue.
+type canonicalMember struct {
+	name  string
+	value interface{}
+}
+
+// readCanonicalValue reads the next JSON value. Objects are read as member lists, so that duplicate
+// names are noticed instead of silently overwritten.
+func readCanonicalValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	switch token {
+	case json.Delim('{'):
+		var members []canonicalMember
+		names := make(map[string]bool)
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSON: %w", err)
+			}
+			name := token.(string)
+			if names[name] {
+				return nil, fmt.Errorf("duplicate member %q", name)
+			}
+			names[name] = true
+
+			value, err := readCanonicalValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, canonicalMember{name: name, value: value})
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return members, nil
+	case json.Delim('['):
+		elements := []interface{}{}
+		for decoder.More() {
+			value, err := readCanonicalValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, value)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return elements, nil
+	default:
+		return token, nil
+	}
+}
+
+// writeCanonicalValue writes a value read by readCanonicalValue in canonical form.
+func writeCanonicalValue(buffer *bytes.Buffer, value interface{}) error {
+	switch value := value.(type) {
+	case []canonicalMember:
+		sort.Slice(value, func(i, j int) bool {
+			return lessUTF16(value[i].name, value[j].name)
+		})
+		buffer.WriteByte('{')
+		for i, member := range value {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			writeCanonicalString(buffer, member.name)
+			buffer.WriteByte(':')
+			if err := writeCanonicalValue(buffer, member.value); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+	case []interface{}:
+		buffer.WriteByte('[')
+		for i, element := range value {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writeCanonicalValue(buffer, element); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+	case string:
+		writeCanonicalString(buffer, value)
+	case json.Number:
+		number, err := strconv.ParseFloat(string(value), 64)
+		if err != nil {
+			return fmt.Errorf("number %s cannot be represented: %w", value, err)
+		}
+		buffer.WriteString(formatCanonicalNumber(number))
+	case bool:
+		buffer.WriteString(strconv.FormatBool(value))
+	case nil:
+		buffer.WriteString("null")
+	default:
+		return fmt.Errorf("unexpected JSON token %v", value)
+	}
+	return nil
+}
+
+// lessUTF16 orders strings by their UTF-16 code units, as RFC 8785 sorts member names.
+func lessUTF16(a, b string) bool {
+	unitsA, unitsB := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(unitsA) && i < len(unitsB); i++ {
+		if unitsA[i] != unitsB[i] {
+			return unitsA[i] < unitsB[i]
+		}
+	}
+	return len(unitsA) < len(unitsB)
+}
+
+// formatCanonicalNumber writes a finite double the way ECMAScript's Number.prototype.toString does,
+// which RFC 8785 prescribes: the shortest digits that round-trip, in plain notation for exponents
+// from -7 to 20 and in exponential notation otherwise.
+func formatCanonicalNumber(number float64) string {
+	if number == 0 || math.IsNaN(number) || math.IsInf(number, 0) {
+		// Negative zero is written as 0; non-finite numbers cannot be parsed from JSON.
+		return "0"
+	}
+
+	sign := ""
+	if number < 0 {
+		sign = "-"
+		number = -number
+	}
+
+	// Shortest round-trip digits d.ddde±x, so the decimal point sits after `point` digits.
+	mantissa, exponent, _ := strings.Cut(strconv.FormatFloat(number, 'e', -1, 64), "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	power, _ := strconv.Atoi(exponent)
+	point := power + 1
+
+	switch {
+	case len(digits) <= point && point <= 21:
+		return sign + digits + strings.Repeat("0", point-len(digits))
+	case 0 < point && point <= 21:
+		return sign + digits[:point] + "." + digits[point:]
+	case -6 < point && point <= 0:
+		return sign + "0." + strings.Repeat("0", -point) + digits
+	}
+
+	exponentSign := "+"
+	if power < 0 {
+		exponentSign = "-"
+		power = -power
+	}
+	if len(digits) > 1 {
+		digits = digits[:1] + "." + digits[1:]
+	}
+	return sign + digits + "e" + exponentSign + strconv.Itoa(power)
+}
+
+// writeCanonicalString writes s as a JSON string the way RFC 8785 serializes strings: only quotes,
+// backslashes and control characters are escaped, everything else is written as UTF-8.
+// Invalid UTF-8 is replaced with U+FFFD.
+func writeCanonicalString(buffer *bytes.Buffer, s string) {
+	buffer.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buffer.WriteString(`\"`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buffer, `\u%04x`, r)
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
+	}
+	buffer.WriteByte('"')
+}
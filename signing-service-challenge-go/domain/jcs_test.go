@@ -0,0 +1,108 @@
+package domain_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+func TestCanonicalizeJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name: "RFC 8785 Example",
+			input: `{
+				"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+				"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+				"literals": [null, true, false]
+			}`,
+			expected: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],` +
+				`"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		{
+			name: "Members Sorted By UTF-16 Code Units",
+			input: `{"\u20ac": "Euro Sign", "\r": "Carriage Return", "\ufb33": "Hebrew Letter Dalet With Dagesh",
+				"1": "One", "\ud83d\ude00": "Emoji: Grinning Face", "\u0080": "Control",
+				"\u00f6": "Latin Small Letter O With Diaeresis"}`,
+			expected: "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\"," +
+				"\"ö\":\"Latin Small Letter O With Diaeresis\",\"€\":\"Euro Sign\"," +
+				"\"😀\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+		},
+		{
+			name:     "Nested Values",
+			input:    ` { "b" : [ {"d": 1.0, "c": -0} ], "a" : {} , "e": [] } `,
+			expected: `{"a":{},"b":[{"c":0,"d":1}],"e":[]}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			canonical, err := domain.CanonicalizeJSON([]byte(tc.input))
+			if err != nil {
+				t.Fatalf("Failed to canonicalize JSON: %v", err)
+			}
+			if canonical != tc.expected {
+				t.Fatalf("Expected %s, got %s", tc.expected, canonical)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeJSONNumbers(t *testing.T) {
+	// Boundary cases of ECMAScript number serialization, mostly taken from RFC 8785, Appendix B.
+	testCases := []struct {
+		bits     uint64
+		expected string
+	}{
+		{0x0000000000000000, "0"},
+		{0x8000000000000000, "0"},
+		{0x0000000000000001, "5e-324"},
+		{0x8000000000000001, "-5e-324"},
+		{0x7fefffffffffffff, "1.7976931348623157e+308"},
+		{0xffefffffffffffff, "-1.7976931348623157e+308"},
+		{0x4340000000000000, "9007199254740992"},
+		{0xc340000000000000, "-9007199254740992"},
+		{0x4430000000000000, "295147905179352830000"},
+		{0x44b52d02c7e14af5, "9.999999999999997e+22"},
+		{0x44b52d02c7e14af6, "1e+23"},
+		{0x44b52d02c7e14af7, "1.0000000000000001e+23"},
+		{0x444b1ae4d6e2ef4e, "999999999999999700000"},
+		{0x444b1ae4d6e2ef4f, "999999999999999900000"},
+		{0x444b1ae4d6e2ef50, "1e+21"},
+		{0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+		{0x3eb0c6f7a0b5ed8d, "0.000001"},
+		{0x41b3de4355555553, "333333333.3333332"},
+		{0x41b3de4355555556, "333333333.3333334"},
+		{0xc1b3de4355555556, "-333333333.3333334"},
+	}
+
+	for _, tc := range testCases {
+		input := strconv.FormatFloat(math.Float64frombits(tc.bits), 'g', -1, 64)
+		canonical, err := domain.CanonicalizeJSON([]byte(input))
+		if err != nil {
+			t.Fatalf("Failed to canonicalize %s: %v", input, err)
+		}
+		if canonical != tc.expected {
+			t.Errorf("Expected %s to be written as %s, got %s", input, tc.expected, canonical)
+		}
+	}
+}
+
+func TestCanonicalizeJSONRejectsInvalidInput(t *testing.T) {
+	for _, input := range []string{
+		`{"a": 1, "a": 2}`,
+		`{"a": 1e400}`,
+		`{"a": 1`,
+		`{"a": 1} {}`,
+		``,
+	} {
+		if canonical, err := domain.CanonicalizeJSON([]byte(input)); err == nil {
+			t.Errorf("Expected %q to be rejected, got %s", input, canonical)
+		}
+	}
+}
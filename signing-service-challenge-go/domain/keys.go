@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+// keyRotationPrefix starts the data of every rotation record.
+const keyRotationPrefix = "KEY_ROTATION"
+
+// KeyGeneration is a key pair a device signed with during a window of signature counters.
+type KeyGeneration struct {
+	Generation int
+	PublicKey  interface{}
+	Verifier   crypto.Verifier
+	// ValidFrom is the first signature counter signed with the key.
+	ValidFrom int
+	// ValidUntil is the first signature counter no longer signed with the key, or 0 while the key is current.
+	// The last counter signed with a retired key is the rotation record announcing its successor.
+	ValidUntil int
+	// RetiredAt is when the key was rotated out; it is zero while the key is current.
+	RetiredAt time.Time
+}
+
+// covers reports whether the key signed the given counter.
+func (key *KeyGeneration) covers(counter int) bool {
+	return counter >= key.ValidFrom && (key.ValidUntil == 0 || counter < key.ValidUntil)
+}
+
+// NewKey is a freshly generated key pair a device rotates to.
+type NewKey struct {
+	PublicKey  interface{}
+	PrivateKey interface{}
+	Signer     crypto.Signer
+	Verifier   crypto.Verifier
+}
+
+// KeyRotation is everything that has to be stored atomically when a device rotates its key:
+// the rotation record signed with the retired key, the retired key's generation and the new key.
+type KeyRotation struct {
+	Algorithm   string
+	Transaction *Transaction
+	Retired     KeyGeneration
+	Current     KeyGeneration
+	PrivateKey  interface{}
+}
+
+// KeyRotationData is the data of the rotation record that announces a device's new public key.
+// It is signed with the retired key, so that the chain vouches for the new key.
+func KeyRotationData(generation int, publicKey interface{}) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode public key: %w", err)
+	}
+	return fmt.Sprintf("%s:%d:%s", keyRotationPrefix, generation, base64.StdEncoding.EncodeToString(der)), nil
+}
+
+// CurrentKeyGeneration returns the generation of the key the device currently signs with.
+func (device *SignatureDevice) CurrentKeyGeneration() int {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	return device.keyGeneration()
+}
+
+// KeyGenerations returns all keys of the device ordered by generation, ending with the current one.
+func (device *SignatureDevice) KeyGenerations() []KeyGeneration {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	keys := make([]KeyGeneration, 0, len(device.RetiredKeys)+1)
+	keys = append(keys, device.RetiredKeys...)
+	return append(keys, device.currentKey())
+}
+
+// VerifierFor returns the verifier of the key that signed the given counter.
+// Counters that have not been signed yet map to the current key.
+func (device *SignatureDevice) VerifierFor(counter int) crypto.Verifier {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	for i := range device.RetiredKeys {
+		if device.RetiredKeys[i].covers(counter) {
+			return device.RetiredKeys[i].Verifier
+		}
+	}
+	return device.Verifier
+}
+
+// RotateKey replaces the device's key pair. A rotation record announcing the new public key is
+// signed with the current key as the next link of the chain, after which the current key is retired.
+// Like Sign, this is a single critical section: the persist callback is invoked with the complete
+// rotation, and if it fails, the device is left untouched.
+func (device *SignatureDevice) RotateKey(key NewKey, at time.Time, persist func(*KeyRotation) error) (*KeyRotation, error) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	if status := device.status(); status != DeviceStatusActive {
+		return nil, &InactiveDeviceError{DeviceID: device.ID, Status: status}
+	}
+	if device.Signer == nil {
+		return nil, fmt.Errorf("device %s has no signer", device.ID)
+	}
+
+	generation := device.keyGeneration()
+	data, err := KeyRotationData(generation+1, key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	securedData := device.buildSignData(data)
+	signature, err := device.Signer.Sign([]byte(securedData))
+	if err != nil {
+		return nil, err
+	}
+
+	at = at.UTC()
+	retired := device.currentKey()
+	retired.ValidUntil = device.SignatureCounter + 1
+	retired.RetiredAt = at
+
+	rotation := &KeyRotation{
+		Algorithm: device.Algorithm,
+		Transaction: &Transaction{
+			DeviceID:    device.ID,
+			Counter:     device.SignatureCounter,
+			Data:        data,
+			SecuredData: securedData,
+			Signature:   signature,
+			CreatedAt:   at,
+		},
+		Retired: retired,
+		Current: KeyGeneration{
+			Generation: generation + 1,
+			PublicKey:  key.PublicKey,
+			Verifier:   key.Verifier,
+			ValidFrom:  retired.ValidUntil,
+		},
+		PrivateKey: key.PrivateKey,
+	}
+
+	if persist != nil {
+		if err := persist(rotation); err != nil {
+			return nil, err
+		}
+	}
+
+	device.commitSignature(signature)
+	device.RetiredKeys = append(device.RetiredKeys, retired)
+	device.KeyGeneration = rotation.Current.Generation
+	device.KeyValidFrom = rotation.Current.ValidFrom
+	device.PublicKey = key.PublicKey
+	device.PrivateKey = key.PrivateKey
+	device.Signer = key.Signer
+	device.Verifier = key.Verifier
+
+	return rotation, nil
+}
+
+// currentKey describes the key the device currently signs with. The caller must hold device.mu.
+func (device *SignatureDevice) currentKey() KeyGeneration {
+	return KeyGeneration{
+		Generation: device.keyGeneration(),
+		PublicKey:  device.PublicKey,
+		Verifier:   device.Verifier,
+		ValidFrom:  device.KeyValidFrom,
+	}
+}
+
+// keyGeneration returns the current key generation, treating devices stored before keys
+// could be rotated as being on their first key. The caller must hold device.mu.
+func (device *SignatureDevice) keyGeneration() int {
+	if device.KeyGeneration == 0 {
+		return 1
+	}
+	return device.KeyGeneration
+}
@@ -0,0 +1,142 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeviceStatus is the lifecycle state of a signature device.
+type DeviceStatus string
+
+const (
+	// DeviceStatusActive devices sign transactions.
+	DeviceStatusActive DeviceStatus = "ACTIVE"
+	// DeviceStatusSuspended devices refuse to sign until they are reactivated, e.g. while a till is lost.
+	DeviceStatusSuspended DeviceStatus = "SUSPENDED"
+	// DeviceStatusDecommissioned devices are permanently retired and never sign again.
+	DeviceStatusDecommissioned DeviceStatus = "DECOMMISSIONED"
+)
+
+// ParseDeviceStatus validates a device status given by name.
+func ParseDeviceStatus(name string) (DeviceStatus, error) {
+	switch status := DeviceStatus(name); status {
+	case DeviceStatusActive, DeviceStatusSuspended, DeviceStatusDecommissioned:
+		return status, nil
+	default:
+		return "", fmt.Errorf("unknown device status %q", name)
+	}
+}
+
+// canTransition reports whether a device may move from one status to another.
+// Decommissioning is irreversible; staying in the current status is always allowed.
+func canTransition(from, to DeviceStatus) bool {
+	if from == to {
+		return true
+	}
+	switch from {
+	case DeviceStatusActive:
+		return to == DeviceStatusSuspended || to == DeviceStatusDecommissioned
+	case DeviceStatusSuspended:
+		return to == DeviceStatusActive || to == DeviceStatusDecommissioned
+	default:
+		return false
+	}
+}
+
+// InactiveDeviceError reports that a device was asked to sign while it is not active.
+type InactiveDeviceError struct {
+	DeviceID uuid.UUID
+	Status   DeviceStatus
+}
+
+func (e *InactiveDeviceError) Error() string {
+	return fmt.Sprintf("device %s is %s and cannot sign", e.DeviceID, e.Status)
+}
+
+// IsInactiveDevice reports whether err is or wraps an *InactiveDeviceError.
+func IsInactiveDevice(err error) bool {
+	var inactive *InactiveDeviceError
+	return errors.As(err, &inactive)
+}
+
+// StatusTransitionError reports a lifecycle transition that is not allowed, such as reactivating
+// a decommissioned device.
+type StatusTransitionError struct {
+	DeviceID uuid.UUID
+	From, To DeviceStatus
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("device %s cannot change status from %s to %s", e.DeviceID, e.From, e.To)
+}
+
+// LifecycleState returns a consistent snapshot of the device's status and the time it was
+// decommissioned, which is zero unless the device is decommissioned.
+func (device *SignatureDevice) LifecycleState() (DeviceStatus, time.Time) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	return device.status(), device.DecommissionedAt
+}
+
+// ChangeStatus moves the device to the given status. Decommissioning records the given time
+// and cannot be undone. Once ChangeStatus returns, this device makes no further signatures unless
+// it is active. Other copies of the device, such as those of other instances sharing a database,
+// only stop once the new status is stored: the SQL repositories refuse transactions of devices
+// whose stored status is not active.
+func (device *SignatureDevice) ChangeStatus(status DeviceStatus, at time.Time) error {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	current := device.status()
+	if !canTransition(current, status) {
+		return &StatusTransitionError{DeviceID: device.ID, From: current, To: status}
+	}
+	if current == status {
+		return nil
+	}
+
+	device.Status = status
+	if status == DeviceStatusDecommissioned {
+		device.DecommissionedAt = at.UTC()
+	}
+	return nil
+}
+
+// SyncStoredState adopts the label, metadata and lifecycle state of a stored record written elsewhere,
+// e.g. by another service instance, if the record is newer than the device, and moves the
+// version forward.
+func (device *SignatureDevice) SyncStoredState(
+	version int,
+	label string,
+	metadata map[string]string,
+	status DeviceStatus,
+	decommissionedAt time.Time,
+) {
+	device.mu.Lock()
+	defer device.mu.Unlock()
+
+	if version <= device.Version {
+		return
+	}
+
+	device.Version = version
+	device.Label = label
+	device.Metadata = copyMetadata(metadata)
+	if device.status() != DeviceStatusDecommissioned {
+		device.Status = status
+		device.DecommissionedAt = decommissionedAt
+	}
+}
+
+// status returns the lifecycle status, treating devices stored before statuses existed as active.
+// The caller must hold device.mu.
+func (device *SignatureDevice) status() DeviceStatus {
+	if device.Status == "" {
+		return DeviceStatusActive
+	}
+	return device.Status
+}
@@ -0,0 +1,198 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// SecuredDataFormat names the layout in which a device embeds the signature counter, the data
+// and the previous signature into the secured data it signs.
+type SecuredDataFormat string
+
+const (
+	// SecuredDataFormatLegacy is <counter>_<data>_<last_signature_base64>. Data containing "_" can only be
+	// told apart from the signature because base64 never contains "_".
+	SecuredDataFormatLegacy SecuredDataFormat = "LEGACY"
+	// SecuredDataFormatLengthPrefixed is <counter>:<byte length of data>:<data>:<last_signature_base64>,
+	// which is unambiguous whatever the data contains.
+	SecuredDataFormatLengthPrefixed SecuredDataFormat = "LENGTH_PREFIXED"
+	// SecuredDataFormatJSON is the RFC 8785 canonical JSON object
+	// {"counter":<counter>,"data":<data>,"last_signature":<last_signature_base64>}.
+	SecuredDataFormatJSON SecuredDataFormat = "JSON"
+)
+
+// ParseSecuredDataFormat validates a secured data format given by name. An empty name selects the legacy format.
+func ParseSecuredDataFormat(name string) (SecuredDataFormat, error) {
+	switch format := SecuredDataFormat(name); format {
+	case "":
+		return SecuredDataFormatLegacy, nil
+	case SecuredDataFormatLegacy, SecuredDataFormatLengthPrefixed, SecuredDataFormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown secured data format %q", name)
+	}
+}
+
+// SecuredData is the content of a secured data string.
+type SecuredData struct {
+	Counter int
+	Data    string
+	// LastSignature is the previous signature, or the device ID for the first signature of a chain.
+	LastSignature []byte
+}
+
+// SecuredDataFormatter lays out secured data in one format and decomposes it again.
+// Parse only accepts strings exactly as Format produces them.
+type SecuredDataFormatter interface {
+	Format(securedData SecuredData) string
+	Parse(securedData string) (SecuredData, error)
+}
+
+// Formatter returns the formatter of the format. Devices stored before formats could be chosen
+// have no format and use the legacy one.
+func (format SecuredDataFormat) Formatter() SecuredDataFormatter {
+	switch format {
+	case SecuredDataFormatLengthPrefixed:
+		return lengthPrefixedFormatter{}
+	case SecuredDataFormatJSON:
+		return jsonFormatter{}
+	default:
+		return legacyFormatter{}
+	}
+}
+
+// ChainReference returns what the secured data at the given counter of a device's chain embeds:
+// the last signature, or the device ID for the first signature (counter 0).
+func ChainReference(deviceID uuid.UUID, counter int, lastSignature []byte) []byte {
+	if counter == 0 {
+		return []byte(deviceID.String())
+	}
+	return lastSignature
+}
+
+type legacyFormatter struct{}
+
+func (legacyFormatter) Format(securedData SecuredData) string {
+	return fmt.Sprintf("%d_%s_%s",
+		securedData.Counter, securedData.Data, base64.StdEncoding.EncodeToString(securedData.LastSignature))
+}
+
+func (legacyFormatter) Parse(securedData string) (SecuredData, error) {
+	prefix, rest, found := strings.Cut(securedData, "_")
+	if !found {
+		return SecuredData{}, errors.New("missing separator after the signature counter")
+	}
+	counter, err := parseCounter(prefix)
+	if err != nil {
+		return SecuredData{}, err
+	}
+
+	separator := strings.LastIndex(rest, "_")
+	if separator < 0 {
+		return SecuredData{}, errors.New("missing separator before the last signature")
+	}
+	lastSignature, err := base64.StdEncoding.Strict().DecodeString(rest[separator+1:])
+	if err != nil {
+		return SecuredData{}, fmt.Errorf("invalid last signature: %w", err)
+	}
+
+	return SecuredData{Counter: counter, Data: rest[:separator], LastSignature: lastSignature}, nil
+}
+
+type lengthPrefixedFormatter struct{}
+
+func (lengthPrefixedFormatter) Format(securedData SecuredData) string {
+	return fmt.Sprintf("%d:%d:%s:%s",
+		securedData.Counter, len(securedData.Data), securedData.Data,
+		base64.StdEncoding.EncodeToString(securedData.LastSignature))
+}
+
+func (lengthPrefixedFormatter) Parse(securedData string) (SecuredData, error) {
+	prefix, rest, found := strings.Cut(securedData, ":")
+	if !found {
+		return SecuredData{}, errors.New("missing separator after the signature counter")
+	}
+	counter, err := parseCounter(prefix)
+	if err != nil {
+		return SecuredData{}, err
+	}
+
+	prefix, rest, found = strings.Cut(rest, ":")
+	if !found {
+		return SecuredData{}, errors.New("missing separator after the data length")
+	}
+	length, err := parseCounter(prefix)
+	if err != nil {
+		return SecuredData{}, fmt.Errorf("invalid data length %q", prefix)
+	}
+	if len(rest) <= length || rest[length] != ':' {
+		return SecuredData{}, fmt.Errorf("data is not followed by a separator after %d bytes", length)
+	}
+
+	lastSignature, err := base64.StdEncoding.Strict().DecodeString(rest[length+1:])
+	if err != nil {
+		return SecuredData{}, fmt.Errorf("invalid last signature: %w", err)
+	}
+
+	return SecuredData{Counter: counter, Data: rest[:length], LastSignature: lastSignature}, nil
+}
+
+type jsonFormatter struct{}
+
+// Format writes the members in the order RFC 8785 sorts them in.
+func (jsonFormatter) Format(securedData SecuredData) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(`{"counter":`)
+	buffer.WriteString(strconv.Itoa(securedData.Counter))
+	buffer.WriteString(`,"data":`)
+	writeCanonicalString(&buffer, securedData.Data)
+	buffer.WriteString(`,"last_signature":`)
+	writeCanonicalString(&buffer, base64.StdEncoding.EncodeToString(securedData.LastSignature))
+	buffer.WriteString(`}`)
+	return buffer.String()
+}
+
+func (formatter jsonFormatter) Parse(securedData string) (SecuredData, error) {
+	var fields struct {
+		Counter       *int    `json:"counter"`
+		Data          *string `json:"data"`
+		LastSignature *string `json:"last_signature"`
+	}
+	decoder := json.NewDecoder(strings.NewReader(securedData))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&fields); err != nil {
+		return SecuredData{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if fields.Counter == nil || fields.Data == nil || fields.LastSignature == nil {
+		return SecuredData{}, errors.New("counter, data and last_signature are required")
+	}
+	if *fields.Counter < 0 {
+		return SecuredData{}, fmt.Errorf("invalid signature counter %d", *fields.Counter)
+	}
+	lastSignature, err := base64.StdEncoding.Strict().DecodeString(*fields.LastSignature)
+	if err != nil {
+		return SecuredData{}, fmt.Errorf("invalid last signature: %w", err)
+	}
+
+	parsed := SecuredData{Counter: *fields.Counter, Data: *fields.Data, LastSignature: lastSignature}
+	if formatter.Format(parsed) != securedData {
+		return SecuredData{}, errors.New("JSON is not in canonical form")
+	}
+	return parsed, nil
+}
+
+// parseCounter parses a non-negative decimal number without sign or leading zeros.
+func parseCounter(value string) (int, error) {
+	counter, err := strconv.Atoi(value)
+	if err != nil || counter < 0 || strconv.Itoa(counter) != value {
+		return 0, fmt.Errorf("invalid signature counter %q", value)
+	}
+	return counter, nil
+}
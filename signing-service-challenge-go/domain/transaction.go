@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Transaction is a single signature issued by a signature device.
+// It captures everything needed to later reproduce and verify the signature chain.
+type Transaction struct {
+	DeviceID    uuid.UUID
+	Counter     int
+	Data        string
+	SecuredData string
+	Signature   []byte
+	CreatedAt   time.Time
+}
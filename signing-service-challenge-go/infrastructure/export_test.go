@@ -0,0 +1,29 @@
+package infrastructure
+
+import "errors"
+
+// FailNextLogWrite makes the next append to the write-ahead log of r write only part of the
+// entry and fail, as a full disk would.
+func FailNextLogWrite(r *FileRepository) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.log = &failingLogFile{logFile: r.log}
+}
+
+type failingLogFile struct {
+	logFile
+	failed bool
+}
+
+func (f *failingLogFile) Write(p []byte) (int, error) {
+	if f.failed {
+		return f.logFile.Write(p)
+	}
+	f.failed = true
+	n, err := f.logFile.Write(p[:len(p)/2])
+	if err != nil {
+		return n, err
+	}
+	return n, errors.New("no space left on device")
+}
@@ -0,0 +1,872 @@
+package infrastructure
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	fileLogName      = "wal.log"
+	fileSnapshotName = "snapshot.json"
+
+	fileEntryDeviceCreated = "device_created"
+	fileEntryDeviceUpdated = "device_updated"
+	fileEntryKeyUpdated    = "key_updated"
+	fileEntryTransaction   = "transaction"
+	fileEntryKeyRotated    = "key_rotated"
+	fileEntryBatch         = "transaction_batch"
+)
+
+// fileDeviceRecord is the persisted state of a signature device.
+type fileDeviceRecord struct {
+	ID               string              `json:"id"`
+	Label            string              `json:"label"`
+	Metadata         map[string]string   `json:"metadata,omitempty"`
+	Algorithm        string              `json:"algorithm,omitempty"`
+	Parameters       crypto.Parameters   `json:"parameters"`
+	SignatureCounter int                 `json:"signature_counter"`
+	LastSignature    []byte              `json:"last_signature,omitempty"`
+	Version          int                 `json:"version"`
+	Status           domain.DeviceStatus `json:"status,omitempty"`
+	DecommissionedAt *time.Time          `json:"decommissioned_at,omitempty"`
+	PrivateKey       []byte              `json:"private_key,omitempty"`
+	KeyGeneration    int                 `json:"key_generation,omitempty"`
+	KeyValidFrom     int                 `json:"key_valid_from,omitempty"`
+	RetiredKeys      []*fileKeyRecord    `json:"retired_keys,omitempty"`
+	// SecuredDataFormat is empty for devices stored before formats could be chosen, which use the legacy one.
+	SecuredDataFormat domain.SecuredDataFormat `json:"secured_data_format,omitempty"`
+}
+
+// fileKeyRecord is the persisted state of a retired key.
+type fileKeyRecord struct {
+	Generation int       `json:"generation"`
+	PublicKey  []byte    `json:"public_key"`
+	ValidFrom  int       `json:"valid_from"`
+	ValidUntil int       `json:"valid_until"`
+	RetiredAt  time.Time `json:"retired_at"`
+}
+
+// fileLogEntry is a single line of the write-ahead log.
+type fileLogEntry struct {
+	Type         string                `json:"type"`
+	Device       *fileDeviceRecord     `json:"device,omitempty"`
+	Transaction  *domain.Transaction   `json:"transaction,omitempty"`
+	Transactions []*domain.Transaction `json:"transactions,omitempty"`
+}
+
+// fileSnapshot is the compacted state of all log entries written before it.
+type fileSnapshot struct {
+	Devices      []*fileDeviceRecord   `json:"devices"`
+	Transactions []*domain.Transaction `json:"transactions"`
+}
+
+// FileRepository persists signature devices and their transactions in a directory without any external dependencies.
+//
+// Every device creation, device update and signed transaction is appended to a write-ahead log as one
+// JSON line and fsync'd before the call returns. On startup the latest snapshot is loaded and the log
+// replayed on top of it; a torn last line left by a crash is discarded. After compactEvery appended
+// entries the whole state is written to a new snapshot in the background and the log is cut down to
+// the entries appended meanwhile. Only copying the device records and swapping the log hold the
+// repository lock, so appends are not blocked while the snapshot is written.
+//
+// All transactions are kept in memory and every snapshot contains all of them, so memory use and
+// the time to write a snapshot grow with the history. The backend is meant for single instances
+// with a moderate number of transactions; larger deployments should use a database.
+//
+// Replaying is idempotent, so a crash between writing a snapshot and replacing the log is harmless.
+type FileRepository struct {
+	dir          string
+	algorithms   *crypto.Registry
+	compactEvery int
+
+	// compactMu serializes compactions, which do not hold mu while the snapshot is written.
+	compactMu   sync.Mutex
+	compactions sync.WaitGroup
+
+	mu sync.Mutex
+	// compacting is set while a compaction started by maybeCompact runs.
+	compacting   bool
+	closed       bool
+	log          logFile
+	logEntries   int
+	records      map[string]*fileDeviceRecord
+	devices      map[string]*domain.SignatureDevice
+	transactions map[string][]*domain.Transaction
+	// logErr is set once a failed append could not be rolled back; no further entries are appended after it.
+	logErr error
+}
+
+// logFile is the write-ahead log file, an *os.File outside of tests.
+type logFile interface {
+	io.WriteSeeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// NewFileRepository opens the repository in dir, creating it if needed, and restores its state.
+// The registry is used to encode and decode the keys of stored devices. A compactEvery of zero
+// or less disables automatic compaction.
+func NewFileRepository(dir string, algorithms *crypto.Registry, compactEvery int) (*FileRepository, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	repository := &FileRepository{
+		dir:          dir,
+		algorithms:   algorithms,
+		compactEvery: compactEvery,
+		records:      make(map[string]*fileDeviceRecord),
+		devices:      make(map[string]*domain.SignatureDevice),
+		transactions: make(map[string][]*domain.Transaction),
+	}
+
+	if err := repository.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := repository.replayLog(); err != nil {
+		return nil, err
+	}
+	if err := repository.restoreDevices(); err != nil {
+		repository.log.Close()
+		return nil, err
+	}
+
+	return repository, nil
+}
+
+// Close waits for a running compaction and closes the write-ahead log.
+func (r *FileRepository) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	r.compactions.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.log.Close()
+}
+
+// Save stores a new device together with its encoded key pair.
+func (r *FileRepository) Save(id string, device *domain.SignatureDevice) error {
+	privateKey, err := encodePrivateKey(r.algorithms, device)
+	if err != nil {
+		return err
+	}
+
+	counter, lastSignature := device.SignatureState()
+	status, decommissionedAt := device.LifecycleState()
+	label, metadata := device.Details()
+	record := &fileDeviceRecord{
+		ID:                id,
+		Label:             label,
+		Metadata:          metadata,
+		Algorithm:         device.Algorithm,
+		Parameters:        device.Parameters,
+		SignatureCounter:  counter,
+		LastSignature:     lastSignature,
+		Version:           device.CurrentVersion(),
+		Status:            status,
+		DecommissionedAt:  fileTime(decommissionedAt),
+		PrivateKey:        privateKey,
+		SecuredDataFormat: device.SecuredDataFormat,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.records[id]; exists {
+		return fmt.Errorf("device with id %s already exists", id)
+	}
+	if err := r.append(&fileLogEntry{Type: fileEntryDeviceCreated, Device: record}); err != nil {
+		return err
+	}
+
+	r.records[id] = record
+	r.devices[id] = device
+	r.maybeCompact()
+	return nil
+}
+
+// GetDeviceById retrieves a device by its ID.
+func (r *FileRepository) GetDeviceById(id string) (*domain.SignatureDevice, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	device, exists := r.devices[id]
+	return device, exists
+}
+
+// UpdateDevice persists the mutable state of an existing device, provided that
+// the device is based on the stored version, and advances the version.
+// The signature counter is already persisted by SaveTransaction.
+//
+// Devices are read and synced outside of r.mu: SignatureDevice.Sign holds the device lock while it
+// saves a transaction, which takes r.mu, so taking a device lock under r.mu could deadlock.
+func (r *FileRepository) UpdateDevice(device *domain.SignatureDevice) error {
+	id := device.ID.String()
+	version := device.CurrentVersion()
+	status, decommissionedAt := device.LifecycleState()
+	label, metadata := device.Details()
+
+	update := &fileDeviceRecord{
+		ID:               id,
+		Label:            label,
+		Metadata:         metadata,
+		Version:          version + 1,
+		Status:           status,
+		DecommissionedAt: fileTime(decommissionedAt),
+	}
+	stored, err := r.appendUpdate(update, version)
+	if err != nil {
+		return err
+	}
+
+	// Both only move forward, so a concurrent newer update cannot be rolled back here.
+	stored.SyncStoredState(version+1, label, metadata, status, decommissionedAt)
+	device.SyncVersion(version + 1)
+	return nil
+}
+
+// appendUpdate logs and applies a device update based on the given version and returns the stored device.
+func (r *FileRepository) appendUpdate(update *fileDeviceRecord, version int) (*domain.SignatureDevice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, exists := r.records[update.ID]
+	if !exists {
+		return nil, fmt.Errorf("device with id %s not found", update.ID)
+	}
+	if record.Version != version {
+		return nil, &VersionConflictError{DeviceID: update.ID, Stored: record.Version, Given: version}
+	}
+
+	if err := r.append(&fileLogEntry{Type: fileEntryDeviceUpdated, Device: update}); err != nil {
+		return nil, err
+	}
+
+	r.applyUpdate(update)
+	r.maybeCompact()
+	return r.devices[update.ID], nil
+}
+
+// UpdatePrivateKey re-encodes and stores the private key of an existing device.
+func (r *FileRepository) UpdatePrivateKey(device *domain.SignatureDevice) error {
+	id := device.ID.String()
+	privateKey, err := encodePrivateKey(r.algorithms, device)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.records[id]; !exists {
+		return fmt.Errorf("device with id %s not found", device.ID)
+	}
+
+	update := &fileDeviceRecord{ID: id, PrivateKey: privateKey}
+	if err := r.append(&fileLogEntry{Type: fileEntryKeyUpdated, Device: update}); err != nil {
+		return err
+	}
+
+	r.records[id].PrivateKey = privateKey
+	r.maybeCompact()
+	return nil
+}
+
+// GetAllDevices returns all stored devices.
+func (r *FileRepository) GetAllDevices() ([]*domain.SignatureDevice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	devices := make([]*domain.SignatureDevice, 0, len(r.devices))
+	for _, device := range r.devices {
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// SaveTransaction appends a transaction to the log and advances the stored signature counter.
+// Transactions have to be saved in counter order without gaps.
+func (r *FileRepository) SaveTransaction(transaction *domain.Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deviceId := transaction.DeviceID.String()
+	record, exists := r.records[deviceId]
+	if !exists {
+		return fmt.Errorf("device with id %s not found", deviceId)
+	}
+	if record.SignatureCounter != transaction.Counter {
+		return &VersionConflictError{DeviceID: deviceId, Stored: record.SignatureCounter, Given: transaction.Counter}
+	}
+
+	if err := r.append(&fileLogEntry{Type: fileEntryTransaction, Transaction: transaction}); err != nil {
+		return err
+	}
+
+	r.applyTransaction(transaction)
+	r.maybeCompact()
+	return nil
+}
+
+// SaveTransactions appends consecutive transactions of a device to the log as a single entry,
+// so that the batch is replayed completely or not at all.
+func (r *FileRepository) SaveTransactions(transactions []*domain.Transaction) error {
+	if err := checkBatch(transactions); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	first := transactions[0]
+	deviceId := first.DeviceID.String()
+	record, exists := r.records[deviceId]
+	if !exists {
+		return fmt.Errorf("device with id %s not found", deviceId)
+	}
+	if record.SignatureCounter != first.Counter {
+		return &VersionConflictError{DeviceID: deviceId, Stored: record.SignatureCounter, Given: first.Counter}
+	}
+
+	if err := r.append(&fileLogEntry{Type: fileEntryBatch, Transactions: transactions}); err != nil {
+		return err
+	}
+
+	for _, transaction := range transactions {
+		r.applyTransaction(transaction)
+	}
+	r.maybeCompact()
+	return nil
+}
+
+// SaveKeyRotation appends the rotation record together with the retired key and the device's new key pair
+// to the log as a single entry, so that they are replayed atomically.
+func (r *FileRepository) SaveKeyRotation(rotation *domain.KeyRotation) error {
+	_, privateKey, retiredKey, err := encodeKeyRotation(r.algorithms, rotation)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	transaction := rotation.Transaction
+	deviceId := transaction.DeviceID.String()
+	record, exists := r.records[deviceId]
+	if !exists {
+		return fmt.Errorf("device with id %s not found", deviceId)
+	}
+	if record.SignatureCounter != transaction.Counter {
+		return &VersionConflictError{DeviceID: deviceId, Stored: record.SignatureCounter, Given: transaction.Counter}
+	}
+
+	update := &fileDeviceRecord{
+		ID:            deviceId,
+		PrivateKey:    privateKey,
+		KeyGeneration: rotation.Current.Generation,
+		KeyValidFrom:  rotation.Current.ValidFrom,
+		RetiredKeys: []*fileKeyRecord{{
+			Generation: rotation.Retired.Generation,
+			PublicKey:  retiredKey,
+			ValidFrom:  rotation.Retired.ValidFrom,
+			ValidUntil: rotation.Retired.ValidUntil,
+			RetiredAt:  rotation.Retired.RetiredAt.UTC(),
+		}},
+	}
+	entry := &fileLogEntry{Type: fileEntryKeyRotated, Device: update, Transaction: transaction}
+	if err := r.append(entry); err != nil {
+		return err
+	}
+
+	r.applyTransaction(transaction)
+	r.applyKeyRotation(update)
+	r.maybeCompact()
+	return nil
+}
+
+// GetTransaction retrieves the transaction of a device with the given counter.
+func (r *FileRepository) GetTransaction(deviceId string, counter int) (*domain.Transaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	transactions := r.transactions[deviceId]
+	if counter < 0 || counter >= len(transactions) {
+		return nil, false
+	}
+	return transactions[counter], true
+}
+
+// GetTransactionsByDevice returns all transactions of a device ordered by counter.
+func (r *FileRepository) GetTransactionsByDevice(deviceId string) ([]*domain.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	transactions := make([]*domain.Transaction, len(r.transactions[deviceId]))
+	copy(transactions, r.transactions[deviceId])
+	return transactions, nil
+}
+
+// GetTransactionsPage returns a page of the transactions of a device ordered by counter and their total number.
+func (r *FileRepository) GetTransactionsPage(deviceId string, offset, limit int) ([]*domain.Transaction, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	transactions := r.transactions[deviceId]
+	return transactionPage(transactions, offset, limit), len(transactions), nil
+}
+
+// Compact writes the current state to a new snapshot and drops the log entries it contains.
+// Entries can be appended while the snapshot is written; they are kept in the log.
+func (r *FileRepository) Compact() error {
+	r.compactMu.Lock()
+	defer r.compactMu.Unlock()
+
+	state, err := r.captureState()
+	if err != nil {
+		return err
+	}
+	if err := r.writeSnapshot(state); err != nil {
+		return err
+	}
+	return r.replaceLog(state.offset, state.entries)
+}
+
+// append writes an entry to the log and syncs it to disk. The caller must hold r.mu.
+// If the entry cannot be written completely and synced, the log is cut back to where the entry
+// started, so that no torn line ends up in front of later entries.
+func (r *FileRepository) append(entry *fileLogEntry) error {
+	if r.logErr != nil {
+		return r.logErr
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	offset, err := r.log.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to determine write-ahead log offset: %w", err)
+	}
+	if _, err := r.log.Write(append(line, '\n')); err != nil {
+		return r.rollback(offset, fmt.Errorf("failed to append to write-ahead log: %w", err))
+	}
+	if err := r.log.Sync(); err != nil {
+		return r.rollback(offset, fmt.Errorf("failed to sync write-ahead log: %w", err))
+	}
+
+	r.logEntries++
+	return nil
+}
+
+// rollback cuts the log back to offset after a failed append and returns the append's error.
+// If the log cannot be restored, all further appends fail. The caller must hold r.mu.
+func (r *FileRepository) rollback(offset int64, cause error) error {
+	if err := r.log.Truncate(offset); err != nil {
+		r.logErr = fmt.Errorf("write-ahead log could not be restored after a failed append: %w", err)
+		return cause
+	}
+	if _, err := r.log.Seek(offset, io.SeekStart); err != nil {
+		r.logErr = fmt.Errorf("write-ahead log could not be restored after a failed append: %w", err)
+		return cause
+	}
+	if err := r.log.Sync(); err != nil {
+		r.logErr = fmt.Errorf("write-ahead log could not be restored after a failed append: %w", err)
+	}
+	return cause
+}
+
+// maybeCompact starts a compaction in the background once the log holds compactEvery entries,
+// unless one is already running. The caller must hold r.mu. The triggering entry is already
+// durable in the log, so a failed compaction is simply retried with the next entry.
+func (r *FileRepository) maybeCompact() {
+	if r.compactEvery <= 0 || r.logEntries < r.compactEvery || r.compacting || r.closed {
+		return
+	}
+
+	r.compacting = true
+	r.compactions.Add(1)
+	go func() {
+		defer r.compactions.Done()
+		_ = r.Compact()
+
+		r.mu.Lock()
+		r.compacting = false
+		r.mu.Unlock()
+	}()
+}
+
+// compactionState is the state a snapshot is written from, captured at a log offset.
+type compactionState struct {
+	devices      []*fileDeviceRecord
+	transactions [][]*domain.Transaction
+	offset       int64
+	entries      int
+}
+
+// captureState copies the current state along with the log offset and the number of entries it covers.
+// Records are copied, as they are updated in place. Transactions are only ever appended, so the
+// slices up to their current length stay valid without holding r.mu.
+func (r *FileRepository) captureState() (*compactionState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.logErr != nil {
+		return nil, r.logErr
+	}
+	offset, err := r.log.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine write-ahead log offset: %w", err)
+	}
+
+	state := &compactionState{
+		devices:      make([]*fileDeviceRecord, 0, len(r.records)),
+		transactions: make([][]*domain.Transaction, 0, len(r.records)),
+		offset:       offset,
+		entries:      r.logEntries,
+	}
+	for id, record := range r.records {
+		copied := *record
+		state.devices = append(state.devices, &copied)
+		state.transactions = append(state.transactions, r.transactions[id])
+	}
+	return state, nil
+}
+
+// writeSnapshot writes a snapshot next to the log and atomically replaces the previous one.
+func (r *FileRepository) writeSnapshot(state *compactionState) error {
+	snapshot := fileSnapshot{
+		Devices:      state.devices,
+		Transactions: []*domain.Transaction{},
+	}
+	for _, transactions := range state.transactions {
+		snapshot.Transactions = append(snapshot.Transactions, transactions...)
+	}
+
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(r.dir, fileSnapshotName)
+	if err := writeFileSynced(path+".tmp", content); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+	return syncDir(r.dir)
+}
+
+// replaceLog replaces the log by a new one holding only the entries appended after offset, which
+// the snapshot does not contain. Once the new log is in place, it is used even if the rename cannot
+// be synced, but no further entries are appended, as a crash could bring back the old log without them.
+func (r *FileRepository) replaceLog(offset int64, entries int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.logErr != nil {
+		return r.logErr
+	}
+	end, err := r.log.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to determine write-ahead log offset: %w", err)
+	}
+
+	path := filepath.Join(r.dir, fileLogName)
+	tail, err := readFileRange(path, offset, end)
+	if err != nil {
+		return fmt.Errorf("failed to read write-ahead log: %w", err)
+	}
+
+	log, err := os.OpenFile(path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := log.Write(tail); err != nil {
+		log.Close()
+		return fmt.Errorf("failed to write write-ahead log: %w", err)
+	}
+	if err := log.Sync(); err != nil {
+		log.Close()
+		return fmt.Errorf("failed to sync write-ahead log: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Close()
+		return fmt.Errorf("failed to replace write-ahead log: %w", err)
+	}
+
+	r.log.Close()
+	r.log = log
+	r.logEntries -= entries
+	if err := syncDir(r.dir); err != nil {
+		r.logErr = fmt.Errorf("write-ahead log could not be replaced durably: %w", err)
+		return r.logErr
+	}
+	return nil
+}
+
+// loadSnapshot restores the state of the latest snapshot, if there is one.
+func (r *FileRepository) loadSnapshot() error {
+	content, err := os.ReadFile(filepath.Join(r.dir, fileSnapshotName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snapshot fileSnapshot
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	for _, record := range snapshot.Devices {
+		r.records[record.ID] = record
+	}
+	for _, transaction := range snapshot.Transactions {
+		r.applyTransaction(transaction)
+	}
+	return nil
+}
+
+// replayLog applies all complete log entries and opens the log for appending.
+// A trailing partial entry, left behind by a crash during a write, is cut off.
+func (r *FileRepository) replayLog() error {
+	log, err := os.OpenFile(filepath.Join(r.dir, fileLogName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+
+	var (
+		reader = bufio.NewReader(log)
+		valid  int64
+	)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Close()
+			return err
+		}
+
+		var entry fileLogEntry
+		if err := json.Unmarshal(bytes.TrimSpace(line), &entry); err != nil {
+			// Only the last line can be torn; anything else is corruption.
+			if _, peekErr := reader.Peek(1); peekErr != io.EOF {
+				log.Close()
+				return fmt.Errorf("corrupt write-ahead log entry at offset %d: %w", valid, err)
+			}
+			break
+		}
+		if err := r.apply(&entry); err != nil {
+			log.Close()
+			return err
+		}
+
+		valid += int64(len(line))
+		r.logEntries++
+	}
+
+	if err := log.Truncate(valid); err != nil {
+		log.Close()
+		return err
+	}
+	if _, err := log.Seek(valid, io.SeekStart); err != nil {
+		log.Close()
+		return err
+	}
+
+	r.log = log
+	return nil
+}
+
+// apply replays a single log entry. Entries already contained in the snapshot are skipped.
+func (r *FileRepository) apply(entry *fileLogEntry) error {
+	switch entry.Type {
+	case fileEntryDeviceCreated:
+		if _, exists := r.records[entry.Device.ID]; !exists {
+			r.records[entry.Device.ID] = entry.Device
+		}
+		return nil
+	case fileEntryDeviceUpdated:
+		if record, exists := r.records[entry.Device.ID]; exists && record.Version < entry.Device.Version {
+			r.applyUpdate(entry.Device)
+		}
+		return nil
+	case fileEntryKeyUpdated:
+		if record, exists := r.records[entry.Device.ID]; exists {
+			record.PrivateKey = entry.Device.PrivateKey
+		}
+		return nil
+	case fileEntryTransaction:
+		if record, exists := r.records[entry.Transaction.DeviceID.String()]; exists &&
+			record.SignatureCounter == entry.Transaction.Counter {
+			r.applyTransaction(entry.Transaction)
+		}
+		return nil
+	case fileEntryKeyRotated:
+		if record, exists := r.records[entry.Transaction.DeviceID.String()]; exists &&
+			record.SignatureCounter == entry.Transaction.Counter {
+			r.applyTransaction(entry.Transaction)
+			r.applyKeyRotation(entry.Device)
+		}
+		return nil
+	case fileEntryBatch:
+		if len(entry.Transactions) == 0 {
+			return nil
+		}
+		first := entry.Transactions[0]
+		if record, exists := r.records[first.DeviceID.String()]; exists && record.SignatureCounter == first.Counter {
+			for _, transaction := range entry.Transactions {
+				r.applyTransaction(transaction)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown write-ahead log entry type %q", entry.Type)
+	}
+}
+
+// restoreDevices builds the devices from the records restored by loadSnapshot and replayLog.
+func (r *FileRepository) restoreDevices() error {
+	for id, record := range r.records {
+		device := &domain.SignatureDevice{
+			Label:            record.Label,
+			Metadata:         record.Metadata,
+			Algorithm:        record.Algorithm,
+			Parameters:       record.Parameters,
+			SignatureCounter: record.SignatureCounter,
+			LastSignature:    record.LastSignature,
+			Version:          record.Version,
+			Status:           record.Status,
+			KeyGeneration:    record.KeyGeneration,
+			KeyValidFrom:     record.KeyValidFrom,
+		}
+		if record.DecommissionedAt != nil {
+			device.DecommissionedAt = *record.DecommissionedAt
+		}
+
+		var err error
+		if device.SecuredDataFormat, err = domain.ParseSecuredDataFormat(string(record.SecuredDataFormat)); err != nil {
+			return err
+		}
+		if device.ID, err = uuid.Parse(id); err != nil {
+			return err
+		}
+		if err := restoreKeys(r.algorithms, device, record.PrivateKey); err != nil {
+			return err
+		}
+		for _, key := range record.RetiredKeys {
+			retired := domain.KeyGeneration{
+				Generation: key.Generation,
+				ValidFrom:  key.ValidFrom,
+				ValidUntil: key.ValidUntil,
+				RetiredAt:  key.RetiredAt,
+			}
+			if err := restoreRetiredKey(r.algorithms, device, retired, key.PublicKey); err != nil {
+				return err
+			}
+		}
+		r.devices[id] = device
+	}
+	return nil
+}
+
+// applyUpdate adopts the label, metadata, lifecycle state and version of a device update.
+func (r *FileRepository) applyUpdate(update *fileDeviceRecord) {
+	record := r.records[update.ID]
+	record.Label = update.Label
+	record.Metadata = update.Metadata
+	record.Version = update.Version
+	record.Status = update.Status
+	record.DecommissionedAt = update.DecommissionedAt
+}
+
+// applyKeyRotation adopts the new key pair of a device and records its retired key.
+func (r *FileRepository) applyKeyRotation(update *fileDeviceRecord) {
+	record := r.records[update.ID]
+	record.PrivateKey = update.PrivateKey
+	record.KeyGeneration = update.KeyGeneration
+	record.KeyValidFrom = update.KeyValidFrom
+	record.RetiredKeys = append(record.RetiredKeys, update.RetiredKeys...)
+}
+
+// applyTransaction records a transaction and advances the stored signature counter.
+// It only touches the records, as SaveTransaction runs while the device itself is locked.
+func (r *FileRepository) applyTransaction(transaction *domain.Transaction) {
+	deviceId := transaction.DeviceID.String()
+	r.transactions[deviceId] = append(r.transactions[deviceId], transaction)
+
+	record := r.records[deviceId]
+	record.SignatureCounter = transaction.Counter + 1
+	record.LastSignature = transaction.Signature
+}
+
+// fileTime encodes an optional timestamp; the zero time is omitted.
+func fileTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func writeFileSynced(path string, content []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readFileRange reads the bytes of a file from offset up to end.
+func readFileRange(path string, offset, end int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	content := make([]byte, end-offset)
+	if _, err := file.ReadAt(content, offset); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// syncDir makes a rename within dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
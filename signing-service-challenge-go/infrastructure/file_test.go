@@ -0,0 +1,431 @@
+package infrastructure_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/infrastructure"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+)
+
+func openFileServices(t *testing.T, dir string, compactEvery int) (*infrastructure.FileRepository, *service.DeviceService, *service.TransactionService) {
+	repository, err := infrastructure.NewFileRepository(dir, crypto.DefaultRegistry, compactEvery)
+	if err != nil {
+		t.Fatalf("Failed to open file repository: %v", err)
+	}
+	return repository,
+		service.NewDeviceService(repository, crypto.DefaultRegistry),
+		service.NewTransactionService(repository, repository)
+}
+
+func TestFileRepositorySurvivesRestart(t *testing.T) {
+	for _, compactEvery := range []int{0, 3} {
+		dir := t.TempDir()
+
+		repository, deviceService, transactionService := openFileServices(t, dir, compactEvery)
+		device, err := deviceService.CreateSignatureDevice("ECC", "edge", crypto.Parameters{})
+		if err != nil {
+			t.Fatalf("Failed to create device: %v", err)
+		}
+		deviceId := device.ID.String()
+
+		for _, data := range []string{"first", "second", "third", "fourth"} {
+			if _, err := transactionService.SignTransaction(deviceId, data); err != nil {
+				t.Fatalf("Failed to sign transaction: %v", err)
+			}
+		}
+		if err := repository.Close(); err != nil {
+			t.Fatalf("Failed to close repository: %v", err)
+		}
+
+		if compactEvery > 0 {
+			if _, err := os.Stat(filepath.Join(dir, "snapshot.json")); err != nil {
+				t.Errorf("Expected a snapshot to be written: %v", err)
+			}
+		}
+
+		repository, deviceService, transactionService = openFileServices(t, dir, compactEvery)
+
+		restored, exists := deviceService.GetDevice(deviceId)
+		if !exists {
+			t.Fatalf("Device %s not found after reopening", deviceId)
+		}
+		if restored.Label != "edge" || restored.SignatureCounter != 4 {
+			t.Errorf("Expected label %q and counter 4, got %q and %d", "edge", restored.Label, restored.SignatureCounter)
+		}
+
+		transaction, err := transactionService.SignTransaction(deviceId, "fifth")
+		if err != nil {
+			t.Fatalf("Failed to sign after reopening: %v", err)
+		}
+		if transaction.Counter != 4 {
+			t.Errorf("Expected counter 4 after reopening, got %d", transaction.Counter)
+		}
+
+		report, err := transactionService.AuditDevice(deviceId)
+		if err != nil {
+			t.Fatalf("Failed to audit device: %v", err)
+		}
+		if !report.Valid || report.TransactionsChecked != 5 {
+			t.Errorf("Expected an intact chain of 5 transactions, got %+v", report)
+		}
+		repository.Close()
+	}
+}
+
+func TestFileRepositoryDiscardsTornLogEntry(t *testing.T) {
+	dir := t.TempDir()
+
+	repository, deviceService, transactionService := openFileServices(t, dir, 0)
+	device, err := deviceService.CreateSignatureDevice("ED25519", "", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	deviceId := device.ID.String()
+	if _, err := transactionService.SignTransaction(deviceId, "kept"); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	repository.Close()
+
+	// Simulate a crash in the middle of appending an entry.
+	log, err := os.OpenFile(filepath.Join(dir, "wal.log"), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	log.WriteString(`{"type":"transaction","transaction":{"DeviceID":"`)
+	log.Close()
+
+	repository, _, transactionService = openFileServices(t, dir, 0)
+	transaction, err := transactionService.SignTransaction(deviceId, "after crash")
+	if err != nil {
+		t.Fatalf("Failed to sign after recovering: %v", err)
+	}
+	if transaction.Counter != 1 {
+		t.Errorf("Expected counter 1 after recovering, got %d", transaction.Counter)
+	}
+
+	repository.Close()
+	repository, _, transactionService = openFileServices(t, dir, 0)
+	defer repository.Close()
+
+	report, err := transactionService.AuditDevice(deviceId)
+	if err != nil {
+		t.Fatalf("Failed to audit device: %v", err)
+	}
+	if !report.Valid || report.TransactionsChecked != 2 {
+		t.Errorf("Expected an intact chain of 2 transactions, got %+v", report)
+	}
+}
+
+func TestFileRepositoryRollsBackFailedAppends(t *testing.T) {
+	dir := t.TempDir()
+
+	repository, deviceService, transactionService := openFileServices(t, dir, 0)
+	device, err := deviceService.CreateSignatureDevice("ED25519", "", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	deviceId := device.ID.String()
+	if _, err := transactionService.SignTransaction(deviceId, "before"); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+
+	infrastructure.FailNextLogWrite(repository)
+	if _, err := transactionService.SignTransaction(deviceId, "lost"); err == nil {
+		t.Fatal("Expected signing to fail when the log cannot be written")
+	}
+
+	transaction, err := transactionService.SignTransaction(deviceId, "after")
+	if err != nil {
+		t.Fatalf("Failed to sign after a failed append: %v", err)
+	}
+	if transaction.Counter != 1 {
+		t.Errorf("Expected the failed signature not to use up counter 1, got %d", transaction.Counter)
+	}
+	repository.Close()
+
+	// The partial entry must not be left in front of the entries written after it.
+	repository, _, transactionService = openFileServices(t, dir, 0)
+	defer repository.Close()
+
+	report, err := transactionService.AuditDevice(deviceId)
+	if err != nil {
+		t.Fatalf("Failed to audit device: %v", err)
+	}
+	if !report.Valid || report.TransactionsChecked != 2 {
+		t.Errorf("Expected an intact chain of 2 transactions, got %+v", report)
+	}
+}
+
+func TestFileRepositoryConcurrentSignsAndUpdates(t *testing.T) {
+	// Not closed on failure, as Close would block on a deadlocked repository.
+	repository, deviceService, transactionService := openFileServices(t, t.TempDir(), 0)
+
+	device, err := deviceService.CreateSignatureDevice("ED25519", "", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	deviceId := device.ID.String()
+
+	// Signs and updates may lose against concurrent ones; only progress and the chain matter here.
+	const goroutines = 1000
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			transactionService.SignTransaction(deviceId, "data")
+		}()
+		go func(i int) {
+			defer wg.Done()
+			label := fmt.Sprintf("till %d", i)
+			deviceService.UpdateDevice(deviceId, service.DeviceUpdate{Label: &label})
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("Concurrent signs and updates did not finish, the repository deadlocked")
+	}
+
+	report, err := transactionService.AuditDevice(deviceId)
+	if err != nil {
+		t.Fatalf("Failed to audit device: %v", err)
+	}
+	if !report.Valid || report.TransactionsChecked == 0 {
+		t.Errorf("Expected an intact chain, got %+v", report)
+	}
+	repository.Close()
+}
+
+func TestFileRepositoryCompactsWhileSigning(t *testing.T) {
+	const (
+		devices      = 8
+		transactions = 100
+	)
+	dir := t.TempDir()
+
+	repository, deviceService, transactionService := openFileServices(t, dir, 10)
+	deviceIds := make([]string, devices)
+	for i := range deviceIds {
+		device, err := deviceService.CreateSignatureDevice("ED25519", "", crypto.Parameters{})
+		if err != nil {
+			t.Fatalf("Failed to create device: %v", err)
+		}
+		deviceIds[i] = device.ID.String()
+	}
+
+	// Compactions run in the background while all devices keep signing.
+	errs := make(chan error, devices)
+	for _, deviceId := range deviceIds {
+		go func(deviceId string) {
+			for i := 0; i < transactions; i++ {
+				if _, err := transactionService.SignTransaction(deviceId, fmt.Sprintf("data %d", i)); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}(deviceId)
+	}
+	for range deviceIds {
+		if err := <-errs; err != nil {
+			t.Fatalf("Failed to sign transaction: %v", err)
+		}
+	}
+	repository.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "snapshot.json")); err != nil {
+		t.Fatalf("Expected a snapshot to be written: %v", err)
+	}
+
+	repository, _, transactionService = openFileServices(t, dir, 10)
+	defer repository.Close()
+
+	for _, deviceId := range deviceIds {
+		report, err := transactionService.AuditDevice(deviceId)
+		if err != nil {
+			t.Fatalf("Failed to audit device: %v", err)
+		}
+		if !report.Valid || report.TransactionsChecked != transactions {
+			t.Errorf("Expected an intact chain of %d transactions, got %+v", transactions, report)
+		}
+	}
+}
+
+func TestFileRepositorySealsAndRewrapsPrivateKeys(t *testing.T) {
+	dir := t.TempDir()
+	oldKEK, newKEK := newTestKEK(t), newTestKEK(t)
+
+	// Start with plaintext keys, as written before encryption at rest was enabled.
+	repository, deviceService, _ := openFileServices(t, dir, 0)
+	device, err := deviceService.CreateSignatureDevice("RSA", "", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	deviceId := device.ID.String()
+	repository.Close()
+
+	// Seal all keys under the old key-encryption key, then rotate to the new one.
+	for _, sealer := range []*crypto.KeySealer{crypto.NewKeySealer(oldKEK), crypto.NewKeySealer(newKEK, oldKEK)} {
+		repository, err := infrastructure.NewFileRepository(dir, crypto.NewSealedRegistry(crypto.DefaultRegistry, sealer), 0)
+		if err != nil {
+			t.Fatalf("Failed to open file repository: %v", err)
+		}
+		if _, err := service.NewDeviceService(repository, crypto.DefaultRegistry).RewrapPrivateKeys(sealer); err != nil {
+			t.Fatalf("Failed to re-wrap private keys: %v", err)
+		}
+		if err := repository.Compact(); err != nil {
+			t.Fatalf("Failed to compact repository: %v", err)
+		}
+		repository.Close()
+	}
+
+	snapshot, err := os.ReadFile(filepath.Join(dir, "snapshot.json"))
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	if bytes.Contains(snapshot, []byte("RSA_PRIVATE_KEY")) {
+		t.Error("Expected no plaintext private key in the snapshot")
+	}
+
+	// The old key-encryption key is no longer needed.
+	sealer := crypto.NewKeySealer(newKEK)
+	repository, err = infrastructure.NewFileRepository(dir, crypto.NewSealedRegistry(crypto.DefaultRegistry, sealer), 0)
+	if err != nil {
+		t.Fatalf("Failed to open file repository: %v", err)
+	}
+	defer repository.Close()
+
+	transaction, err := service.NewTransactionService(repository, repository).SignTransaction(deviceId, "data")
+	if err != nil {
+		t.Fatalf("Failed to sign with re-wrapped key: %v", err)
+	}
+	restored, _ := repository.GetDeviceById(deviceId)
+	valid, err := restored.Verifier.Verify([]byte(transaction.SecuredData), transaction.Signature)
+	if err != nil || !valid {
+		t.Errorf("Expected signature to verify, got %v (%v)", valid, err)
+	}
+}
+
+func newTestKEK(t *testing.T) *crypto.KeyEncryptionKey {
+	key := make([]byte, crypto.KeyEncryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("Failed to generate key-encryption key: %v", err)
+	}
+	kek, err := crypto.NewKeyEncryptionKey(key)
+	if err != nil {
+		t.Fatalf("Failed to create key-encryption key: %v", err)
+	}
+	return kek
+}
+
+func TestFileRepositoryPersistsDeviceUpdates(t *testing.T) {
+	for _, compactEvery := range []int{0, 1} {
+		dir := t.TempDir()
+		repository, deviceService, _ := openFileServices(t, dir, compactEvery)
+
+		device, err := deviceService.CreateSignatureDevice("ED25519", "till", crypto.Parameters{})
+		if err != nil {
+			t.Fatalf("Failed to create device: %v", err)
+		}
+		deviceId := device.ID.String()
+		if _, err := deviceService.ChangeDeviceStatus(deviceId, domain.DeviceStatusSuspended); err != nil {
+			t.Fatalf("Failed to suspend device: %v", err)
+		}
+		storeId := "S-17"
+		update := service.DeviceUpdate{Metadata: map[string]*string{"store_id": &storeId}}
+		if _, err := deviceService.UpdateDevice(deviceId, update); err != nil {
+			t.Fatalf("Failed to update device: %v", err)
+		}
+		repository.Close()
+
+		repository, deviceService, transactionService := openFileServices(t, dir, compactEvery)
+
+		restored, _ := deviceService.GetDevice(deviceId)
+		if status, _ := restored.LifecycleState(); status != domain.DeviceStatusSuspended {
+			t.Fatalf("Expected a suspended device, got %s", status)
+		}
+		if _, metadata := restored.Details(); metadata["store_id"] != storeId {
+			t.Errorf("Expected store ID %q, got %v", storeId, metadata)
+		}
+		if _, err := transactionService.SignTransaction(deviceId, "data"); err == nil {
+			t.Error("Expected a suspended device to refuse signing")
+		}
+		repository.Close()
+	}
+}
+
+func TestFileRepositoryPersistsKeyRotation(t *testing.T) {
+	for _, compactEvery := range []int{0, 2} {
+		dir := t.TempDir()
+		repository, deviceService, transactionService := openFileServices(t, dir, compactEvery)
+
+		device, err := deviceService.CreateSignatureDevice("RSA-PSS", "till", crypto.Parameters{})
+		if err != nil {
+			t.Fatalf("Failed to create device: %v", err)
+		}
+		deviceId := device.ID.String()
+		rotateKey(t, deviceService, transactionService, deviceId)
+		repository.Close()
+
+		repository, deviceService, transactionService = openFileServices(t, dir, compactEvery)
+
+		restored, _ := deviceService.GetDevice(deviceId)
+		if generation := restored.CurrentKeyGeneration(); generation != 2 {
+			t.Fatalf("Expected key generation 2, got %d", generation)
+		}
+		assertRotatedChain(t, transactionService, deviceId)
+		repository.Close()
+	}
+}
+
+func TestFileRepositoryPersistsBatches(t *testing.T) {
+	for _, compactEvery := range []int{0, 2} {
+		dir := t.TempDir()
+
+		repository, deviceService, transactionService := openFileServices(t, dir, compactEvery)
+		device, err := deviceService.CreateSignatureDevice("ED25519", "", crypto.Parameters{})
+		if err != nil {
+			t.Fatalf("Failed to create device: %v", err)
+		}
+		deviceId := device.ID.String()
+
+		if _, err := transactionService.SignTransaction(deviceId, "single"); err != nil {
+			t.Fatalf("Failed to sign transaction: %v", err)
+		}
+		if _, err := transactionService.SignTransactions(deviceId, []string{"first", "second", "third"}); err != nil {
+			t.Fatalf("Failed to sign batch: %v", err)
+		}
+		repository.Close()
+
+		repository, deviceService, transactionService = openFileServices(t, dir, compactEvery)
+
+		restored, _ := deviceService.GetDevice(deviceId)
+		if restored.SignatureCounter != 4 {
+			t.Errorf("Expected counter 4 after reopening, got %d", restored.SignatureCounter)
+		}
+		report, err := transactionService.AuditDevice(deviceId)
+		if err != nil {
+			t.Fatalf("Failed to audit device: %v", err)
+		}
+		if !report.Valid || report.TransactionsChecked != 4 {
+			t.Errorf("Expected an intact chain of 4 transactions, got %+v", report)
+		}
+		repository.Close()
+	}
+}
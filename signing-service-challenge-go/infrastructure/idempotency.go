@@ -0,0 +1,97 @@
+package infrastructure
+
+import (
+	"sync"
+	"time"
+)
+
+// IdempotencyRecord remembers the request an idempotency key was first used with and, once the
+// request succeeded, the signature counter of the transaction it produced.
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request body the key was first used with.
+	Fingerprint string
+	Counter     int
+	// Completed is false while the first request with the key is still being processed.
+	Completed bool
+	ExpiresAt time.Time
+}
+
+// IdempotencyStore tracks idempotency keys of sign requests until they expire.
+// Reserve claims an unused key and returns true; if the key is already in use, the
+// existing record is returned instead. A reserved key is either completed with the
+// counter of the signed transaction or released again if signing failed.
+type IdempotencyStore interface {
+	Reserve(key, fingerprint string) (IdempotencyRecord, bool)
+	Complete(key string, counter int)
+	Release(key string)
+}
+
+// InMemoryIdempotencyStore provides thread-safe in-memory storage for idempotency keys.
+// Keys are forgotten once their window has passed, or when the process restarts.
+type InMemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	window    time.Duration
+	records   map[string]IdempotencyRecord
+	lastPurge time.Time
+}
+
+// NewInMemoryIdempotencyStore initializes a new InMemoryIdempotencyStore whose keys expire after window.
+func NewInMemoryIdempotencyStore(window time.Duration) *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{
+		window:    window,
+		records:   make(map[string]IdempotencyRecord),
+		lastPurge: time.Now(),
+	}
+}
+
+// Reserve claims key for a request with the given fingerprint, unless an unexpired record exists for it.
+func (s *InMemoryIdempotencyStore) Reserve(key, fingerprint string) (IdempotencyRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.purgeExpired(now)
+
+	if record, exists := s.records[key]; exists && now.Before(record.ExpiresAt) {
+		return record, false
+	}
+
+	record := IdempotencyRecord{Fingerprint: fingerprint, ExpiresAt: now.Add(s.window)}
+	s.records[key] = record
+	return record, true
+}
+
+// Complete records the counter of the transaction signed for a reserved key.
+func (s *InMemoryIdempotencyStore) Complete(key string, counter int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, exists := s.records[key]; exists {
+		record.Counter = counter
+		record.Completed = true
+		s.records[key] = record
+	}
+}
+
+// Release frees a reserved key whose request failed, so that it can be retried.
+func (s *InMemoryIdempotencyStore) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, exists := s.records[key]; exists && !record.Completed {
+		delete(s.records, key)
+	}
+}
+
+// purgeExpired drops expired records at most once per window. The caller must hold s.mu.
+func (s *InMemoryIdempotencyStore) purgeExpired(now time.Time) {
+	if now.Sub(s.lastPurge) < s.window {
+		return
+	}
+	for key, record := range s.records {
+		if !now.Before(record.ExpiresAt) {
+			delete(s.records, key)
+		}
+	}
+	s.lastPurge = now
+}
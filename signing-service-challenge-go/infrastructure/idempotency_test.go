@@ -0,0 +1,53 @@
+package infrastructure_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/infrastructure"
+)
+
+func TestInMemoryIdempotencyStore(t *testing.T) {
+	store := infrastructure.NewInMemoryIdempotencyStore(time.Hour)
+
+	if _, reserved := store.Reserve("key", "fingerprint"); !reserved {
+		t.Fatal("Expected an unused key to be reserved")
+	}
+	record, reserved := store.Reserve("key", "fingerprint")
+	if reserved || record.Completed {
+		t.Fatalf("Expected the key to be in progress, got %+v (reserved %v)", record, reserved)
+	}
+
+	store.Complete("key", 7)
+	record, reserved = store.Reserve("key", "other")
+	if reserved || !record.Completed || record.Counter != 7 || record.Fingerprint != "fingerprint" {
+		t.Fatalf("Expected the completed record of the first request, got %+v (reserved %v)", record, reserved)
+	}
+
+	store.Release("key")
+	if _, reserved := store.Reserve("key", "fingerprint"); reserved {
+		t.Fatal("Expected completed keys not to be released")
+	}
+
+	if _, reserved := store.Reserve("failed", "fingerprint"); !reserved {
+		t.Fatal("Expected an unused key to be reserved")
+	}
+	store.Release("failed")
+	if _, reserved := store.Reserve("failed", "fingerprint"); !reserved {
+		t.Fatal("Expected a released key to be reserved again")
+	}
+}
+
+func TestInMemoryIdempotencyStoreExpiresKeys(t *testing.T) {
+	store := infrastructure.NewInMemoryIdempotencyStore(10 * time.Millisecond)
+
+	store.Reserve("key", "fingerprint")
+	store.Complete("key", 0)
+
+	time.Sleep(20 * time.Millisecond)
+
+	record, reserved := store.Reserve("key", "other")
+	if !reserved || record.Fingerprint != "other" {
+		t.Fatalf("Expected the expired key to be reserved anew, got %+v (reserved %v)", record, reserved)
+	}
+}
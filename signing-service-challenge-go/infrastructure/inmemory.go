@@ -10,14 +10,16 @@ import (
 
 // InMemoryRepository provides thread-safe in-memory storage for signature devices.
 type InMemoryRepository struct {
-	mu      sync.RWMutex
-	devices map[string]*domain.SignatureDevice
+	mu       sync.RWMutex
+	devices  map[string]*domain.SignatureDevice
+	versions map[string]int
 }
 
 // NewInMemoryRepository initializes a new InMemoryRepository.
 func NewInMemoryRepository() *InMemoryRepository {
 	return &InMemoryRepository{
-		devices: make(map[string]*domain.SignatureDevice),
+		devices:  make(map[string]*domain.SignatureDevice),
+		versions: make(map[string]int),
 	}
 }
 
@@ -31,6 +33,7 @@ func (s *InMemoryRepository) Save(id string, device *domain.SignatureDevice) err
 	}
 
 	s.devices[id] = device
+	s.versions[id] = device.CurrentVersion()
 	return nil
 }
 
@@ -42,14 +45,27 @@ func (s *InMemoryRepository) GetDeviceById(id string) (*domain.SignatureDevice,
 	return device, exists
 }
 
-// UpdateDevice updates the state of an existing device in the store.
+// UpdateDevice updates the state of an existing device in the store, provided that
+// the device is based on the stored version, and advances the version.
 func (s *InMemoryRepository) UpdateDevice(device *domain.SignatureDevice) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, exists := s.devices[device.ID.String()]; !exists {
+
+	id := device.ID.String()
+	if _, exists := s.devices[id]; !exists {
 		return fmt.Errorf("device with id %s not found", device.ID)
 	}
-	s.devices[device.ID.String()] = device
+
+	stored, given := s.versions[id], device.CurrentVersion()
+	if stored != given {
+		return &VersionConflictError{DeviceID: id, Stored: stored, Given: given}
+	}
+
+	label, metadata := device.Details()
+	status, decommissionedAt := device.LifecycleState()
+	s.devices[id].SyncStoredState(stored+1, label, metadata, status, decommissionedAt)
+	s.versions[id] = stored + 1
+	device.SyncVersion(stored + 1)
 	return nil
 }
 
@@ -65,3 +81,82 @@ func (s *InMemoryRepository) GetAllDevices() ([]*domain.SignatureDevice, error)
 
 	return devices, nil
 }
+
+// InMemoryTransactionRepository provides thread-safe in-memory storage for signed transactions.
+type InMemoryTransactionRepository struct {
+	mu           sync.RWMutex
+	transactions map[string][]*domain.Transaction
+}
+
+// NewInMemoryTransactionRepository initializes a new InMemoryTransactionRepository.
+func NewInMemoryTransactionRepository() *InMemoryTransactionRepository {
+	return &InMemoryTransactionRepository{
+		transactions: make(map[string][]*domain.Transaction),
+	}
+}
+
+// SaveTransaction appends a transaction to its device's history.
+// Transactions have to be saved in counter order without gaps.
+func (s *InMemoryTransactionRepository) SaveTransaction(transaction *domain.Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deviceId := transaction.DeviceID.String()
+	if expected := len(s.transactions[deviceId]); transaction.Counter != expected {
+		return &VersionConflictError{DeviceID: deviceId, Stored: expected, Given: transaction.Counter}
+	}
+
+	s.transactions[deviceId] = append(s.transactions[deviceId], transaction)
+	return nil
+}
+
+// SaveTransactions appends consecutive transactions of a device to its history at once.
+// The first transaction has to continue the stored history.
+func (s *InMemoryTransactionRepository) SaveTransactions(transactions []*domain.Transaction) error {
+	if err := checkBatch(transactions); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	first := transactions[0]
+	deviceId := first.DeviceID.String()
+	if expected := len(s.transactions[deviceId]); first.Counter != expected {
+		return &VersionConflictError{DeviceID: deviceId, Stored: expected, Given: first.Counter}
+	}
+
+	s.transactions[deviceId] = append(s.transactions[deviceId], transactions...)
+	return nil
+}
+
+// GetTransaction retrieves the transaction of a device with the given counter.
+func (s *InMemoryTransactionRepository) GetTransaction(deviceId string, counter int) (*domain.Transaction, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	transactions := s.transactions[deviceId]
+	if counter < 0 || counter >= len(transactions) {
+		return nil, false
+	}
+	return transactions[counter], true
+}
+
+// GetTransactionsByDevice returns all transactions of a device ordered by counter.
+func (s *InMemoryTransactionRepository) GetTransactionsByDevice(deviceId string) ([]*domain.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	transactions := make([]*domain.Transaction, len(s.transactions[deviceId]))
+	copy(transactions, s.transactions[deviceId])
+	return transactions, nil
+}
+
+// GetTransactionsPage returns a page of the transactions of a device ordered by counter and their total number.
+func (s *InMemoryTransactionRepository) GetTransactionsPage(deviceId string, offset, limit int) ([]*domain.Transaction, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	transactions := s.transactions[deviceId]
+	return transactionPage(transactions, offset, limit), len(transactions), nil
+}
@@ -0,0 +1,40 @@
+package infrastructure_test
+
+import (
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/infrastructure"
+	"github.com/google/uuid"
+)
+
+func TestInMemoryRepositoryRejectsStaleUpdates(t *testing.T) {
+	repository := infrastructure.NewInMemoryRepository()
+
+	id := uuid.New()
+	device := &domain.SignatureDevice{ID: id, Label: "original"}
+	if err := repository.Save(id.String(), device); err != nil {
+		t.Fatalf("Failed to save device: %v", err)
+	}
+
+	// A second copy read at the same version as the stored device.
+	stale := &domain.SignatureDevice{ID: id, Label: "stale"}
+
+	device.Label = "updated"
+	if err := repository.UpdateDevice(device); err != nil {
+		t.Fatalf("Failed to update device: %v", err)
+	}
+	if device.Version != 1 {
+		t.Errorf("Expected version 1 after update, got %d", device.Version)
+	}
+
+	err := repository.UpdateDevice(stale)
+	if !infrastructure.IsVersionConflict(err) {
+		t.Fatalf("Expected a version conflict, got %v", err)
+	}
+
+	stored, _ := repository.GetDeviceById(id.String())
+	if stored.Label != "updated" {
+		t.Errorf("Expected the stale update to be rejected, got label %q", stored.Label)
+	}
+}
@@ -0,0 +1,584 @@
+package infrastructure
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/google/uuid"
+
+	// PostgreSQL driver, registered as "pgx".
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// postgresMigrationLock is the advisory lock key that serializes migrations across service instances.
+const postgresMigrationLock = 0x5349474e
+
+// postgresMigrations are applied in order; the index + 1 is the schema version.
+// Never edit an existing migration, append a new one instead.
+var postgresMigrations = []string{
+	`CREATE TABLE devices (
+		id                 UUID PRIMARY KEY,
+		label              TEXT NOT NULL,
+		algorithm          TEXT NOT NULL,
+		rsa_bits           INTEGER NOT NULL DEFAULT 0,
+		curve              TEXT NOT NULL DEFAULT '',
+		hash               TEXT NOT NULL DEFAULT '',
+		salt_length        INTEGER NOT NULL DEFAULT 0,
+		signature_encoding TEXT NOT NULL DEFAULT '',
+		signature_counter  BIGINT NOT NULL DEFAULT 0,
+		last_signature     BYTEA,
+		public_key         BYTEA NOT NULL,
+		private_key        BYTEA NOT NULL
+	);
+	CREATE TABLE transactions (
+		device_id    UUID NOT NULL REFERENCES devices (id),
+		counter      BIGINT NOT NULL,
+		data         TEXT NOT NULL,
+		secured_data TEXT NOT NULL,
+		signature    BYTEA NOT NULL,
+		created_at   TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (device_id, counter)
+	);`,
+	`ALTER TABLE devices ADD COLUMN version INTEGER NOT NULL DEFAULT 0;`,
+	`ALTER TABLE devices ADD COLUMN status TEXT NOT NULL DEFAULT 'ACTIVE';
+	ALTER TABLE devices ADD COLUMN decommissioned_at TIMESTAMPTZ;`,
+	`ALTER TABLE devices ADD COLUMN metadata JSONB NOT NULL DEFAULT '{}';`,
+	`ALTER TABLE devices ADD COLUMN key_generation INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE devices ADD COLUMN key_valid_from BIGINT NOT NULL DEFAULT 0;
+	CREATE TABLE device_keys (
+		device_id   UUID NOT NULL REFERENCES devices (id),
+		generation  INTEGER NOT NULL,
+		public_key  BYTEA NOT NULL,
+		valid_from  BIGINT NOT NULL,
+		valid_until BIGINT NOT NULL,
+		retired_at  TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (device_id, generation)
+	);`,
+	`ALTER TABLE devices ADD COLUMN secured_data_format TEXT NOT NULL DEFAULT 'LEGACY';`,
+}
+
+// PostgresRepository stores signature devices and their transactions in PostgreSQL and
+// can be shared by several service instances.
+//
+// The database is the source of truth for the signature counter: SaveTransaction locks the
+// device row with SELECT ... FOR UPDATE and only accepts the transaction if it continues the
+// stored chain, so two instances can never issue the same counter. A stale instance gets a
+// *VersionConflictError instead and picks up the current state the next time it fetches the device.
+type PostgresRepository struct {
+	db         *sql.DB
+	algorithms *crypto.Registry
+
+	mu      sync.Mutex
+	devices map[string]*domain.SignatureDevice
+}
+
+// NewPostgresRepository connects to the PostgreSQL database described by dsn and migrates it to the latest schema.
+// The registry is used to encode and decode the keys of stored devices.
+func NewPostgresRepository(dsn string, algorithms *crypto.Registry) (*PostgresRepository, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migratePostgres(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PostgresRepository{
+		db:         db,
+		algorithms: algorithms,
+		devices:    make(map[string]*domain.SignatureDevice),
+	}, nil
+}
+
+// Close closes the underlying connection pool.
+func (p *PostgresRepository) Close() error {
+	return p.db.Close()
+}
+
+// migratePostgres applies all migrations newer than the database's schema version in a single
+// transaction. An advisory lock keeps instances starting at the same time from racing.
+func migratePostgres(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, postgresMigrationLock); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var version int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := version; i < len(postgresMigrations); i++ {
+		if _, err := tx.Exec(postgresMigrations[i]); err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, i+1); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", i+1, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Save stores a new device together with its encoded key pair.
+func (p *PostgresRepository) Save(id string, device *domain.SignatureDevice) error {
+	algorithm, exists := p.algorithms.Get(device.Algorithm)
+	if !exists {
+		return fmt.Errorf("algorithm %s is not registered", device.Algorithm)
+	}
+
+	publicKey, privateKey, err := algorithm.Marshaler.Marshal(crypto.KeyPair{
+		Public:  device.PublicKey,
+		Private: device.PrivateKey,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode keys of device %s: %w", id, err)
+	}
+
+	counter, lastSignature := device.SignatureState()
+	status, decommissionedAt := device.LifecycleState()
+	label, metadata := device.Details()
+	encodedMetadata, err := encodeMetadata(metadata)
+	if err != nil {
+		return err
+	}
+
+	parameters := device.Parameters
+	_, err = p.db.Exec(
+		`INSERT INTO devices (
+			id, label, algorithm, rsa_bits, curve, hash, salt_length, signature_encoding,
+			signature_counter, last_signature, public_key, private_key, version, status, decommissioned_at, metadata,
+			secured_data_format
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
+		id, label, device.Algorithm,
+		parameters.RSABits, parameters.Curve, parameters.Hash, parameters.SaltLength, parameters.SignatureEncoding,
+		counter, lastSignature, publicKey, privateKey, device.CurrentVersion(),
+		string(status), postgresTime(decommissionedAt), string(encodedMetadata), string(device.SecuredDataFormat),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert device %s: %w", id, err)
+	}
+
+	p.mu.Lock()
+	p.devices[id] = device
+	p.mu.Unlock()
+
+	return nil
+}
+
+// GetDeviceById retrieves a device by its ID and brings its signature state up to date with the database,
+// which other instances may have advanced in the meantime.
+func (p *PostgresRepository) GetDeviceById(id string) (*domain.SignatureDevice, bool) {
+	device, err := p.loadDevice(id)
+	if err != nil {
+		return nil, false
+	}
+	return device, true
+}
+
+// UpdateDevice persists the mutable state of an existing device, provided that
+// the device is based on the stored version, and advances the version.
+// The signature counter never moves backwards, as it is already advanced by SaveTransaction.
+func (p *PostgresRepository) UpdateDevice(device *domain.SignatureDevice) error {
+	id := device.ID.String()
+	counter, lastSignature := device.SignatureState()
+	status, decommissionedAt := device.LifecycleState()
+	label, metadata := device.Details()
+	version := device.CurrentVersion()
+
+	encodedMetadata, err := encodeMetadata(metadata)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.db.Exec(
+		`UPDATE devices
+		SET label = $1,
+			last_signature = CASE WHEN signature_counter <= $2 THEN $3 ELSE last_signature END,
+			signature_counter = GREATEST(signature_counter, $2),
+			status = $4,
+			decommissioned_at = $5,
+			metadata = $6,
+			version = version + 1
+		WHERE id = $7 AND version = $8`,
+		label, counter, lastSignature, string(status), postgresTime(decommissionedAt), string(encodedMetadata),
+		id, version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update device %s: %w", device.ID, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		var stored int
+		if err := p.db.QueryRow(`SELECT version FROM devices WHERE id = $1`, id).Scan(&stored); err != nil {
+			return fmt.Errorf("device with id %s not found", device.ID)
+		}
+		return &VersionConflictError{DeviceID: id, Stored: stored, Given: version}
+	}
+
+	device.SyncVersion(version + 1)
+	return nil
+}
+
+// UpdatePrivateKey re-encodes and stores the private key of an existing device.
+func (p *PostgresRepository) UpdatePrivateKey(device *domain.SignatureDevice) error {
+	privateKey, err := encodePrivateKey(p.algorithms, device)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.db.Exec(`UPDATE devices SET private_key = $1 WHERE id = $2`, privateKey, device.ID.String())
+	if err != nil {
+		return fmt.Errorf("failed to update private key of device %s: %w", device.ID, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("device with id %s not found", device.ID)
+	}
+	return nil
+}
+
+// GetAllDevices returns all devices stored in the database.
+func (p *PostgresRepository) GetAllDevices() ([]*domain.SignatureDevice, error) {
+	rows, err := p.db.Query(`SELECT id FROM devices ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	devices := make([]*domain.SignatureDevice, 0, len(ids))
+	for _, id := range ids {
+		device, err := p.loadDevice(id)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// SaveTransaction stores a transaction and advances the device's signature counter in one database transaction.
+// The device row is locked for the duration, and the transaction is rejected unless it continues the stored chain
+// and the device is still active, which another instance may have changed in the meantime.
+func (p *PostgresRepository) SaveTransaction(transaction *domain.Transaction) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := savePostgresTransaction(tx, transaction); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveTransactions stores consecutive transactions of a device in one database transaction,
+// under the same checks as SaveTransaction, so that either the whole batch is stored or none of it.
+func (p *PostgresRepository) SaveTransactions(transactions []*domain.Transaction) error {
+	if err := checkBatch(transactions); err != nil {
+		return err
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, transaction := range transactions {
+		if err := savePostgresTransaction(tx, transaction); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SaveKeyRotation stores the rotation record, the retired key and the device's new key pair in one
+// database transaction, under the same checks as SaveTransaction.
+func (p *PostgresRepository) SaveKeyRotation(rotation *domain.KeyRotation) error {
+	publicKey, privateKey, retiredKey, err := encodeKeyRotation(p.algorithms, rotation)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := savePostgresTransaction(tx, rotation.Transaction); err != nil {
+		return err
+	}
+
+	deviceId := rotation.Transaction.DeviceID.String()
+	retired := rotation.Retired
+	_, err = tx.Exec(
+		`INSERT INTO device_keys (device_id, generation, public_key, valid_from, valid_until, retired_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		deviceId, retired.Generation, retiredKey, retired.ValidFrom, retired.ValidUntil, retired.RetiredAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store retired key of device %s: %w", deviceId, err)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE devices SET public_key = $1, private_key = $2, key_generation = $3, key_valid_from = $4 WHERE id = $5`,
+		publicKey, privateKey, rotation.Current.Generation, rotation.Current.ValidFrom, deviceId,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store new key of device %s: %w", deviceId, err)
+	}
+
+	return tx.Commit()
+}
+
+// savePostgresTransaction locks the device row, checks that the transaction continues the stored chain
+// of an active device, advances the signature counter and stores the transaction.
+func savePostgresTransaction(tx *sql.Tx, transaction *domain.Transaction) error {
+	deviceId := transaction.DeviceID.String()
+
+	var (
+		counter int
+		status  domain.DeviceStatus
+	)
+	err := tx.QueryRow(
+		`SELECT signature_counter, status FROM devices WHERE id = $1 FOR UPDATE`,
+		deviceId,
+	).Scan(&counter, &status)
+	if err != nil {
+		return fmt.Errorf("failed to lock device %s: %w", deviceId, err)
+	}
+
+	if status != domain.DeviceStatusActive {
+		return &domain.InactiveDeviceError{DeviceID: transaction.DeviceID, Status: status}
+	}
+
+	if counter != transaction.Counter {
+		return &VersionConflictError{DeviceID: deviceId, Stored: counter, Given: transaction.Counter}
+	}
+
+	_, err = tx.Exec(
+		`UPDATE devices SET signature_counter = $1, last_signature = $2 WHERE id = $3`,
+		transaction.Counter+1, transaction.Signature, deviceId,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to advance signature counter of device %s: %w", deviceId, err)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO transactions (device_id, counter, data, secured_data, signature, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		deviceId, transaction.Counter, transaction.Data, transaction.SecuredData,
+		transaction.Signature, transaction.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert transaction %d of device %s: %w", transaction.Counter, deviceId, err)
+	}
+	return nil
+}
+
+// GetTransaction retrieves the transaction of a device with the given counter.
+func (p *PostgresRepository) GetTransaction(deviceId string, counter int) (*domain.Transaction, bool) {
+	row := p.db.QueryRow(
+		`SELECT device_id, counter, data, secured_data, signature, created_at
+		FROM transactions WHERE device_id = $1 AND counter = $2`,
+		deviceId, counter,
+	)
+
+	transaction, err := scanPostgresTransaction(row)
+	if err != nil {
+		return nil, false
+	}
+	return transaction, true
+}
+
+// GetTransactionsByDevice returns all transactions of a device ordered by counter.
+func (p *PostgresRepository) GetTransactionsByDevice(deviceId string) ([]*domain.Transaction, error) {
+	return p.queryTransactions(
+		`SELECT device_id, counter, data, secured_data, signature, created_at
+		FROM transactions WHERE device_id = $1 ORDER BY counter`,
+		deviceId,
+	)
+}
+
+// GetTransactionsPage returns a page of the transactions of a device ordered by counter and their total number.
+func (p *PostgresRepository) GetTransactionsPage(deviceId string, offset, limit int) ([]*domain.Transaction, int, error) {
+	var total int
+	err := p.db.QueryRow(`SELECT COUNT(*) FROM transactions WHERE device_id = $1`, deviceId).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	transactions, err := p.queryTransactions(
+		`SELECT device_id, counter, data, secured_data, signature, created_at
+		FROM transactions WHERE device_id = $1 ORDER BY counter LIMIT $2 OFFSET $3`,
+		deviceId, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	return transactions, total, nil
+}
+
+// queryTransactions runs a query selecting transactions and scans all rows.
+func (p *PostgresRepository) queryTransactions(query string, args ...interface{}) ([]*domain.Transaction, error) {
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []*domain.Transaction{}
+	for rows.Next() {
+		transaction, err := scanPostgresTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+// loadDevice returns the cached device or restores it from the database,
+// and syncs its signature counter, label and lifecycle state with the stored ones.
+func (p *PostgresRepository) loadDevice(id string) (*domain.SignatureDevice, error) {
+	var (
+		rawID            string
+		parameters       crypto.Parameters
+		record           = &domain.SignatureDevice{}
+		lastSignature    []byte
+		privateKey       []byte
+		decommissionedAt sql.NullTime
+		metadata         []byte
+	)
+	err := p.db.QueryRow(
+		`SELECT id, label, algorithm, rsa_bits, curve, hash, salt_length, signature_encoding,
+			signature_counter, last_signature, private_key, version, status, decommissioned_at, metadata,
+			key_generation, key_valid_from, secured_data_format
+		FROM devices WHERE id = $1`,
+		id,
+	).Scan(
+		&rawID, &record.Label, &record.Algorithm,
+		&parameters.RSABits, &parameters.Curve, &parameters.Hash, &parameters.SaltLength, &parameters.SignatureEncoding,
+		&record.SignatureCounter, &lastSignature, &privateKey, &record.Version, &record.Status, &decommissionedAt, &metadata,
+		&record.KeyGeneration, &record.KeyValidFrom, &record.SecuredDataFormat,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if record.Metadata, err = decodeMetadata(metadata); err != nil {
+		return nil, err
+	}
+	if decommissionedAt.Valid {
+		record.DecommissionedAt = decommissionedAt.Time.UTC()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// A device whose key was rotated by another instance is rebuilt from the stored keys.
+	if device, exists := p.devices[id]; exists && device.CurrentKeyGeneration() >= record.KeyGeneration {
+		device.SyncSignatureState(record.SignatureCounter, lastSignature)
+		device.SyncStoredState(record.Version, record.Label, record.Metadata, record.Status, record.DecommissionedAt)
+		return device, nil
+	}
+
+	record.ID, err = uuid.Parse(rawID)
+	if err != nil {
+		return nil, err
+	}
+	record.Parameters = parameters
+	record.LastSignature = lastSignature
+
+	if err := restoreKeys(p.algorithms, record, privateKey); err != nil {
+		return nil, err
+	}
+	if err := p.loadRetiredKeys(record); err != nil {
+		return nil, err
+	}
+
+	p.devices[id] = record
+	return record, nil
+}
+
+// loadRetiredKeys restores the keys a device signed with before its key was rotated.
+func (p *PostgresRepository) loadRetiredKeys(device *domain.SignatureDevice) error {
+	rows, err := p.db.Query(
+		`SELECT generation, public_key, valid_from, valid_until, retired_at
+		FROM device_keys WHERE device_id = $1 ORDER BY generation`,
+		device.ID.String(),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			key       domain.KeyGeneration
+			publicKey []byte
+		)
+		if err := rows.Scan(&key.Generation, &publicKey, &key.ValidFrom, &key.ValidUntil, &key.RetiredAt); err != nil {
+			return err
+		}
+		if err := restoreRetiredKey(p.algorithms, device, key, publicKey); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// postgresTime encodes an optional timestamp; the zero time is stored as NULL.
+func postgresTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func scanPostgresTransaction(row rowScanner) (*domain.Transaction, error) {
+	var (
+		transaction domain.Transaction
+		deviceID    string
+	)
+	err := row.Scan(
+		&deviceID, &transaction.Counter, &transaction.Data,
+		&transaction.SecuredData, &transaction.Signature, &transaction.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if transaction.DeviceID, err = uuid.Parse(deviceID); err != nil {
+		return nil, err
+	}
+	transaction.CreatedAt = transaction.CreatedAt.UTC()
+	return &transaction, nil
+}
@@ -0,0 +1,190 @@
+package infrastructure_test
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/infrastructure"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+)
+
+// embeddedPostgres is started by the first PostgreSQL test and stopped by TestMain.
+var (
+	startEmbeddedPostgres sync.Once
+	embeddedPostgres      *embeddedpostgres.EmbeddedPostgres
+	embeddedPostgresDir   string
+	embeddedPostgresDSN   string
+	embeddedPostgresErr   error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if embeddedPostgres != nil {
+		embeddedPostgres.Stop()
+		os.RemoveAll(embeddedPostgresDir)
+	}
+	os.Exit(code)
+}
+
+// postgresTestDSN returns the database the PostgreSQL tests run against: the one given in
+// POSTGRES_TEST_DSN, or else a throwaway instance started in a temporary directory. The
+// PostgreSQL binaries of the embedded instance are downloaded and cached on first use, so
+// without a DSN the tests are skipped if that is not possible, e.g. offline, and in -short mode.
+func postgresTestDSN(t *testing.T) string {
+	if dsn := os.Getenv("POSTGRES_TEST_DSN"); dsn != "" {
+		return dsn
+	}
+	if testing.Short() {
+		t.Skip("POSTGRES_TEST_DSN is not set and embedded postgres is not started in short mode")
+	}
+
+	startEmbeddedPostgres.Do(func() {
+		embeddedPostgresErr = func() error {
+			port, err := freePort()
+			if err != nil {
+				return err
+			}
+			runtimePath, err := os.MkdirTemp("", "embedded-postgres")
+			if err != nil {
+				return err
+			}
+
+			config := embeddedpostgres.DefaultConfig().
+				Version(embeddedpostgres.V16).
+				Port(port).
+				RuntimePath(runtimePath).
+				Logger(io.Discard)
+			database := embeddedpostgres.NewDatabase(config)
+			if err := database.Start(); err != nil {
+				os.RemoveAll(runtimePath)
+				return err
+			}
+			embeddedPostgres, embeddedPostgresDir = database, runtimePath
+			embeddedPostgresDSN = config.GetConnectionURL() + "?sslmode=disable"
+			return nil
+		}()
+	})
+	if embeddedPostgresErr != nil {
+		t.Skipf("POSTGRES_TEST_DSN is not set and embedded postgres could not be started: %v", embeddedPostgresErr)
+	}
+	return embeddedPostgresDSN
+}
+
+// freePort returns a TCP port that is currently not in use.
+func freePort() (uint32, error) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return uint32(listener.Addr().(*net.TCPAddr).Port), nil
+}
+
+func openPostgresServices(t *testing.T) (*infrastructure.PostgresRepository, *service.DeviceService, *service.TransactionService) {
+	dsn := postgresTestDSN(t)
+
+	repository, err := infrastructure.NewPostgresRepository(dsn, crypto.DefaultRegistry)
+	if err != nil {
+		t.Fatalf("Failed to connect to postgres: %v", err)
+	}
+	t.Cleanup(func() { repository.Close() })
+
+	return repository,
+		service.NewDeviceService(repository, crypto.DefaultRegistry),
+		service.NewTransactionService(repository, repository)
+}
+
+func TestPostgresRepositoryInstancesNeverShareCounters(t *testing.T) {
+	_, deviceService, firstInstance := openPostgresServices(t)
+	_, _, secondInstance := openPostgresServices(t)
+
+	device, err := deviceService.CreateSignatureDevice("ED25519", "clustered", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	deviceId := device.ID.String()
+
+	const signsPerInstance = 50
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		counters  = make(map[int]bool)
+		succeeded int
+	)
+	for _, instance := range []*service.TransactionService{firstInstance, secondInstance} {
+		for i := 0; i < signsPerInstance; i++ {
+			wg.Add(1)
+			go func(instance *service.TransactionService) {
+				defer wg.Done()
+
+				// A stale instance is rejected rather than issuing a counter twice.
+				transaction, err := instance.SignTransaction(deviceId, "data")
+				if err != nil {
+					return
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				if counters[transaction.Counter] {
+					t.Errorf("Counter %d was issued twice", transaction.Counter)
+				}
+				counters[transaction.Counter] = true
+				succeeded++
+			}(instance)
+		}
+	}
+	wg.Wait()
+
+	if succeeded == 0 {
+		t.Fatal("Expected at least one signature to succeed")
+	}
+
+	report, err := secondInstance.AuditDevice(deviceId)
+	if err != nil {
+		t.Fatalf("Failed to audit device: %v", err)
+	}
+	if !report.Valid || report.TransactionsChecked != succeeded {
+		t.Errorf("Expected an intact chain of %d transactions, got %+v", succeeded, report)
+	}
+}
+
+func TestPostgresRepositoryPagesTransactions(t *testing.T) {
+	repository, deviceService, transactionService := openPostgresServices(t)
+
+	device, err := deviceService.CreateSignatureDevice("ED25519", "", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	deviceId := device.ID.String()
+	if _, err := transactionService.SignTransactions(deviceId, []string{"a", "b", "c", "d", "e"}); err != nil {
+		t.Fatalf("Failed to sign batch: %v", err)
+	}
+
+	assertTransactionPages(t, repository, deviceId)
+}
+
+func TestPostgresRepositoryPicksUpForeignSignatures(t *testing.T) {
+	_, deviceService, firstInstance := openPostgresServices(t)
+	_, _, secondInstance := openPostgresServices(t)
+
+	device, err := deviceService.CreateSignatureDevice("ECC", "", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	deviceId := device.ID.String()
+
+	for i, instance := range []*service.TransactionService{firstInstance, secondInstance, firstInstance} {
+		transaction, err := instance.SignTransaction(deviceId, "data")
+		if err != nil {
+			t.Fatalf("Failed to sign transaction %d: %v", i, err)
+		}
+		if transaction.Counter != i {
+			t.Errorf("Expected counter %d, got %d", i, transaction.Counter)
+		}
+	}
+}
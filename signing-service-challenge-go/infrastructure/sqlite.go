@@ -0,0 +1,706 @@
+package infrastructure
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/google/uuid"
+
+	// Pure-Go SQLite driver, registered as "sqlite".
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order; the index + 1 is the schema version.
+// Never edit an existing migration, append a new one instead.
+var sqliteMigrations = []string{
+	`CREATE TABLE devices (
+		id                 TEXT PRIMARY KEY,
+		label              TEXT NOT NULL,
+		algorithm          TEXT NOT NULL,
+		rsa_bits           INTEGER NOT NULL DEFAULT 0,
+		curve              TEXT NOT NULL DEFAULT '',
+		hash               TEXT NOT NULL DEFAULT '',
+		salt_length        INTEGER NOT NULL DEFAULT 0,
+		signature_encoding TEXT NOT NULL DEFAULT '',
+		signature_counter  INTEGER NOT NULL DEFAULT 0,
+		last_signature     BLOB,
+		public_key         BLOB NOT NULL,
+		private_key        BLOB NOT NULL
+	);
+	CREATE TABLE transactions (
+		device_id    TEXT NOT NULL REFERENCES devices (id),
+		counter      INTEGER NOT NULL,
+		data         TEXT NOT NULL,
+		secured_data TEXT NOT NULL,
+		signature    BLOB NOT NULL,
+		created_at   TEXT NOT NULL,
+		PRIMARY KEY (device_id, counter)
+	);`,
+	`ALTER TABLE devices ADD COLUMN version INTEGER NOT NULL DEFAULT 0;`,
+	`ALTER TABLE devices ADD COLUMN status TEXT NOT NULL DEFAULT 'ACTIVE';
+	ALTER TABLE devices ADD COLUMN decommissioned_at TEXT;`,
+	`ALTER TABLE devices ADD COLUMN metadata TEXT NOT NULL DEFAULT '{}';`,
+	`ALTER TABLE devices ADD COLUMN key_generation INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE devices ADD COLUMN key_valid_from INTEGER NOT NULL DEFAULT 0;
+	CREATE TABLE device_keys (
+		device_id   TEXT NOT NULL REFERENCES devices (id),
+		generation  INTEGER NOT NULL,
+		public_key  BLOB NOT NULL,
+		valid_from  INTEGER NOT NULL,
+		valid_until INTEGER NOT NULL,
+		retired_at  TEXT NOT NULL,
+		PRIMARY KEY (device_id, generation)
+	);`,
+	`ALTER TABLE devices ADD COLUMN secured_data_format TEXT NOT NULL DEFAULT 'LEGACY';`,
+}
+
+// SQLiteRepository provides durable storage for signature devices and their transactions in SQLite.
+// Keys are stored PEM encoded with the marshaler of the device's algorithm.
+//
+// Loaded devices are kept in an identity map, so that all callers share one
+// *domain.SignatureDevice per ID and its lock keeps guarding the signature counter.
+// This assumes a single process owns the database file.
+type SQLiteRepository struct {
+	db         *sql.DB
+	algorithms *crypto.Registry
+
+	mu      sync.Mutex
+	devices map[string]*domain.SignatureDevice
+}
+
+// NewSQLiteRepository opens (or creates) the SQLite database at path and migrates it to the latest schema.
+// The registry is used to encode and decode the keys of stored devices.
+func NewSQLiteRepository(path string, algorithms *crypto.Registry) (*SQLiteRepository, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serializing access avoids "database is locked" errors.
+	db.SetMaxOpenConns(1)
+
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteRepository{
+		db:         db,
+		algorithms: algorithms,
+		devices:    make(map[string]*domain.SignatureDevice),
+	}, nil
+}
+
+// Close closes the underlying database.
+func (s *SQLiteRepository) Close() error {
+	return s.db.Close()
+}
+
+// migrateSQLite applies all migrations newer than the database's schema version.
+func migrateSQLite(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Save stores a new device together with its encoded key pair.
+func (s *SQLiteRepository) Save(id string, device *domain.SignatureDevice) error {
+	algorithm, exists := s.algorithms.Get(device.Algorithm)
+	if !exists {
+		return fmt.Errorf("algorithm %s is not registered", device.Algorithm)
+	}
+
+	publicKey, privateKey, err := algorithm.Marshaler.Marshal(crypto.KeyPair{
+		Public:  device.PublicKey,
+		Private: device.PrivateKey,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode keys of device %s: %w", id, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.devices[id]; exists {
+		return fmt.Errorf("device with id %s already exists", id)
+	}
+
+	counter, lastSignature := device.SignatureState()
+	status, decommissionedAt := device.LifecycleState()
+	label, metadata := device.Details()
+	encodedMetadata, err := encodeMetadata(metadata)
+	if err != nil {
+		return err
+	}
+
+	parameters := device.Parameters
+	_, err = s.db.Exec(
+		`INSERT INTO devices (
+			id, label, algorithm, rsa_bits, curve, hash, salt_length, signature_encoding,
+			signature_counter, last_signature, public_key, private_key, version, status, decommissioned_at, metadata,
+			secured_data_format
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, label, device.Algorithm,
+		parameters.RSABits, parameters.Curve, parameters.Hash, parameters.SaltLength, parameters.SignatureEncoding,
+		counter, lastSignature, publicKey, privateKey, device.CurrentVersion(),
+		string(status), sqliteTime(decommissionedAt), string(encodedMetadata), string(device.SecuredDataFormat),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert device %s: %w", id, err)
+	}
+
+	s.devices[id] = device
+	return nil
+}
+
+// GetDeviceById retrieves a device by its ID, loading it from the database on first access.
+func (s *SQLiteRepository) GetDeviceById(id string) (*domain.SignatureDevice, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device, err := s.loadDevice(id)
+	if err != nil {
+		return nil, false
+	}
+	return device, true
+}
+
+// UpdateDevice persists the mutable state of an existing device, provided that
+// the device is based on the stored version, and advances the version.
+// The signature counter never moves backwards, as it is already advanced by SaveTransaction.
+func (s *SQLiteRepository) UpdateDevice(device *domain.SignatureDevice) error {
+	id := device.ID.String()
+	counter, lastSignature := device.SignatureState()
+	status, decommissionedAt := device.LifecycleState()
+	label, metadata := device.Details()
+	version := device.CurrentVersion()
+
+	encodedMetadata, err := encodeMetadata(metadata)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(
+		`UPDATE devices
+		SET label = ?,
+			metadata = ?,
+			last_signature = CASE WHEN signature_counter <= ? THEN ? ELSE last_signature END,
+			signature_counter = MAX(signature_counter, ?),
+			status = ?,
+			decommissioned_at = ?,
+			version = version + 1
+		WHERE id = ? AND version = ?`,
+		label, string(encodedMetadata), counter, lastSignature, counter,
+		string(status), sqliteTime(decommissionedAt), id, version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update device %s: %w", device.ID, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		var stored int
+		if err := s.db.QueryRow(`SELECT version FROM devices WHERE id = ?`, id).Scan(&stored); err != nil {
+			return fmt.Errorf("device with id %s not found", device.ID)
+		}
+		return &VersionConflictError{DeviceID: id, Stored: stored, Given: version}
+	}
+
+	device.SyncVersion(version + 1)
+	return nil
+}
+
+// UpdatePrivateKey re-encodes and stores the private key of an existing device.
+func (s *SQLiteRepository) UpdatePrivateKey(device *domain.SignatureDevice) error {
+	privateKey, err := encodePrivateKey(s.algorithms, device)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`UPDATE devices SET private_key = ? WHERE id = ?`, privateKey, device.ID.String())
+	if err != nil {
+		return fmt.Errorf("failed to update private key of device %s: %w", device.ID, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("device with id %s not found", device.ID)
+	}
+	return nil
+}
+
+// GetAllDevices returns all devices stored in the database.
+func (s *SQLiteRepository) GetAllDevices() ([]*domain.SignatureDevice, error) {
+	rows, err := s.db.Query(`SELECT id FROM devices ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	devices := make([]*domain.SignatureDevice, 0, len(ids))
+	for _, id := range ids {
+		device, err := s.loadDevice(id)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// SaveTransaction stores a transaction and advances the device's stored signature counter
+// in one database transaction. It fails if the stored counter does not match the transaction.
+func (s *SQLiteRepository) SaveTransaction(transaction *domain.Transaction) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveSQLiteTransaction(tx, transaction); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveTransactions stores consecutive transactions of a device and advances its signature counter
+// in one database transaction, so that either the whole batch is stored or none of it.
+func (s *SQLiteRepository) SaveTransactions(transactions []*domain.Transaction) error {
+	if err := checkBatch(transactions); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, transaction := range transactions {
+		if err := saveSQLiteTransaction(tx, transaction); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SaveKeyRotation stores the rotation record, the retired key and the device's new key pair in one
+// database transaction. Like SaveTransaction, it fails if the stored counter does not match the record.
+func (s *SQLiteRepository) SaveKeyRotation(rotation *domain.KeyRotation) error {
+	publicKey, privateKey, retiredKey, err := encodeKeyRotation(s.algorithms, rotation)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveSQLiteTransaction(tx, rotation.Transaction); err != nil {
+		return err
+	}
+
+	deviceId := rotation.Transaction.DeviceID.String()
+	retired := rotation.Retired
+	_, err = tx.Exec(
+		`INSERT INTO device_keys (device_id, generation, public_key, valid_from, valid_until, retired_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		deviceId, retired.Generation, retiredKey, retired.ValidFrom, retired.ValidUntil,
+		retired.RetiredAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store retired key of device %s: %w", deviceId, err)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE devices SET public_key = ?, private_key = ?, key_generation = ?, key_valid_from = ? WHERE id = ?`,
+		publicKey, privateKey, rotation.Current.Generation, rotation.Current.ValidFrom, deviceId,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store new key of device %s: %w", deviceId, err)
+	}
+
+	return tx.Commit()
+}
+
+// saveSQLiteTransaction advances the stored signature counter if it matches the transaction and the
+// stored device is active, and stores the transaction.
+func saveSQLiteTransaction(tx *sql.Tx, transaction *domain.Transaction) error {
+	deviceId := transaction.DeviceID.String()
+	result, err := tx.Exec(
+		`UPDATE devices SET signature_counter = ?, last_signature = ?
+		WHERE id = ? AND signature_counter = ? AND status = ?`,
+		transaction.Counter+1, transaction.Signature, deviceId, transaction.Counter, string(domain.DeviceStatusActive),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to advance signature counter of device %s: %w", deviceId, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected != 1 {
+		var (
+			stored int
+			status domain.DeviceStatus
+		)
+		err := tx.QueryRow(`SELECT signature_counter, status FROM devices WHERE id = ?`, deviceId).Scan(&stored, &status)
+		if err != nil {
+			return fmt.Errorf("device with id %s not found", deviceId)
+		}
+		if status != domain.DeviceStatusActive {
+			return &domain.InactiveDeviceError{DeviceID: transaction.DeviceID, Status: status}
+		}
+		return &VersionConflictError{DeviceID: deviceId, Stored: stored, Given: transaction.Counter}
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO transactions (device_id, counter, data, secured_data, signature, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		deviceId, transaction.Counter, transaction.Data, transaction.SecuredData,
+		transaction.Signature, transaction.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert transaction %d of device %s: %w", transaction.Counter, deviceId, err)
+	}
+	return nil
+}
+
+// GetTransaction retrieves the transaction of a device with the given counter.
+func (s *SQLiteRepository) GetTransaction(deviceId string, counter int) (*domain.Transaction, bool) {
+	row := s.db.QueryRow(
+		`SELECT device_id, counter, data, secured_data, signature, created_at
+		FROM transactions WHERE device_id = ? AND counter = ?`,
+		deviceId, counter,
+	)
+
+	transaction, err := scanTransaction(row)
+	if err != nil {
+		return nil, false
+	}
+	return transaction, true
+}
+
+// GetTransactionsByDevice returns all transactions of a device ordered by counter.
+func (s *SQLiteRepository) GetTransactionsByDevice(deviceId string) ([]*domain.Transaction, error) {
+	return s.queryTransactions(
+		`SELECT device_id, counter, data, secured_data, signature, created_at
+		FROM transactions WHERE device_id = ? ORDER BY counter`,
+		deviceId,
+	)
+}
+
+// GetTransactionsPage returns a page of the transactions of a device ordered by counter and their total number.
+func (s *SQLiteRepository) GetTransactionsPage(deviceId string, offset, limit int) ([]*domain.Transaction, int, error) {
+	var total int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM transactions WHERE device_id = ?`, deviceId).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	transactions, err := s.queryTransactions(
+		`SELECT device_id, counter, data, secured_data, signature, created_at
+		FROM transactions WHERE device_id = ? ORDER BY counter LIMIT ? OFFSET ?`,
+		deviceId, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	return transactions, total, nil
+}
+
+// queryTransactions runs a query selecting transactions and scans all rows.
+func (s *SQLiteRepository) queryTransactions(query string, args ...interface{}) ([]*domain.Transaction, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []*domain.Transaction{}
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+// loadDevice returns the cached device or restores it from the database. The caller must hold s.mu.
+func (s *SQLiteRepository) loadDevice(id string) (*domain.SignatureDevice, error) {
+	if device, exists := s.devices[id]; exists {
+		return device, nil
+	}
+
+	var (
+		rawID            string
+		parameters       crypto.Parameters
+		device           = &domain.SignatureDevice{}
+		lastSignature    []byte
+		privateKey       []byte
+		decommissionedAt sql.NullString
+		metadata         string
+	)
+	err := s.db.QueryRow(
+		`SELECT id, label, algorithm, rsa_bits, curve, hash, salt_length, signature_encoding,
+			signature_counter, last_signature, private_key, version, status, decommissioned_at, metadata,
+			key_generation, key_valid_from, secured_data_format
+		FROM devices WHERE id = ?`,
+		id,
+	).Scan(
+		&rawID, &device.Label, &device.Algorithm,
+		&parameters.RSABits, &parameters.Curve, &parameters.Hash, &parameters.SaltLength, &parameters.SignatureEncoding,
+		&device.SignatureCounter, &lastSignature, &privateKey, &device.Version, &device.Status, &decommissionedAt, &metadata,
+		&device.KeyGeneration, &device.KeyValidFrom, &device.SecuredDataFormat,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if device.Metadata, err = decodeMetadata([]byte(metadata)); err != nil {
+		return nil, err
+	}
+	if decommissionedAt.Valid {
+		if device.DecommissionedAt, err = time.Parse(time.RFC3339Nano, decommissionedAt.String); err != nil {
+			return nil, err
+		}
+	}
+
+	device.ID, err = uuid.Parse(rawID)
+	if err != nil {
+		return nil, err
+	}
+	device.Parameters = parameters
+	device.LastSignature = lastSignature
+
+	if err := restoreKeys(s.algorithms, device, privateKey); err != nil {
+		return nil, err
+	}
+	if err := s.loadRetiredKeys(device); err != nil {
+		return nil, err
+	}
+
+	s.devices[id] = device
+	return device, nil
+}
+
+// loadRetiredKeys restores the keys a device signed with before its key was rotated.
+func (s *SQLiteRepository) loadRetiredKeys(device *domain.SignatureDevice) error {
+	rows, err := s.db.Query(
+		`SELECT generation, public_key, valid_from, valid_until, retired_at
+		FROM device_keys WHERE device_id = ? ORDER BY generation`,
+		device.ID.String(),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			key       domain.KeyGeneration
+			publicKey []byte
+			retiredAt string
+		)
+		if err := rows.Scan(&key.Generation, &publicKey, &key.ValidFrom, &key.ValidUntil, &retiredAt); err != nil {
+			return err
+		}
+		if key.RetiredAt, err = time.Parse(time.RFC3339Nano, retiredAt); err != nil {
+			return err
+		}
+		if err := restoreRetiredKey(s.algorithms, device, key, publicKey); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// sqliteTime encodes an optional timestamp; the zero time is stored as NULL.
+func sqliteTime(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(time.RFC3339Nano), Valid: true}
+}
+
+// encodeMetadata encodes the metadata of a device as a JSON object.
+func encodeMetadata(metadata map[string]string) ([]byte, error) {
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode device metadata: %w", err)
+	}
+	return encoded, nil
+}
+
+// decodeMetadata decodes metadata stored by encodeMetadata; an empty object yields nil.
+func decodeMetadata(encoded []byte) (map[string]string, error) {
+	var metadata map[string]string
+	if err := json.Unmarshal(encoded, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode device metadata: %w", err)
+	}
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	return metadata, nil
+}
+
+// encodePrivateKey encodes the private key of a device with the marshaler of its algorithm.
+func encodePrivateKey(algorithms *crypto.Registry, device *domain.SignatureDevice) ([]byte, error) {
+	algorithm, exists := algorithms.Get(device.Algorithm)
+	if !exists {
+		return nil, fmt.Errorf("algorithm %s of device %s is not registered", device.Algorithm, device.ID)
+	}
+
+	_, privateKey, err := algorithm.Marshaler.Marshal(crypto.KeyPair{
+		Public:  device.PublicKey,
+		Private: device.PrivateKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key of device %s: %w", device.ID, err)
+	}
+	return privateKey, nil
+}
+
+// encodeKeyRotation encodes the new key pair of a rotation with the marshaler of the device's algorithm,
+// and the public key of the retired key as PEM.
+func encodeKeyRotation(algorithms *crypto.Registry, rotation *domain.KeyRotation) (publicKey, privateKey, retiredKey []byte, err error) {
+	deviceId := rotation.Transaction.DeviceID
+	algorithm, exists := algorithms.Get(rotation.Algorithm)
+	if !exists {
+		return nil, nil, nil, fmt.Errorf("algorithm %s of device %s is not registered", rotation.Algorithm, deviceId)
+	}
+
+	publicKey, privateKey, err = algorithm.Marshaler.Marshal(crypto.KeyPair{
+		Public:  rotation.Current.PublicKey,
+		Private: rotation.PrivateKey,
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode new key of device %s: %w", deviceId, err)
+	}
+
+	retiredKey, err = crypto.EncodePublicKeyPEM(rotation.Retired.PublicKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to encode retired key of device %s: %w", deviceId, err)
+	}
+	return publicKey, privateKey, retiredKey, nil
+}
+
+// restoreRetiredKey decodes the stored public key of a retired key, rebuilds its verifier and
+// appends it to the device's retired keys.
+func restoreRetiredKey(
+	algorithms *crypto.Registry,
+	device *domain.SignatureDevice,
+	key domain.KeyGeneration,
+	publicKey []byte,
+) error {
+	algorithm, exists := algorithms.Get(device.Algorithm)
+	if !exists {
+		return fmt.Errorf("algorithm %s of device %s is not registered", device.Algorithm, device.ID)
+	}
+
+	var err error
+	if key.PublicKey, err = crypto.ParsePublicKeyPEM(publicKey); err != nil {
+		return fmt.Errorf("failed to decode key generation %d of device %s: %w", key.Generation, device.ID, err)
+	}
+	if key.Verifier, err = algorithm.NewVerifier(key.PublicKey, device.Parameters); err != nil {
+		return err
+	}
+	key.RetiredAt = key.RetiredAt.UTC()
+
+	device.RetiredKeys = append(device.RetiredKeys, key)
+	return nil
+}
+
+// restoreKeys decodes a stored private key and rebuilds the device's signer and verifier.
+func restoreKeys(algorithms *crypto.Registry, device *domain.SignatureDevice, privateKey []byte) error {
+	algorithm, exists := algorithms.Get(device.Algorithm)
+	if !exists {
+		return fmt.Errorf("algorithm %s of device %s is not registered", device.Algorithm, device.ID)
+	}
+
+	keyPair, err := algorithm.Marshaler.Unmarshal(privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to decode private key of device %s: %w", device.ID, err)
+	}
+	device.PrivateKey = keyPair.Private
+	device.PublicKey = keyPair.Public
+
+	device.Signer, err = algorithm.NewSigner(keyPair.Private, device.Parameters)
+	if err != nil {
+		return err
+	}
+	device.Verifier, err = algorithm.NewVerifier(keyPair.Public, device.Parameters)
+	return err
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTransaction(row rowScanner) (*domain.Transaction, error) {
+	var (
+		transaction domain.Transaction
+		deviceID    string
+		createdAt   string
+	)
+	err := row.Scan(
+		&deviceID, &transaction.Counter, &transaction.Data,
+		&transaction.SecuredData, &transaction.Signature, &createdAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if transaction.DeviceID, err = uuid.Parse(deviceID); err != nil {
+		return nil, err
+	}
+	if transaction.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
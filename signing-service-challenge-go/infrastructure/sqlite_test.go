@@ -0,0 +1,381 @@
+package infrastructure_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/infrastructure"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+)
+
+func openSQLiteServices(t *testing.T, path string) (*infrastructure.SQLiteRepository, *service.DeviceService, *service.TransactionService) {
+	repository, err := infrastructure.NewSQLiteRepository(path, crypto.DefaultRegistry)
+	if err != nil {
+		t.Fatalf("Failed to open sqlite repository: %v", err)
+	}
+	return repository,
+		service.NewDeviceService(repository, crypto.DefaultRegistry),
+		service.NewTransactionService(repository, repository)
+}
+
+func TestSQLiteRepositorySurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing.db")
+
+	for _, algorithm := range []string{"RSA", "RSA-PSS", "ECC", "ED25519"} {
+		t.Run(algorithm, func(t *testing.T) {
+			repository, deviceService, transactionService := openSQLiteServices(t, path)
+
+			device, err := deviceService.CreateSignatureDevice(algorithm, "durable", crypto.Parameters{})
+			if err != nil {
+				t.Fatalf("Failed to create device: %v", err)
+			}
+			deviceId := device.ID.String()
+
+			for _, data := range []string{"first", "second"} {
+				if _, err := transactionService.SignTransaction(deviceId, data); err != nil {
+					t.Fatalf("Failed to sign transaction: %v", err)
+				}
+			}
+			if err := repository.Close(); err != nil {
+				t.Fatalf("Failed to close repository: %v", err)
+			}
+
+			repository, deviceService, transactionService = openSQLiteServices(t, path)
+			defer repository.Close()
+
+			restored, exists := deviceService.GetDevice(deviceId)
+			if !exists {
+				t.Fatalf("Device %s not found after reopening", deviceId)
+			}
+			if restored.Label != "durable" || restored.Parameters != device.Parameters {
+				t.Errorf("Restored device does not match: got %q %+v, want %q %+v",
+					restored.Label, restored.Parameters, "durable", device.Parameters)
+			}
+
+			transaction, err := transactionService.SignTransaction(deviceId, "third")
+			if err != nil {
+				t.Fatalf("Failed to sign after reopening: %v", err)
+			}
+			if transaction.Counter != 2 {
+				t.Errorf("Expected counter 2 after reopening, got %d", transaction.Counter)
+			}
+
+			report, err := transactionService.AuditDevice(deviceId)
+			if err != nil {
+				t.Fatalf("Failed to audit device: %v", err)
+			}
+			if !report.Valid || report.TransactionsChecked != 3 {
+				t.Errorf("Expected an intact chain of 3 transactions, got %+v", report)
+			}
+		})
+	}
+}
+
+func TestSQLiteRepositoryRejectsStaleCounter(t *testing.T) {
+	repository, deviceService, transactionService := openSQLiteServices(t, filepath.Join(t.TempDir(), "signing.db"))
+	defer repository.Close()
+
+	device, err := deviceService.CreateSignatureDevice("ED25519", "", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	transaction, err := transactionService.SignTransaction(device.ID.String(), "data")
+	if err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+
+	if err := repository.SaveTransaction(transaction); err == nil {
+		t.Error("Expected saving a transaction with a stale counter to fail")
+	}
+}
+
+func TestSQLiteRepositoryRejectsTransactionsOfInactiveDevices(t *testing.T) {
+	repository, deviceService, transactionService := openSQLiteServices(t, filepath.Join(t.TempDir(), "signing.db"))
+	defer repository.Close()
+
+	device, err := deviceService.CreateSignatureDevice("ED25519", "", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	transaction, err := transactionService.SignTransaction(device.ID.String(), "data")
+	if err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	if _, err := deviceService.ChangeDeviceStatus(device.ID.String(), domain.DeviceStatusSuspended); err != nil {
+		t.Fatalf("Failed to suspend device: %v", err)
+	}
+
+	// A copy of the device that missed the suspension, e.g. of another instance, still signs.
+	next := *transaction
+	next.Counter = 1
+	if err := repository.SaveTransaction(&next); !domain.IsInactiveDevice(err) {
+		t.Errorf("Expected saving a transaction of a suspended device to be refused, got %v", err)
+	}
+}
+
+func TestSQLiteRepositoryRejectsStaleUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing.db")
+	first, deviceService, _ := openSQLiteServices(t, path)
+	defer first.Close()
+	second, _, _ := openSQLiteServices(t, path)
+	defer second.Close()
+
+	created, err := deviceService.CreateSignatureDevice("ED25519", "original", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	stale, exists := second.GetDeviceById(created.ID.String())
+	if !exists {
+		t.Fatalf("Device %s not found in second repository", created.ID)
+	}
+
+	created.Label = "updated"
+	if err := first.UpdateDevice(created); err != nil {
+		t.Fatalf("Failed to update device: %v", err)
+	}
+
+	stale.Label = "stale"
+	if err := second.UpdateDevice(stale); !infrastructure.IsVersionConflict(err) {
+		t.Fatalf("Expected a version conflict, got %v", err)
+	}
+}
+
+func TestSQLiteRepositoryPersistsLifecycleState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing.db")
+	repository, deviceService, _ := openSQLiteServices(t, path)
+
+	device, err := deviceService.CreateSignatureDevice("ED25519", "till", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	deviceId := device.ID.String()
+	if _, err := deviceService.ChangeDeviceStatus(deviceId, domain.DeviceStatusDecommissioned); err != nil {
+		t.Fatalf("Failed to decommission device: %v", err)
+	}
+	repository.Close()
+
+	repository, deviceService, transactionService := openSQLiteServices(t, path)
+	defer repository.Close()
+
+	restored, _ := deviceService.GetDevice(deviceId)
+	status, decommissionedAt := restored.LifecycleState()
+	if status != domain.DeviceStatusDecommissioned || decommissionedAt.IsZero() {
+		t.Fatalf("Expected a decommissioned device, got %s at %v", status, decommissionedAt)
+	}
+	if _, err := transactionService.SignTransaction(deviceId, "data"); err == nil {
+		t.Error("Expected a decommissioned device to refuse signing")
+	}
+	if _, err := deviceService.ChangeDeviceStatus(deviceId, domain.DeviceStatusActive); err == nil {
+		t.Error("Expected reactivating a decommissioned device to fail")
+	}
+}
+
+func TestSQLiteRepositoryPersistsDeviceDetails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing.db")
+	repository, deviceService, _ := openSQLiteServices(t, path)
+
+	device, err := deviceService.CreateSignatureDevice("ED25519", "till", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	deviceId := device.ID.String()
+
+	label, storeId := "till 2", "S-17"
+	_, err = deviceService.UpdateDevice(deviceId, service.DeviceUpdate{
+		Label:    &label,
+		Metadata: map[string]*string{"store_id": &storeId},
+	})
+	if err != nil {
+		t.Fatalf("Failed to update device: %v", err)
+	}
+	repository.Close()
+
+	repository, deviceService, _ = openSQLiteServices(t, path)
+	defer repository.Close()
+
+	restored, _ := deviceService.GetDevice(deviceId)
+	restoredLabel, metadata := restored.Details()
+	if restoredLabel != label || metadata["store_id"] != storeId || len(metadata) != 1 {
+		t.Fatalf("Expected label %q and store ID %q, got %q and %v", label, storeId, restoredLabel, metadata)
+	}
+}
+
+// rotateKey rotates the key of a device between two signed transactions.
+func rotateKey(t *testing.T, deviceService *service.DeviceService, transactionService *service.TransactionService, deviceId string) {
+	if _, err := transactionService.SignTransaction(deviceId, "before"); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	key, err := deviceService.GenerateKey(deviceId)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err := transactionService.RotateKey(deviceId, key); err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+	if _, err := transactionService.SignTransaction(deviceId, "after"); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+}
+
+// assertRotatedChain checks that a device restored after rotateKey verifies its whole chain.
+func assertRotatedChain(t *testing.T, transactionService *service.TransactionService, deviceId string) {
+	transaction, err := transactionService.SignTransaction(deviceId, "restored")
+	if err != nil {
+		t.Fatalf("Failed to sign after reopening: %v", err)
+	}
+	if transaction.Counter != 3 {
+		t.Errorf("Expected counter 3 after reopening, got %d", transaction.Counter)
+	}
+
+	report, err := transactionService.AuditDevice(deviceId)
+	if err != nil {
+		t.Fatalf("Failed to audit device: %v", err)
+	}
+	if !report.Valid || report.TransactionsChecked != 4 {
+		t.Errorf("Expected an intact chain of 4 transactions, got %+v", report)
+	}
+}
+
+func TestSQLiteRepositoryPersistsKeyRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing.db")
+	repository, deviceService, transactionService := openSQLiteServices(t, path)
+
+	device, err := deviceService.CreateSignatureDevice("ECC", "till", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	deviceId := device.ID.String()
+	rotateKey(t, deviceService, transactionService, deviceId)
+	repository.Close()
+
+	repository, deviceService, transactionService = openSQLiteServices(t, path)
+	defer repository.Close()
+
+	restored, _ := deviceService.GetDevice(deviceId)
+	if generation := restored.CurrentKeyGeneration(); generation != 2 {
+		t.Fatalf("Expected key generation 2, got %d", generation)
+	}
+	assertRotatedChain(t, transactionService, deviceId)
+}
+
+func TestSQLiteRepositoryPersistsBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing.db")
+	repository, deviceService, transactionService := openSQLiteServices(t, path)
+
+	device, err := deviceService.CreateSignatureDevice("ECC", "", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	deviceId := device.ID.String()
+
+	batch, err := transactionService.SignTransactions(deviceId, []string{"first", "second", "third"})
+	if err != nil {
+		t.Fatalf("Failed to sign batch: %v", err)
+	}
+	if err := repository.SaveTransactions(batch); !infrastructure.IsVersionConflict(err) {
+		t.Errorf("Expected saving a batch with a stale counter to conflict, got %v", err)
+	}
+	repository.Close()
+
+	repository, deviceService, transactionService = openSQLiteServices(t, path)
+	defer repository.Close()
+
+	restored, _ := deviceService.GetDevice(deviceId)
+	if restored.SignatureCounter != 3 {
+		t.Errorf("Expected counter 3 after reopening, got %d", restored.SignatureCounter)
+	}
+	report, err := transactionService.AuditDevice(deviceId)
+	if err != nil {
+		t.Fatalf("Failed to audit device: %v", err)
+	}
+	if !report.Valid || report.TransactionsChecked != 3 {
+		t.Errorf("Expected an intact chain of 3 transactions, got %+v", report)
+	}
+}
+
+func TestSQLiteRepositoryPagesTransactions(t *testing.T) {
+	repository, deviceService, transactionService := openSQLiteServices(t, filepath.Join(t.TempDir(), "signing.db"))
+	defer repository.Close()
+
+	device, err := deviceService.CreateSignatureDevice("ED25519", "", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	deviceId := device.ID.String()
+	if _, err := transactionService.SignTransactions(deviceId, []string{"a", "b", "c", "d", "e"}); err != nil {
+		t.Fatalf("Failed to sign batch: %v", err)
+	}
+
+	assertTransactionPages(t, repository, deviceId)
+}
+
+// assertTransactionPages checks the pages of a device holding five transactions.
+func assertTransactionPages(t *testing.T, repository infrastructure.TransactionRepository, deviceId string) {
+	t.Helper()
+
+	testCases := []struct {
+		offset, limit int
+		counters      []int
+	}{
+		{0, 2, []int{0, 1}},
+		{3, 10, []int{3, 4}},
+		{5, 2, []int{}},
+	}
+	for _, tc := range testCases {
+		transactions, total, err := repository.GetTransactionsPage(deviceId, tc.offset, tc.limit)
+		if err != nil {
+			t.Fatalf("Failed to get transactions at offset %d: %v", tc.offset, err)
+		}
+		if total != 5 {
+			t.Errorf("Expected a total of 5 transactions, got %d", total)
+		}
+		if len(transactions) != len(tc.counters) {
+			t.Fatalf("Expected %d transactions at offset %d, got %d", len(tc.counters), tc.offset, len(transactions))
+		}
+		for i, transaction := range transactions {
+			if transaction.Counter != tc.counters[i] {
+				t.Errorf("Expected counter %d at offset %d, got %d", tc.counters[i], tc.offset, transaction.Counter)
+			}
+		}
+	}
+}
+
+func TestSQLiteRepositoryPersistsSecuredDataFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing.db")
+	repository, deviceService, transactionService := openSQLiteServices(t, path)
+
+	device, err := deviceService.CreateSignatureDeviceWithFormat("ED25519", "", crypto.Parameters{}, domain.SecuredDataFormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	deviceId := device.ID.String()
+	if _, err := transactionService.SignTransaction(deviceId, "first"); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	repository.Close()
+
+	repository, deviceService, transactionService = openSQLiteServices(t, path)
+	defer repository.Close()
+
+	restored, _ := deviceService.GetDevice(deviceId)
+	if restored.SecuredDataFormat != domain.SecuredDataFormatJSON {
+		t.Fatalf("Expected format %s after reopening, got %s", domain.SecuredDataFormatJSON, restored.SecuredDataFormat)
+	}
+	transaction, err := transactionService.SignTransaction(deviceId, "second")
+	if err != nil {
+		t.Fatalf("Failed to sign after reopening: %v", err)
+	}
+	if !strings.HasPrefix(transaction.SecuredData, `{"counter":1,"data":"second",`) {
+		t.Errorf("Expected JSON secured data, got %s", transaction.SecuredData)
+	}
+	report, err := transactionService.AuditDevice(deviceId)
+	if err != nil {
+		t.Fatalf("Failed to audit device: %v", err)
+	}
+	if !report.Valid || report.TransactionsChecked != 2 {
+		t.Errorf("Expected an intact chain of 2 transactions, got %+v", report)
+	}
+}
@@ -1,10 +1,101 @@
 package infrastructure
 
-import "github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+import (
+	"errors"
+	"fmt"
 
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// DeviceRepository stores signature devices.
+// UpdateDevice fails with a *VersionConflictError if the device's version is no longer the stored one.
 type DeviceRepository interface {
 	Save(id string, device *domain.SignatureDevice) error // Now returns an error
 	GetDeviceById(id string) (*domain.SignatureDevice, bool)
 	UpdateDevice(device *domain.SignatureDevice) error
 	GetAllDevices() ([]*domain.SignatureDevice, error)
 }
+
+// PrivateKeyUpdater is implemented by repositories that persist private keys, so that
+// re-encrypted keys can be written back.
+type PrivateKeyUpdater interface {
+	UpdatePrivateKey(device *domain.SignatureDevice) error
+}
+
+// TransactionRepository stores every transaction signed by a signature device.
+// SaveTransaction fails with a *VersionConflictError if the transaction does not continue the stored chain.
+type TransactionRepository interface {
+	SaveTransaction(transaction *domain.Transaction) error
+	GetTransaction(deviceId string, counter int) (*domain.Transaction, bool)
+	GetTransactionsByDevice(deviceId string) ([]*domain.Transaction, error)
+	// GetTransactionsPage returns up to limit transactions of a device, starting at offset in counter order,
+	// together with the total number of transactions of that device.
+	GetTransactionsPage(deviceId string, offset, limit int) ([]*domain.Transaction, int, error)
+}
+
+// KeyRotationRepository is implemented by transaction repositories that also persist device keys, so that a
+// rotation record and the device's new key are stored atomically. Like SaveTransaction, SaveKeyRotation fails
+// with a *VersionConflictError if the rotation record does not continue the stored chain.
+type KeyRotationRepository interface {
+	SaveKeyRotation(rotation *domain.KeyRotation) error
+}
+
+// BatchTransactionRepository is implemented by transaction repositories that can store consecutive transactions
+// of a device atomically, so that a batch is either stored completely or not at all. Like SaveTransaction,
+// SaveTransactions fails with a *VersionConflictError if the batch does not continue the stored chain.
+type BatchTransactionRepository interface {
+	SaveTransactions(transactions []*domain.Transaction) error
+}
+
+// VersionConflictError reports that a device was modified concurrently, so that a write based on
+// an outdated state was rejected. For transactions the signature counter acts as the version.
+type VersionConflictError struct {
+	DeviceID string
+	Stored   int
+	Given    int
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf(
+		"device %s was modified concurrently: stored version is %d, update is based on %d",
+		e.DeviceID, e.Stored, e.Given,
+	)
+}
+
+// IsVersionConflict reports whether err is or wraps a *VersionConflictError.
+func IsVersionConflict(err error) bool {
+	var conflict *VersionConflictError
+	return errors.As(err, &conflict)
+}
+
+// transactionPage copies the transactions within offset and limit.
+func transactionPage(transactions []*domain.Transaction, offset, limit int) []*domain.Transaction {
+	if offset >= len(transactions) {
+		return []*domain.Transaction{}
+	}
+	end := offset + limit
+	if end > len(transactions) {
+		end = len(transactions)
+	}
+
+	page := make([]*domain.Transaction, end-offset)
+	copy(page, transactions[offset:end])
+	return page
+}
+
+// checkBatch verifies that a batch holds consecutive transactions of a single device.
+func checkBatch(transactions []*domain.Transaction) error {
+	if len(transactions) == 0 {
+		return errors.New("batch holds no transactions")
+	}
+	first := transactions[0]
+	for i, transaction := range transactions {
+		if transaction.DeviceID != first.DeviceID {
+			return fmt.Errorf("batch mixes transactions of devices %s and %s", first.DeviceID, transaction.DeviceID)
+		}
+		if transaction.Counter != first.Counter+i {
+			return fmt.Errorf("batch of device %s is not consecutive at counter %d", first.DeviceID, transaction.Counter)
+		}
+	}
+	return nil
+}
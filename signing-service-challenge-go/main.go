@@ -1,23 +1,143 @@
 package main
 
 import (
+	"flag"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
 	"log"
+	"os"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/infrastructure"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/remote"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 const (
 	ListenAddress = ":8600"
+	// KEKEnv and PreviousKEKEnv hold base64 encoded key-encryption keys if no key files are given.
+	KEKEnv         = "SIGNING_SERVICE_KEK"
+	PreviousKEKEnv = "SIGNING_SERVICE_PREVIOUS_KEK"
 	// TODO: add further configuration parameters here ...
 )
 
+// registerKeyBackends adds algorithms whose keys are kept outside the process to the registry
+// and returns a function releasing them. Builds with the pkcs11 tag add PKCS#11 tokens.
+var registerKeyBackends = func(registry *crypto.Registry) (func(), error) {
+	return func() {}, nil
+}
+
 func main() {
-	deviceRepository := infrastructure.NewInMemoryRepository()
+	storage := flag.String("storage", "memory", "storage backend: memory, file, sqlite or postgres")
+	fileDir := flag.String("file-dir", "data", "directory used by the file storage backend")
+	fileCompactEvery := flag.Int("file-compact-every", 1000, "number of log entries after which the file storage backend writes a snapshot")
+	sqlitePath := flag.String("sqlite-path", "signing-service.db", "database file used by the sqlite storage backend")
+	postgresDSN := flag.String("postgres-dsn", "", "connection string used by the postgres storage backend")
+	kekFile := flag.String("kek-file", "", "file holding the base64 encoded key-encryption key private keys are sealed with")
+	previousKEKFile := flag.String("previous-kek-file", "", "file holding the previous key-encryption key, whose keys are re-wrapped on startup")
+	remoteSigner := flag.String("remote-signer", "", "address of a key custody server to offer -REMOTE algorithms with")
+	remoteSignerCA := flag.String("remote-signer-ca", "", "CA certificate to verify the key custody server with; connects without TLS if empty, which the server only allows on loopback")
+	remoteSignerCert := flag.String("remote-signer-cert", "", "client certificate to authenticate to the key custody server with")
+	remoteSignerKey := flag.String("remote-signer-key", "", "private key of the client certificate")
+	idempotencyWindow := flag.Duration("idempotency-window", service.DefaultIdempotencyWindow, "how long idempotency keys of sign requests are remembered")
+	flag.Parse()
+
+	// Only the built-in algorithms are sealed. Keys of remote and PKCS#11 algorithms never enter
+	// the process, so their references are registered unsealed on top of the sealed registry.
+	algorithms := crypto.DefaultRegistry
+	var sealer *crypto.KeySealer
+	kek, err := loadKeyEncryptionKey(*kekFile, KEKEnv)
+	if err != nil {
+		log.Fatal("Could not load key-encryption key: ", err)
+	}
+	if kek != nil {
+		previousKEK, err := loadKeyEncryptionKey(*previousKEKFile, PreviousKEKEnv)
+		if err != nil {
+			log.Fatal("Could not load previous key-encryption key: ", err)
+		}
+		if previousKEK != nil {
+			sealer = crypto.NewKeySealer(kek, previousKEK)
+		} else {
+			sealer = crypto.NewKeySealer(kek)
+		}
+		algorithms = crypto.NewSealedRegistry(crypto.DefaultRegistry, sealer)
+	}
+
+	// Remote variants are registered before the key backends, so that they only cover the built-in algorithms.
+	if *remoteSigner != "" {
+		transport := insecure.NewCredentials()
+		if *remoteSignerCA != "" {
+			var err error
+			transport, err = remote.ClientCredentials(*remoteSignerCA, *remoteSignerCert, *remoteSignerKey)
+			if err != nil {
+				log.Fatal("Could not set up mutual TLS with the key custody server: ", err)
+			}
+		}
+		client, err := remote.NewClient(*remoteSigner, grpc.WithTransportCredentials(transport))
+		if err != nil {
+			log.Fatal("Could not connect to key custody server: ", err)
+		}
+		defer client.Close()
+		if err := remote.RegisterAlgorithms(algorithms, crypto.DefaultRegistry, client); err != nil {
+			log.Fatal("Could not register remote algorithms: ", err)
+		}
+	}
+
+	closeKeyBackends, err := registerKeyBackends(algorithms)
+	if err != nil {
+		log.Fatal("Could not set up key backends: ", err)
+	}
+	defer closeKeyBackends()
 
-	deviceService := service.NewDeviceService(deviceRepository)
-	transactionService := service.NewTransactionService(deviceRepository)
+	var (
+		deviceRepository      infrastructure.DeviceRepository
+		transactionRepository infrastructure.TransactionRepository
+	)
+	switch *storage {
+	case "memory":
+		deviceRepository = infrastructure.NewInMemoryRepository()
+		transactionRepository = infrastructure.NewInMemoryTransactionRepository()
+	case "file":
+		repository, err := infrastructure.NewFileRepository(*fileDir, algorithms, *fileCompactEvery)
+		if err != nil {
+			log.Fatal("Could not open file storage: ", err)
+		}
+		defer repository.Close()
+		deviceRepository = repository
+		transactionRepository = repository
+	case "sqlite":
+		repository, err := infrastructure.NewSQLiteRepository(*sqlitePath, algorithms)
+		if err != nil {
+			log.Fatal("Could not open sqlite database: ", err)
+		}
+		defer repository.Close()
+		deviceRepository = repository
+		transactionRepository = repository
+	case "postgres":
+		repository, err := infrastructure.NewPostgresRepository(*postgresDSN, algorithms)
+		if err != nil {
+			log.Fatal("Could not connect to postgres database: ", err)
+		}
+		defer repository.Close()
+		deviceRepository = repository
+		transactionRepository = repository
+	default:
+		log.Fatal("Unknown storage backend ", *storage)
+	}
+
+	deviceService := service.NewDeviceService(deviceRepository, algorithms)
+	transactionService := service.NewTransactionService(deviceRepository, transactionRepository)
+	transactionService.SetIdempotencyStore(infrastructure.NewInMemoryIdempotencyStore(*idempotencyWindow))
+
+	if sealer != nil {
+		// Seals keys stored in plaintext and moves keys off the previous key-encryption key.
+		rewrapped, err := deviceService.RewrapPrivateKeys(sealer)
+		if err != nil {
+			log.Fatal("Could not re-wrap private keys: ", err)
+		}
+		log.Printf("Private keys are sealed under key-encryption key %s, re-wrapped %d keys", sealer.CurrentKeyID(), rewrapped)
+	}
 	
 	server := api.NewServer(ListenAddress, deviceRepository, deviceService, transactionService)
 
@@ -25,3 +145,15 @@ func main() {
 		log.Fatal("Could not start server on ", ListenAddress)
 	}
 }
+
+// loadKeyEncryptionKey loads a key-encryption key from path, or from the environment variable
+// envName if path is empty. It returns nil if neither is set.
+func loadKeyEncryptionKey(path, envName string) (*crypto.KeyEncryptionKey, error) {
+	if path != "" {
+		return crypto.LoadKeyEncryptionKey(path)
+	}
+	if _, exists := os.LookupEnv(envName); exists {
+		return crypto.KeyEncryptionKeyFromEnv(envName)
+	}
+	return nil, nil
+}
@@ -0,0 +1,36 @@
+//go:build pkcs11
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+// PKCS11PINEnv holds the user PIN of the PKCS#11 token.
+const PKCS11PINEnv = "SIGNING_SERVICE_PKCS11_PIN"
+
+var (
+	pkcs11Module = flag.String("pkcs11-module", "", "PKCS#11 module to keep device keys on, e.g. libsofthsm2.so")
+	pkcs11Token  = flag.String("pkcs11-token", "", "label of the PKCS#11 token")
+)
+
+func init() {
+	registerKeyBackends = func(registry *crypto.Registry) (func(), error) {
+		if *pkcs11Module == "" {
+			return func() {}, nil
+		}
+
+		token, err := crypto.OpenPKCS11Token(*pkcs11Module, *pkcs11Token, os.Getenv(PKCS11PINEnv))
+		if err != nil {
+			return nil, err
+		}
+		if err := crypto.RegisterPKCS11Algorithms(registry, token); err != nil {
+			token.Close()
+			return nil, err
+		}
+		return func() { token.Close() }, nil
+	}
+}
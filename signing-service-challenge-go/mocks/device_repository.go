@@ -2,14 +2,21 @@ package mocks
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/infrastructure"
 )
 
 // MockDeviceRepository is a simple mock implementation of the DeviceRepository interface.
 type MockDeviceRepository struct {
+	mu             sync.Mutex
 	SavedDevices   map[string]*domain.SignatureDevice
 	GetDeviceCalls []string
+	// UpdateConflicts is the number of upcoming UpdateDevice calls that fail with a version conflict.
+	UpdateConflicts int
+	// UpdateError, if set, is returned by all UpdateDevice calls.
+	UpdateError error
 }
 
 // NewMockDeviceRepository creates and returns a new instance of MockDeviceRepository.
@@ -22,6 +29,9 @@ func NewMockDeviceRepository() *MockDeviceRepository {
 
 // Save adds a new device to the mock store.
 func (m *MockDeviceRepository) Save(id string, device *domain.SignatureDevice) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if _, exists := m.SavedDevices[id]; exists {
 		return fmt.Errorf("device with id %s already exists", id)
 	}
@@ -31,6 +41,9 @@ func (m *MockDeviceRepository) Save(id string, device *domain.SignatureDevice) e
 
 // GetDeviceById retrieves a device by its ID.
 func (m *MockDeviceRepository) GetDeviceById(id string) (*domain.SignatureDevice, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.GetDeviceCalls = append(m.GetDeviceCalls, id)
 	device, exists := m.SavedDevices[id]
 	return device, exists
@@ -38,15 +51,33 @@ func (m *MockDeviceRepository) GetDeviceById(id string) (*domain.SignatureDevice
 
 // UpdateDevice updates an existing device in the mock store.
 func (m *MockDeviceRepository) UpdateDevice(device *domain.SignatureDevice) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if _, exists := m.SavedDevices[device.ID.String()]; !exists {
 		return fmt.Errorf("device with id %s not found", device.ID.String())
 	}
-	m.SavedDevices[device.ID.String()] = device
+	if m.UpdateError != nil {
+		return m.UpdateError
+	}
+	if m.UpdateConflicts > 0 {
+		m.UpdateConflicts--
+		version := device.CurrentVersion()
+		return &infrastructure.VersionConflictError{DeviceID: device.ID.String(), Stored: version + 1, Given: version}
+	}
+	version := device.CurrentVersion() + 1
+	label, metadata := device.Details()
+	status, decommissionedAt := device.LifecycleState()
+	m.SavedDevices[device.ID.String()].SyncStoredState(version, label, metadata, status, decommissionedAt)
+	device.SyncVersion(version)
 	return nil
 }
 
 // GetAllDevices returns all stored devices.
 func (m *MockDeviceRepository) GetAllDevices() ([]*domain.SignatureDevice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	var devices []*domain.SignatureDevice
 	for _, device := range m.SavedDevices {
 		devices = append(devices, device)
@@ -0,0 +1,102 @@
+package mocks
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// MockTransactionRepository is a simple mock implementation of the TransactionRepository interface.
+type MockTransactionRepository struct {
+	mu                sync.Mutex
+	SavedTransactions map[string][]*domain.Transaction
+	SaveError         error
+}
+
+// NewMockTransactionRepository creates and returns a new instance of MockTransactionRepository.
+func NewMockTransactionRepository() *MockTransactionRepository {
+	return &MockTransactionRepository{
+		SavedTransactions: make(map[string][]*domain.Transaction),
+	}
+}
+
+// SaveTransaction appends a transaction to the mock store, or fails with SaveError if set.
+func (m *MockTransactionRepository) SaveTransaction(transaction *domain.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SaveError != nil {
+		return m.SaveError
+	}
+
+	deviceId := transaction.DeviceID.String()
+	if expected := len(m.SavedTransactions[deviceId]); transaction.Counter != expected {
+		return fmt.Errorf("unexpected transaction counter %d, expected %d", transaction.Counter, expected)
+	}
+	m.SavedTransactions[deviceId] = append(m.SavedTransactions[deviceId], transaction)
+	return nil
+}
+
+// SaveTransactions appends a batch of transactions to the mock store at once, or fails with SaveError if set.
+func (m *MockTransactionRepository) SaveTransactions(transactions []*domain.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.SaveError != nil {
+		return m.SaveError
+	}
+
+	for i, transaction := range transactions {
+		deviceId := transaction.DeviceID.String()
+		if expected := len(m.SavedTransactions[deviceId]) + i; transaction.Counter != expected {
+			return fmt.Errorf("unexpected transaction counter %d, expected %d", transaction.Counter, expected)
+		}
+	}
+	for _, transaction := range transactions {
+		deviceId := transaction.DeviceID.String()
+		m.SavedTransactions[deviceId] = append(m.SavedTransactions[deviceId], transaction)
+	}
+	return nil
+}
+
+// GetTransaction retrieves a transaction by device ID and counter.
+func (m *MockTransactionRepository) GetTransaction(deviceId string, counter int) (*domain.Transaction, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	transactions := m.SavedTransactions[deviceId]
+	if counter < 0 || counter >= len(transactions) {
+		return nil, false
+	}
+	return transactions[counter], true
+}
+
+// GetTransactionsByDevice returns all stored transactions of a device.
+func (m *MockTransactionRepository) GetTransactionsByDevice(deviceId string) ([]*domain.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	transactions := make([]*domain.Transaction, len(m.SavedTransactions[deviceId]))
+	copy(transactions, m.SavedTransactions[deviceId])
+	return transactions, nil
+}
+
+// GetTransactionsPage returns a page of the stored transactions of a device and their total number.
+func (m *MockTransactionRepository) GetTransactionsPage(deviceId string, offset, limit int) ([]*domain.Transaction, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	transactions := m.SavedTransactions[deviceId]
+	if offset >= len(transactions) {
+		return []*domain.Transaction{}, len(transactions), nil
+	}
+	end := offset + limit
+	if end > len(transactions) {
+		end = len(transactions)
+	}
+
+	page := make([]*domain.Transaction, end-offset)
+	copy(page, transactions[offset:end])
+	return page, len(transactions), nil
+}
@@ -0,0 +1,120 @@
+package remote
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+// AlgorithmSuffix is appended to the names of algorithms whose keys are held by a key custody server.
+const AlgorithmSuffix = "-REMOTE"
+
+const keyReferencePEMType = "REMOTE_KEY_REFERENCE"
+
+// PrivateKey references a private key held by a key custody server.
+type PrivateKey struct {
+	Client *Client
+	KeyID  string
+
+	publicKey []byte
+}
+
+// Signer forwards signing to the key custody server holding the key.
+type Signer struct {
+	Key *PrivateKey
+}
+
+func (s *Signer) Sign(dataToBeSigned []byte) ([]byte, error) {
+	response, err := s.Key.Client.Sign(&SignRequest{KeyID: s.Key.KeyID, Data: dataToBeSigned})
+	if err != nil {
+		return nil, fmt.Errorf("remote signing with key %s failed: %w", s.Key.KeyID, err)
+	}
+	return response.Signature, nil
+}
+
+// RegisterAlgorithms adds a remote variant of every algorithm of base to the registry, named
+// with AlgorithmSuffix, e.g. "ECC-REMOTE". Keys of devices created with them are generated and
+// kept by the key custody server behind client; the API process only stores a reference.
+// Signatures are still verified locally.
+func RegisterAlgorithms(registry, base *crypto.Registry, client *Client) error {
+	for _, algorithm := range base.List() {
+		if err := registry.Register(remoteAlgorithm(*algorithm, client)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func remoteAlgorithm(algorithm crypto.Algorithm, client *Client) crypto.Algorithm {
+	name := algorithm.Name
+	marshaler := &keyReferenceMarshaler{client: client}
+
+	algorithm.Name = name + AlgorithmSuffix
+	algorithm.Metadata.Description += ", with keys held by a key custody server"
+	algorithm.Marshaler = marshaler
+	algorithm.Generate = func(parameters crypto.Parameters) (*crypto.KeyPair, error) {
+		response, err := client.GenerateKey(&GenerateKeyRequest{Algorithm: name, Parameters: parameters})
+		if err != nil {
+			return nil, fmt.Errorf("remote key generation failed: %w", err)
+		}
+		publicKey, err := crypto.ParsePublicKeyPEM(response.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		return &crypto.KeyPair{
+			Public:  publicKey,
+			Private: &PrivateKey{Client: client, KeyID: response.KeyID, publicKey: response.PublicKey},
+		}, nil
+	}
+	algorithm.NewSigner = func(privateKey interface{}, _ crypto.Parameters) (crypto.Signer, error) {
+		// The server signs with the parameters the key was generated with.
+		key, ok := privateKey.(*PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("invalid private key type for %s: %T", name+AlgorithmSuffix, privateKey)
+		}
+		return &Signer{Key: key}, nil
+	}
+	return algorithm
+}
+
+// keyReferenceMarshaler stores the ID of a remote key instead of the key itself.
+type keyReferenceMarshaler struct {
+	client *Client
+}
+
+func (m *keyReferenceMarshaler) Marshal(keyPair crypto.KeyPair) ([]byte, []byte, error) {
+	key, ok := keyPair.Private.(*PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid private key type for remote keys: %T", keyPair.Private)
+	}
+
+	reference := pem.EncodeToMemory(&pem.Block{
+		Type:  keyReferencePEMType,
+		Bytes: []byte(key.KeyID),
+	})
+	return key.publicKey, reference, nil
+}
+
+func (m *keyReferenceMarshaler) Unmarshal(privateKeyBytes []byte) (*crypto.KeyPair, error) {
+	block, _ := pem.Decode(privateKeyBytes)
+	if block == nil || block.Type != keyReferencePEMType {
+		return nil, errors.New("failed to decode remote key reference")
+	}
+
+	keyID := string(block.Bytes)
+	response, err := m.client.GetPublicKey(&GetPublicKeyRequest{KeyID: keyID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch public key of remote key %s: %w", keyID, err)
+	}
+	publicKey, err := crypto.ParsePublicKeyPEM(response.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &crypto.KeyPair{
+		Public:  publicKey,
+		Private: &PrivateKey{Client: m.client, KeyID: keyID, publicKey: response.PublicKey},
+	}, nil
+}
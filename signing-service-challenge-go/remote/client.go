@@ -0,0 +1,58 @@
+package remote
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// DefaultTimeout bounds every call to the key custody server.
+const DefaultTimeout = 5 * time.Second
+
+// Client talks to a key custody server.
+type Client struct {
+	conn    *grpc.ClientConn
+	timeout time.Duration
+}
+
+// NewClient creates a client for the key custody server at target. The options must at least
+// provide the transport credentials.
+func NewClient(target string, options ...grpc.DialOption) (*Client, error) {
+	options = append(options, grpc.WithDefaultCallOptions(grpc.CallContentSubtype(codecName)))
+	conn, err := grpc.NewClient(target, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, timeout: DefaultTimeout}, nil
+}
+
+// Close closes the connection to the server.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// GenerateKey asks the server to generate a key pair.
+func (c *Client) GenerateKey(request *GenerateKeyRequest) (*GenerateKeyResponse, error) {
+	response := new(GenerateKeyResponse)
+	return response, c.invoke("GenerateKey", request, response)
+}
+
+// GetPublicKey fetches the public key of a key held by the server.
+func (c *Client) GetPublicKey(request *GetPublicKeyRequest) (*GetPublicKeyResponse, error) {
+	response := new(GetPublicKeyResponse)
+	return response, c.invoke("GetPublicKey", request, response)
+}
+
+// Sign asks the server to sign data.
+func (c *Client) Sign(request *SignRequest) (*SignResponse, error) {
+	response := new(SignResponse)
+	return response, c.invoke("Sign", request, response)
+}
+
+func (c *Client) invoke(method string, request, response any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	return c.conn.Invoke(ctx, fullMethod(method), request, response)
+}
@@ -0,0 +1,41 @@
+package remote
+
+import (
+	"context"
+	"net"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// StartLocal runs a key custody server in-process on an in-memory listener, e.g. for tests,
+// and returns a client connected to it. The returned function stops both.
+func StartLocal(algorithms *crypto.Registry, options ...grpc.ServerOption) (*Client, func(), error) {
+	custody, err := NewServer(algorithms, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(options...)
+	RegisterKeyCustodyServer(server, custody)
+	go server.Serve(listener)
+
+	client, err := NewClient("passthrough:///bufconn",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+	)
+	if err != nil {
+		server.Stop()
+		return nil, nil, err
+	}
+
+	return client, func() {
+		client.Close()
+		server.Stop()
+	}, nil
+}
@@ -0,0 +1,39 @@
+package remote
+
+import "github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+
+// GenerateKeyRequest asks the key custody server to generate a key pair.
+type GenerateKeyRequest struct {
+	Algorithm  string            `json:"algorithm"`
+	Parameters crypto.Parameters `json:"parameters"`
+}
+
+// GenerateKeyResponse identifies the generated key and carries its public key,
+// encoded with the marshaler of the algorithm.
+type GenerateKeyResponse struct {
+	KeyID     string `json:"key_id"`
+	PublicKey []byte `json:"public_key"`
+}
+
+// GetPublicKeyRequest asks for the public key of a key held by the server.
+type GetPublicKeyRequest struct {
+	KeyID string `json:"key_id"`
+}
+
+// GetPublicKeyResponse carries the public key and the algorithm of a key.
+type GetPublicKeyResponse struct {
+	Algorithm  string            `json:"algorithm"`
+	Parameters crypto.Parameters `json:"parameters"`
+	PublicKey  []byte            `json:"public_key"`
+}
+
+// SignRequest asks the server to sign data with one of its keys.
+type SignRequest struct {
+	KeyID string `json:"key_id"`
+	Data  []byte `json:"data"`
+}
+
+// SignResponse carries the signature.
+type SignResponse struct {
+	Signature []byte `json:"signature"`
+}
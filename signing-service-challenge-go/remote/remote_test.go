@@ -0,0 +1,202 @@
+package remote_test
+
+import (
+	"context"
+	"crypto/rand"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/infrastructure"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/remote"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRemoteAlgorithms(t *testing.T) {
+	client, stop, err := remote.StartLocal(crypto.DefaultRegistry)
+	if err != nil {
+		t.Fatalf("Failed to start key custody server: %v", err)
+	}
+	defer stop()
+
+	registry := crypto.NewRegistry()
+	if err := remote.RegisterAlgorithms(registry, crypto.DefaultRegistry, client); err != nil {
+		t.Fatalf("Failed to register remote algorithms: %v", err)
+	}
+
+	deviceRepository := infrastructure.NewInMemoryRepository()
+	deviceService := service.NewDeviceService(deviceRepository, registry)
+	transactionService := service.NewTransactionService(deviceRepository, infrastructure.NewInMemoryTransactionRepository())
+
+	for _, name := range []string{"RSA", "RSA-PSS", "ECC", "ED25519"} {
+		t.Run(name, func(t *testing.T) {
+			device, err := deviceService.CreateSignatureDevice(name+remote.AlgorithmSuffix, "", crypto.Parameters{})
+			if err != nil {
+				t.Fatalf("Failed to create device: %v", err)
+			}
+			if _, ok := device.PrivateKey.(*remote.PrivateKey); !ok {
+				t.Fatalf("Expected the private key to stay on the server, got %T", device.PrivateKey)
+			}
+
+			transaction, err := transactionService.SignTransaction(device.ID.String(), "data")
+			if err != nil {
+				t.Fatalf("Failed to sign transaction: %v", err)
+			}
+			valid, err := transactionService.VerifySignature(device.ID.String(), transaction.SecuredData, transaction.Signature)
+			if err != nil || !valid {
+				t.Fatalf("Expected remote signature to verify locally, got %v (%v)", valid, err)
+			}
+
+			// Restore the key from its stored reference, as a repository would.
+			algorithm, _ := registry.Get(device.Algorithm)
+			_, reference, err := algorithm.Marshaler.Marshal(crypto.KeyPair{Public: device.PublicKey, Private: device.PrivateKey})
+			if err != nil {
+				t.Fatalf("Failed to marshal key reference: %v", err)
+			}
+			restored, err := algorithm.Marshaler.Unmarshal(reference)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal key reference: %v", err)
+			}
+			signer, err := algorithm.NewSigner(restored.Private, device.Parameters)
+			if err != nil {
+				t.Fatalf("Failed to create signer: %v", err)
+			}
+			signature, err := signer.Sign([]byte("more data"))
+			if err != nil {
+				t.Fatalf("Failed to sign with restored key: %v", err)
+			}
+			if valid, _ := device.Verifier.Verify([]byte("more data"), signature); !valid {
+				t.Fatal("Expected signature of restored key to verify")
+			}
+		})
+	}
+}
+
+func TestRemoteAlgorithmsWithSealedRegistry(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	countCalls := func(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		mu.Lock()
+		calls[path.Base(info.FullMethod)]++
+		mu.Unlock()
+		return handler(ctx, request)
+	}
+
+	client, stop, err := remote.StartLocal(crypto.DefaultRegistry, grpc.UnaryInterceptor(countCalls))
+	if err != nil {
+		t.Fatalf("Failed to start key custody server: %v", err)
+	}
+	defer stop()
+
+	key := make([]byte, crypto.KeyEncryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("Failed to generate key-encryption key: %v", err)
+	}
+	kek, err := crypto.NewKeyEncryptionKey(key)
+	if err != nil {
+		t.Fatalf("Failed to create key-encryption key: %v", err)
+	}
+
+	// Wired up like the service does with a key-encryption key and a key custody server.
+	registry := crypto.NewSealedRegistry(crypto.DefaultRegistry, crypto.NewKeySealer(kek))
+	if err := remote.RegisterAlgorithms(registry, crypto.DefaultRegistry, client); err != nil {
+		t.Fatalf("Failed to register remote algorithms: %v", err)
+	}
+
+	deviceRepository := infrastructure.NewInMemoryRepository()
+	deviceService := service.NewDeviceService(deviceRepository, registry)
+	transactionService := service.NewTransactionService(deviceRepository, infrastructure.NewInMemoryTransactionRepository())
+
+	local, err := deviceService.CreateSignatureDevice("ECC", "", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	if _, ok := local.PrivateKey.(*crypto.SealedPrivateKey); !ok {
+		t.Errorf("Expected the key of a built-in algorithm to be sealed, got %T", local.PrivateKey)
+	}
+
+	device, err := deviceService.CreateSignatureDevice("ECC"+remote.AlgorithmSuffix, "", crypto.Parameters{})
+	if err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	if _, ok := device.PrivateKey.(*remote.PrivateKey); !ok {
+		t.Fatalf("Expected the remote key reference not to be sealed, got %T", device.PrivateKey)
+	}
+
+	mu.Lock()
+	clear(calls)
+	mu.Unlock()
+
+	for i := 0; i < 3; i++ {
+		if _, err := transactionService.SignTransaction(device.ID.String(), "data"); err != nil {
+			t.Fatalf("Failed to sign transaction: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["Sign"] != 3 || calls["GetPublicKey"] != 0 {
+		t.Errorf("Expected one Sign call per signature and no public key lookups, got %v", calls)
+	}
+}
+
+func TestServerPersistsKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	server, err := remote.NewServer(crypto.DefaultRegistry, dir)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	generated, err := server.GenerateKey(context.Background(), &remote.GenerateKeyRequest{
+		Algorithm:  "ECC",
+		Parameters: crypto.Parameters{Curve: "P-256", Hash: "SHA-256"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	restarted, err := remote.NewServer(crypto.DefaultRegistry, dir)
+	if err != nil {
+		t.Fatalf("Failed to restart server: %v", err)
+	}
+	if _, err := restarted.Sign(context.Background(), &remote.SignRequest{KeyID: generated.KeyID, Data: []byte("data")}); err != nil {
+		t.Fatalf("Failed to sign with persisted key: %v", err)
+	}
+	if _, err := restarted.Sign(context.Background(), &remote.SignRequest{KeyID: "unknown", Data: []byte("data")}); err == nil {
+		t.Error("Expected signing with an unknown key to fail")
+	}
+}
+
+func TestServerEnforcesParameterPolicy(t *testing.T) {
+	server, err := remote.NewServer(crypto.DefaultRegistry, "")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	for _, parameters := range []crypto.Parameters{
+		{RSABits: 65536},
+		{RSABits: 512},
+		{Curve: "P-256"},
+	} {
+		_, err := server.GenerateKey(context.Background(), &remote.GenerateKeyRequest{Algorithm: "RSA", Parameters: parameters})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Expected parameters %+v to be rejected as invalid, got %v", parameters, err)
+		}
+	}
+
+	generated, err := server.GenerateKey(context.Background(), &remote.GenerateKeyRequest{Algorithm: "RSA"})
+	if err != nil {
+		t.Fatalf("Failed to generate key with default parameters: %v", err)
+	}
+	key, err := server.GetPublicKey(context.Background(), &remote.GetPublicKeyRequest{KeyID: generated.KeyID})
+	if err != nil {
+		t.Fatalf("Failed to get public key: %v", err)
+	}
+	if key.Parameters.RSABits != 2048 {
+		t.Errorf("Expected the default key size to be resolved, got %+v", key.Parameters)
+	}
+}
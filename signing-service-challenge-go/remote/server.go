@@ -0,0 +1,271 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const serviceName = "signing.remote.KeyCustody"
+
+// KeyCustodyServer is implemented by the key custody process.
+type KeyCustodyServer interface {
+	GenerateKey(ctx context.Context, request *GenerateKeyRequest) (*GenerateKeyResponse, error)
+	GetPublicKey(ctx context.Context, request *GetPublicKeyRequest) (*GetPublicKeyResponse, error)
+	Sign(ctx context.Context, request *SignRequest) (*SignResponse, error)
+}
+
+// RegisterKeyCustodyServer registers the key custody service on a gRPC server.
+func RegisterKeyCustodyServer(registrar grpc.ServiceRegistrar, server KeyCustodyServer) {
+	registrar.RegisterService(&keyCustodyServiceDesc, server)
+}
+
+var keyCustodyServiceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*KeyCustodyServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GenerateKey", Handler: unaryHandler("GenerateKey", KeyCustodyServer.GenerateKey)},
+		{MethodName: "GetPublicKey", Handler: unaryHandler("GetPublicKey", KeyCustodyServer.GetPublicKey)},
+		{MethodName: "Sign", Handler: unaryHandler("Sign", KeyCustodyServer.Sign)},
+	},
+}
+
+// unaryHandler adapts a KeyCustodyServer method to a grpc.MethodDesc handler.
+func unaryHandler[Request, Response any](
+	name string,
+	method func(KeyCustodyServer, context.Context, *Request) (*Response, error),
+) func(any, context.Context, func(any) error, grpc.UnaryServerInterceptor) (any, error) {
+	return func(server any, ctx context.Context, decode func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		request := new(Request)
+		if err := decode(request); err != nil {
+			return nil, err
+		}
+
+		handler := func(ctx context.Context, request any) (any, error) {
+			return method(server.(KeyCustodyServer), ctx, request.(*Request))
+		}
+		if interceptor == nil {
+			return handler(ctx, request)
+		}
+		return interceptor(ctx, request, &grpc.UnaryServerInfo{Server: server, FullMethod: fullMethod(name)}, handler)
+	}
+}
+
+func fullMethod(name string) string {
+	return "/" + serviceName + "/" + name
+}
+
+// custodyKey is a key held by the Server.
+type custodyKey struct {
+	Algorithm  string            `json:"algorithm"`
+	Parameters crypto.Parameters `json:"parameters"`
+	PrivateKey []byte            `json:"private_key"`
+
+	signer    crypto.Signer
+	publicKey []byte
+}
+
+// Server keeps private keys for signature devices and signs on their behalf,
+// so that the keys never enter the API process.
+type Server struct {
+	algorithms *crypto.Registry
+	dir        string
+
+	mu   sync.RWMutex
+	keys map[string]*custodyKey
+}
+
+// NewServer creates a key custody server for the algorithms in the registry. If dir is not
+// empty, keys are written to and loaded from it, one file per key; otherwise they only live in memory.
+// Passing a sealed registry encrypts the key files at rest.
+func NewServer(algorithms *crypto.Registry, dir string) (*Server, error) {
+	server := &Server{
+		algorithms: algorithms,
+		dir:        dir,
+		keys:       make(map[string]*custodyKey),
+	}
+	if dir == "" {
+		return server, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if err := server.loadKey(path); err != nil {
+			return nil, err
+		}
+	}
+	return server, nil
+}
+
+// GenerateKey generates and stores a key pair and returns its public key.
+// The requested parameters have to satisfy crypto.DefaultPolicy.
+func (s *Server) GenerateKey(_ context.Context, request *GenerateKeyRequest) (*GenerateKeyResponse, error) {
+	algorithm, exists := s.algorithms.Get(request.Algorithm)
+	if !exists {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported algorithm %s", request.Algorithm)
+	}
+
+	// Parameters are checked here as well, as the server cannot rely on its clients to do so.
+	parameters, err := crypto.DefaultPolicy.Resolve(algorithm, request.Parameters)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid parameters: %v", err)
+	}
+
+	keyPair, err := algorithm.Generate(parameters)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to generate key pair: %v", err)
+	}
+	publicKey, privateKey, err := algorithm.Marshaler.Marshal(*keyPair)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode key pair: %v", err)
+	}
+	signer, err := algorithm.NewSigner(keyPair.Private, parameters)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to create signer: %v", err)
+	}
+
+	id := uuid.NewString()
+	key := &custodyKey{
+		Algorithm:  algorithm.Name,
+		Parameters: parameters,
+		PrivateKey: privateKey,
+		signer:     signer,
+		publicKey:  publicKey,
+	}
+	if err := s.storeKey(id, key); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to store key: %v", err)
+	}
+
+	s.mu.Lock()
+	s.keys[id] = key
+	s.mu.Unlock()
+
+	return &GenerateKeyResponse{KeyID: id, PublicKey: publicKey}, nil
+}
+
+// GetPublicKey returns the public key of a stored key.
+func (s *Server) GetPublicKey(_ context.Context, request *GetPublicKeyRequest) (*GetPublicKeyResponse, error) {
+	key, err := s.key(request.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	return &GetPublicKeyResponse{Algorithm: key.Algorithm, Parameters: key.Parameters, PublicKey: key.publicKey}, nil
+}
+
+// Sign signs data with a stored key.
+func (s *Server) Sign(_ context.Context, request *SignRequest) (*SignResponse, error) {
+	key, err := s.key(request.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := key.signer.Sign(request.Data)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to sign: %v", err)
+	}
+	return &SignResponse{Signature: signature}, nil
+}
+
+func (s *Server) key(id string) (*custodyKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, exists := s.keys[id]
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "key %s not found", id)
+	}
+	return key, nil
+}
+
+// storeKey writes a key file atomically and durably, if the server has a directory. A key is only
+// handed out once its file survives a crash, as devices could not sign again without it.
+func (s *Server) storeKey(id string, key *custodyKey) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	content, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, id+".json")
+	if err := writeFileSynced(path+".tmp", content); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+// loadKey restores a key file written by storeKey.
+func (s *Server) loadKey(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var key custodyKey
+	if err := json.Unmarshal(content, &key); err != nil {
+		return fmt.Errorf("failed to decode key file %s: %w", path, err)
+	}
+
+	algorithm, exists := s.algorithms.Get(key.Algorithm)
+	if !exists {
+		return fmt.Errorf("algorithm %s of key file %s is not registered", key.Algorithm, path)
+	}
+	keyPair, err := algorithm.Marshaler.Unmarshal(key.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to decode key file %s: %w", path, err)
+	}
+	if key.publicKey, _, err = algorithm.Marshaler.Marshal(*keyPair); err != nil {
+		return err
+	}
+	if key.signer, err = algorithm.NewSigner(keyPair.Private, key.Parameters); err != nil {
+		return err
+	}
+
+	id := filepath.Base(path[:len(path)-len(".json")])
+	s.keys[id] = &key
+	return nil
+}
+
+func writeFileSynced(path string, content []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// syncDir makes a rename within dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
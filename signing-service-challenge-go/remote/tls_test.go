@@ -0,0 +1,169 @@
+package remote_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/remote"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// testCertificate is a certificate with its key, written to PEM files in a test directory.
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	certFile    string
+	keyFile     string
+}
+
+// issueCertificate creates a certificate from template, signed by issuer or self-signed if issuer is nil.
+func issueCertificate(t *testing.T, dir, name string, template *x509.Certificate, issuer *testCertificate) *testCertificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.certificate, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	issued := &testCertificate{
+		certificate: certificate,
+		key:         key,
+		certFile:    filepath.Join(dir, name+".crt"),
+		keyFile:     filepath.Join(dir, name+".key"),
+	}
+	if err := os.WriteFile(issued.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(issued.keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return issued
+}
+
+func newCA(t *testing.T, dir, name string) *testCertificate {
+	return issueCertificate(t, dir, name, &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+}
+
+func TestServerRequiresClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t, dir, "ca")
+	rogueCA := newCA(t, dir, "rogue-ca")
+	serverCertificate := issueCertificate(t, dir, "server", &x509.Certificate{
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	clientCertificate := issueCertificate(t, dir, "client", &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	rogueCertificate := issueCertificate(t, dir, "rogue", &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, rogueCA)
+
+	if _, err := remote.ServerCredentials(serverCertificate.certFile, serverCertificate.keyFile, ""); err == nil {
+		t.Fatal("Expected server credentials without a client CA to be rejected")
+	}
+	serverCredentials, err := remote.ServerCredentials(serverCertificate.certFile, serverCertificate.keyFile, ca.certFile)
+	if err != nil {
+		t.Fatalf("Failed to load server credentials: %v", err)
+	}
+
+	custody, err := remote.NewServer(crypto.DefaultRegistry, "")
+	if err != nil {
+		t.Fatalf("Failed to create key custody server: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := grpc.NewServer(grpc.Creds(serverCredentials))
+	remote.RegisterKeyCustodyServer(server, custody)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	generate := func(transport credentials.TransportCredentials) error {
+		client, err := remote.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(transport))
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		_, err = client.GenerateKey(&remote.GenerateKeyRequest{Algorithm: "ECC"})
+		return err
+	}
+
+	clientCredentials, err := remote.ClientCredentials(ca.certFile, clientCertificate.certFile, clientCertificate.keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load client credentials: %v", err)
+	}
+	if err := generate(clientCredentials); err != nil {
+		t.Fatalf("Expected a client with a trusted certificate to be served, got %v", err)
+	}
+
+	rogueCredentials, err := remote.ClientCredentials(ca.certFile, rogueCertificate.certFile, rogueCertificate.keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load client credentials: %v", err)
+	}
+	if err := generate(rogueCredentials); err == nil {
+		t.Fatal("Expected a client certificate from an untrusted CA to be rejected")
+	}
+
+	withoutCertificate, err := credentials.NewClientTLSFromFile(ca.certFile, "")
+	if err != nil {
+		t.Fatalf("Failed to load CA: %v", err)
+	}
+	if err := generate(withoutCertificate); err == nil {
+		t.Fatal("Expected a client without a certificate to be rejected")
+	}
+}
+
+func TestIsLoopbackAddress(t *testing.T) {
+	for address, expected := range map[string]bool{
+		"127.0.0.1:8700": true,
+		"[::1]:8700":     true,
+		"localhost:8700": true,
+		":8700":          false,
+		"0.0.0.0:8700":   false,
+		"10.0.0.1:8700":  false,
+		"127.0.0.1":      false,
+	} {
+		if actual := remote.IsLoopbackAddress(address); actual != expected {
+			t.Errorf("IsLoopbackAddress(%q) = %v, expected %v", address, actual, expected)
+		}
+	}
+}
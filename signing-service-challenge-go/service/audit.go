@@ -0,0 +1,154 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/errors"
+)
+
+// AuditReport summarizes the integrity check of a device's signature chain.
+type AuditReport struct {
+	DeviceID            string
+	TransactionsChecked int
+	Valid               bool
+	// BrokenAt is the counter of the first broken link, or nil if the chain is intact.
+	BrokenAt *int
+	Reason   string
+}
+
+// AuditDevice walks all stored transactions of a device from counter 0 and decomposes each secured data
+// string in the device's format to check that it embeds its counter, its data and its predecessor's signature
+// exactly as the device built it, and that every signature verifies against the public key the device used for that counter.
+// Across key rotations, the last transaction signed with a retired key has to announce its successor.
+// The first broken link is reported; the walk stops there. A chain that ends before the device's
+// signature counter is broken at the first missing counter.
+func (s *TransactionService) AuditDevice(deviceId string) (*AuditReport, error) {
+	device, exists := s.deviceRepository.GetDeviceById(deviceId)
+	if !exists {
+		return nil, errors.WrapError(nil,
+			fmt.Sprintf("Device with id %s not found", deviceId),
+			http.StatusNotFound,
+		)
+	}
+
+	// The counter is read before the transactions, so that transactions signed concurrently
+	// can only add to the chain rather than appear to be missing.
+	committed, _ := device.SignatureState()
+
+	transactions, err := s.transactionRepository.GetTransactionsByDevice(deviceId)
+	if err != nil {
+		return nil, errors.WrapError(
+			err,
+			"Failed to list transactions from repository",
+			http.StatusInternalServerError,
+		)
+	}
+
+	report := &AuditReport{DeviceID: deviceId, Valid: true}
+
+	rotations, err := expectedRotationRecords(device)
+	if err != nil {
+		return nil, errors.WrapError(
+			err,
+			"Failed to encode the public keys of the device",
+			http.StatusInternalServerError,
+		)
+	}
+
+	var lastSignature []byte
+	for counter, transaction := range transactions {
+		reason, err := auditLink(device, counter, transaction, lastSignature, rotations)
+		if err != nil {
+			return nil, errors.WrapError(
+				err,
+				"An error occurred while verifying the signature",
+				http.StatusInternalServerError,
+			)
+		}
+
+		report.TransactionsChecked++
+		if reason != "" {
+			report.Valid = false
+			report.BrokenAt = &counter
+			report.Reason = reason
+			break
+		}
+
+		lastSignature = transaction.Signature
+	}
+
+	if missing := len(transactions); report.Valid && missing < committed {
+		report.Valid = false
+		report.BrokenAt = &missing
+		report.Reason = fmt.Sprintf("transaction with signature counter %d is missing, the device has signed %d", missing, committed)
+	}
+
+	return report, nil
+}
+
+// auditLink checks a single link of the signature chain and returns why it is broken, if it is.
+func auditLink(
+	device *domain.SignatureDevice,
+	counter int,
+	transaction *domain.Transaction,
+	lastSignature []byte,
+	rotations map[int]string,
+) (string, error) {
+	if transaction.DeviceID != device.ID {
+		return fmt.Sprintf("transaction belongs to device %s", transaction.DeviceID), nil
+	}
+
+	if transaction.Counter != counter {
+		return fmt.Sprintf("expected signature counter %d, found %d", counter, transaction.Counter), nil
+	}
+
+	formatter := device.SecuredDataFormatter()
+	securedData, err := formatter.Parse(transaction.SecuredData)
+	if err != nil {
+		return fmt.Sprintf("secured data cannot be parsed: %v", err), nil
+	}
+	if securedData.Counter != counter {
+		return fmt.Sprintf("secured data embeds signature counter %d", securedData.Counter), nil
+	}
+	if securedData.Data != transaction.Data {
+		return "secured data does not embed the transaction data", nil
+	}
+	if !bytes.Equal(securedData.LastSignature, domain.ChainReference(device.ID, counter, lastSignature)) {
+		return "secured data does not embed the previous signature", nil
+	}
+	if formatter.Format(securedData) != transaction.SecuredData {
+		return "secured data is not in the device's format", nil
+	}
+
+	if expected, rotated := rotations[counter]; rotated && transaction.Data != expected {
+		return "rotation record does not announce the next key", nil
+	}
+
+	valid, err := device.VerifierFor(counter).Verify([]byte(transaction.SecuredData), transaction.Signature)
+	if err != nil {
+		return "", err
+	}
+	if !valid {
+		return "signature does not verify against the device public key", nil
+	}
+
+	return "", nil
+}
+
+// expectedRotationRecords maps the counter of every rotation record of a device to the data it has to carry.
+func expectedRotationRecords(device *domain.SignatureDevice) (map[int]string, error) {
+	keys := device.KeyGenerations()
+
+	rotations := make(map[int]string, len(keys)-1)
+	for i, key := range keys[:len(keys)-1] {
+		data, err := domain.KeyRotationData(keys[i+1].Generation, keys[i+1].PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		rotations[key.ValidUntil-1] = data
+	}
+	return rotations, nil
+}
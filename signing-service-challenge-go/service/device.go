@@ -1,7 +1,10 @@
 package service
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
@@ -13,70 +16,78 @@ import (
 // DeviceService handles operations related to signature devices.
 type DeviceService struct {
 	deviceRepository infrastructure.DeviceRepository
+	algorithms       *crypto.Registry
 }
 
 // NewDeviceService creates a new DeviceService.
-func NewDeviceService(deviceRepository infrastructure.DeviceRepository) *DeviceService {
-	return &DeviceService{deviceRepository: deviceRepository}
-}
-
-// CreateSignatureDevice creates and stores a new signature device.
-func (s *DeviceService) CreateSignatureDevice(algorithm, label string) (*domain.SignatureDevice, error) {
-	deviceID := uuid.New()
-	device := &domain.SignatureDevice{
-		ID:               deviceID,
-		Label:            label,
-		Algorithm:        algorithm,
-		SignatureCounter: 0,
+// Devices can be created with any algorithm registered in the given registry.
+func NewDeviceService(deviceRepository infrastructure.DeviceRepository, algorithms *crypto.Registry) *DeviceService {
+	return &DeviceService{
+		deviceRepository: deviceRepository,
+		algorithms:       algorithms,
 	}
+}
 
-	// Generate algorithm-based KeyPair
-	var err error
-	switch algorithm {
-	case "RSA":
-		generator := &crypto.RSAGenerator{}
-		keyPair, err := generator.Generate()
-		if err != nil {
-			return nil, errors.WrapError(
-				err,
-				"Failed to generate RSA key pair",
-				http.StatusInternalServerError,
-			)
-		}
-		device.PrivateKey = keyPair.Private
-		device.PublicKey = keyPair.Public
+// CreateSignatureDevice creates and stores a new signature device that signs secured data in the legacy format.
+// Unset parameters fall back to the algorithm's defaults; the result has to satisfy crypto.DefaultPolicy.
+func (s *DeviceService) CreateSignatureDevice(
+	algorithmName, label string,
+	parameters crypto.Parameters,
+) (*domain.SignatureDevice, error) {
+	return s.CreateSignatureDeviceWithFormat(algorithmName, label, parameters, domain.SecuredDataFormatLegacy)
+}
 
-	case "ECC":
-		generator := &crypto.ECCGenerator{}
-		keyPair, err := generator.Generate()
-		if err != nil {
-			return nil, errors.WrapError(
-				err,
-				"Failed to generate ECC key pair",
-				http.StatusInternalServerError,
-			)
-		}
-		device.PrivateKey = keyPair.Private
-		device.PublicKey = keyPair.Public
+// CreateSignatureDeviceWithFormat creates and stores a new signature device like CreateSignatureDevice
+// that lays out the secured data it signs in the given format.
+func (s *DeviceService) CreateSignatureDeviceWithFormat(
+	algorithmName, label string,
+	parameters crypto.Parameters,
+	format domain.SecuredDataFormat,
+) (*domain.SignatureDevice, error) {
+	format, err := domain.ParseSecuredDataFormat(string(format))
+	if err != nil {
+		return nil, errors.WrapError(nil, err.Error(), http.StatusBadRequest)
+	}
 
-	default:
+	algorithm, exists := s.algorithms.Get(algorithmName)
+	if !exists {
 		return nil, errors.WrapError(
 			nil,
-			"Unsupported algorithm "+algorithm,
+			"Unsupported algorithm "+algorithmName,
 			http.StatusBadRequest,
 		)
 	}
 
-	// Get signer based on the private key
-	device.Signer, err = crypto.GetSigner(device.PrivateKey)
+	parameters, err = crypto.DefaultPolicy.Resolve(algorithm, parameters)
 	if err != nil {
 		return nil, errors.WrapError(
-			err,
-			"Failed to get signer for device",
-			http.StatusInternalServerError,
+			nil,
+			err.Error(),
+			http.StatusBadRequest,
 		)
 	}
 
+	deviceID := uuid.New()
+	device := &domain.SignatureDevice{
+		ID:                deviceID,
+		Label:             label,
+		Algorithm:         algorithm.Name,
+		Parameters:        parameters,
+		SignatureCounter:  0,
+		Status:            domain.DeviceStatusActive,
+		SecuredDataFormat: format,
+	}
+
+	// Generate algorithm-based KeyPair
+	key, err := generateKey(algorithm, parameters)
+	if err != nil {
+		return nil, err
+	}
+	device.PrivateKey = key.PrivateKey
+	device.PublicKey = key.PublicKey
+	device.Signer = key.Signer
+	device.Verifier = key.Verifier
+
 	// Save the device in the repository
 	err = s.deviceRepository.Save(device.ID.String(), device)
 	if err != nil {
@@ -90,6 +101,75 @@ func (s *DeviceService) CreateSignatureDevice(algorithm, label string) (*domain.
 	return device, nil
 }
 
+// GenerateKey generates a new key pair for an existing device with the device's algorithm and parameters,
+// ready to be rotated to. Inactive devices cannot rotate their key, so no key is generated for them.
+func (s *DeviceService) GenerateKey(id string) (domain.NewKey, error) {
+	device, exists := s.deviceRepository.GetDeviceById(id)
+	if !exists {
+		return domain.NewKey{}, errors.WrapError(nil, "Device not found", http.StatusNotFound)
+	}
+	if status, _ := device.LifecycleState(); status != domain.DeviceStatusActive {
+		return domain.NewKey{}, errors.WrapError(
+			&domain.InactiveDeviceError{DeviceID: device.ID, Status: status},
+			fmt.Sprintf("Device with id %s is %s and cannot rotate its key", id, status),
+			http.StatusConflict,
+		)
+	}
+
+	algorithm, exists := s.algorithms.Get(device.Algorithm)
+	if !exists {
+		return domain.NewKey{}, errors.WrapError(
+			nil,
+			"Unsupported algorithm "+device.Algorithm,
+			http.StatusInternalServerError,
+		)
+	}
+
+	return generateKey(algorithm, device.Parameters)
+}
+
+// generateKey generates a key pair together with its signer and verifier.
+func generateKey(algorithm *crypto.Algorithm, parameters crypto.Parameters) (domain.NewKey, error) {
+	keyPair, err := algorithm.Generate(parameters)
+	if err != nil {
+		return domain.NewKey{}, errors.WrapError(
+			err,
+			"Failed to generate "+algorithm.Name+" key pair",
+			http.StatusInternalServerError,
+		)
+	}
+
+	signer, err := algorithm.NewSigner(keyPair.Private, parameters)
+	if err != nil {
+		return domain.NewKey{}, errors.WrapError(
+			err,
+			"Failed to get signer for device",
+			http.StatusInternalServerError,
+		)
+	}
+
+	verifier, err := algorithm.NewVerifier(keyPair.Public, parameters)
+	if err != nil {
+		return domain.NewKey{}, errors.WrapError(
+			err,
+			"Failed to get verifier for device",
+			http.StatusInternalServerError,
+		)
+	}
+
+	return domain.NewKey{
+		PublicKey:  keyPair.Public,
+		PrivateKey: keyPair.Private,
+		Signer:     signer,
+		Verifier:   verifier,
+	}, nil
+}
+
+// ListAlgorithms returns the signature algorithms devices can be created with.
+func (s *DeviceService) ListAlgorithms() []*crypto.Algorithm {
+	return s.algorithms.List()
+}
+
 // GetDevice retrieves a signature device by ID.
 func (s *DeviceService) GetDevice(id string) (*domain.SignatureDevice, bool) {
 	device, exists := s.deviceRepository.GetDeviceById(id)
@@ -111,3 +191,249 @@ func (s *DeviceService) ListDevices() ([]*domain.SignatureDevice, error) {
 	}
 	return devices, nil
 }
+
+// Limits on the descriptive details of a device.
+const (
+	maxLabelLength         = 256
+	maxMetadataEntries     = 32
+	maxMetadataKeyLength   = 64
+	maxMetadataValueLength = 256
+)
+
+// DeviceUpdate describes a partial update of a signature device. Unset fields are left unchanged.
+// Metadata is merged into the device's metadata; keys with a nil value are removed.
+// The algorithm, keys and signature counter are deliberately not updatable.
+type DeviceUpdate struct {
+	Label    *string
+	Metadata map[string]*string
+	Status   *domain.DeviceStatus
+}
+
+// UpdateDevice applies a partial update to a device and persists it.
+// If the device was modified concurrently, it is fetched again and the update retried.
+func (s *DeviceService) UpdateDevice(id string, update DeviceUpdate) (*domain.SignatureDevice, error) {
+	if err := validateDeviceUpdate(update); err != nil {
+		return nil, errors.WrapError(nil, err.Error(), http.StatusBadRequest)
+	}
+
+	for attempt := 0; ; attempt++ {
+		stored, exists := s.deviceRepository.GetDeviceById(id)
+		if !exists {
+			return nil, errors.WrapError(nil, "Device not found", http.StatusNotFound)
+		}
+
+		// The changes are made to a copy, so that the shared device only sees them once they are stored.
+		device := stored.Copy()
+
+		if _, metadata := device.Details(); mergedMetadataEntries(metadata, update.Metadata) > maxMetadataEntries {
+			return nil, errors.WrapError(nil,
+				fmt.Sprintf("Devices can have at most %d metadata entries", maxMetadataEntries),
+				http.StatusBadRequest,
+			)
+		}
+		if update.Status != nil {
+			if err := device.ChangeStatus(*update.Status, time.Now()); err != nil {
+				return nil, errors.WrapError(err, err.Error(), http.StatusConflict)
+			}
+		}
+		device.UpdateDetails(update.Label, update.Metadata)
+
+		err := s.deviceRepository.UpdateDevice(device)
+		if err == nil {
+			label, metadata := device.Details()
+			status, decommissionedAt := device.LifecycleState()
+			stored.SyncStoredState(device.CurrentVersion(), label, metadata, status, decommissionedAt)
+			return stored, nil
+		}
+		if !infrastructure.IsVersionConflict(err) {
+			return nil, errors.WrapError(
+				err,
+				"Failed to update device in repository",
+				http.StatusInternalServerError,
+			)
+		}
+		if attempt == maxConflictRetries {
+			return nil, errors.WrapError(err,
+				"Device was modified concurrently, please retry",
+				http.StatusConflict,
+			)
+		}
+	}
+}
+
+// ChangeDeviceStatus moves a device to the given lifecycle status and persists it.
+// Suspended devices can be reactivated, while decommissioning is irreversible.
+func (s *DeviceService) ChangeDeviceStatus(id string, status domain.DeviceStatus) (*domain.SignatureDevice, error) {
+	return s.UpdateDevice(id, DeviceUpdate{Status: &status})
+}
+
+// mergedMetadataEntries counts the metadata entries a device has after merging in an update.
+func mergedMetadataEntries(current map[string]string, update map[string]*string) int {
+	entries := len(current)
+	for key, value := range update {
+		_, exists := current[key]
+		switch {
+		case value == nil && exists:
+			entries--
+		case value != nil && !exists:
+			entries++
+		}
+	}
+	return entries
+}
+
+// validateDeviceUpdate checks the label and metadata of an update against the limits.
+func validateDeviceUpdate(update DeviceUpdate) error {
+	if update.Label != nil && len(*update.Label) > maxLabelLength {
+		return fmt.Errorf("label must not be longer than %d bytes", maxLabelLength)
+	}
+	for key, value := range update.Metadata {
+		if key == "" || len(key) > maxMetadataKeyLength {
+			return fmt.Errorf("metadata keys must be between 1 and %d bytes long", maxMetadataKeyLength)
+		}
+		if value != nil && len(*value) > maxMetadataValueLength {
+			return fmt.Errorf("metadata value of %q must not be longer than %d bytes", key, maxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// Supported public key export formats.
+const (
+	PublicKeyFormatPEM = "pem"
+	PublicKeyFormatDER = "der"
+	PublicKeyFormatJWK = "jwk"
+)
+
+// ExportPublicKey encodes the public key of a device in the requested format.
+func (s *DeviceService) ExportPublicKey(id string, format string) ([]byte, error) {
+	device, exists := s.deviceRepository.GetDeviceById(id)
+	if !exists {
+		return nil, errors.WrapError(nil, "Device not found", http.StatusNotFound)
+	}
+
+	var encoded []byte
+	var err error
+	switch format {
+	case PublicKeyFormatPEM:
+		encoded, err = s.encodePublicKeyPEM(device)
+	case PublicKeyFormatDER:
+		encoded, err = s.encodePublicKeyPEM(device)
+		if err == nil {
+			encoded, err = crypto.DecodePEM(encoded)
+		}
+	case PublicKeyFormatJWK:
+		var jwk *crypto.JWK
+		jwk, err = s.encodePublicKeyJWK(device)
+		if err == nil {
+			encoded, err = json.Marshal(jwk)
+		}
+	default:
+		return nil, errors.WrapError(nil, "Unsupported public key format "+format, http.StatusBadRequest)
+	}
+
+	if err != nil {
+		return nil, errors.WrapError(
+			err,
+			"Failed to encode public key",
+			http.StatusInternalServerError,
+		)
+	}
+
+	return encoded, nil
+}
+
+// ListPublicKeys returns the public keys of all signature devices as a JWK set.
+func (s *DeviceService) ListPublicKeys() (*crypto.JWKSet, error) {
+	devices, err := s.ListDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := &crypto.JWKSet{Keys: make([]crypto.JWK, 0, len(devices))}
+	for _, device := range devices {
+		jwk, err := s.encodePublicKeyJWK(device)
+		if err != nil {
+			return nil, errors.WrapError(
+				err,
+				"Failed to encode public key of device "+device.ID.String(),
+				http.StatusInternalServerError,
+			)
+		}
+		jwks.Keys = append(jwks.Keys, *jwk)
+	}
+
+	return jwks, nil
+}
+
+// RewrapPrivateKeys re-wraps the sealed private keys of all devices under the current
+// key-encryption key of sealer and writes them back if the repository persists keys.
+// Persisting also seals keys that were loaded from plaintext storage. It returns the
+// number of keys whose key-encryption key changed.
+func (s *DeviceService) RewrapPrivateKeys(sealer *crypto.KeySealer) (int, error) {
+	devices, err := s.ListDevices()
+	if err != nil {
+		return 0, err
+	}
+
+	updater, persistent := s.deviceRepository.(infrastructure.PrivateKeyUpdater)
+
+	rewrapped := 0
+	for _, device := range devices {
+		sealed, ok := device.PrivateKey.(*crypto.SealedPrivateKey)
+		if !ok {
+			continue
+		}
+
+		changed, err := sealer.Rewrap(sealed)
+		if err != nil {
+			return rewrapped, errors.WrapError(
+				err,
+				"Failed to re-wrap private key of device "+device.ID.String(),
+				http.StatusInternalServerError,
+			)
+		}
+		if changed {
+			rewrapped++
+		}
+
+		if persistent {
+			if err := updater.UpdatePrivateKey(device); err != nil {
+				return rewrapped, errors.WrapError(
+					err,
+					"Failed to store private key of device "+device.ID.String(),
+					http.StatusInternalServerError,
+				)
+			}
+		}
+	}
+
+	return rewrapped, nil
+}
+
+// encodePublicKeyPEM encodes the public key of a device with the marshaler of its algorithm.
+func (s *DeviceService) encodePublicKeyPEM(device *domain.SignatureDevice) ([]byte, error) {
+	algorithm, exists := s.algorithms.Get(device.Algorithm)
+	if !exists {
+		return nil, fmt.Errorf("algorithm %s is not registered", device.Algorithm)
+	}
+
+	publicKey, _, err := algorithm.Marshaler.Marshal(crypto.KeyPair{
+		Public:  device.PublicKey,
+		Private: device.PrivateKey,
+	})
+	return publicKey, err
+}
+
+// encodePublicKeyJWK converts the public key of a device into a JWK tagged with the device's algorithm.
+func (s *DeviceService) encodePublicKeyJWK(device *domain.SignatureDevice) (*crypto.JWK, error) {
+	jwk, err := crypto.EncodePublicKeyJWK(device.PublicKey, device.ID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if algorithm, exists := s.algorithms.Get(device.Algorithm); exists && algorithm.JWA != nil {
+		jwk.Algorithm = algorithm.JWA(device.Parameters)
+	}
+	return jwk, nil
+}
@@ -1,69 +1,365 @@
 package service
 
 import (
-	"encoding/base64"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/errors"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/infrastructure"
 	"net/http"
+	"time"
 )
 
 // TransactionService handles operations related to transactions.
 type TransactionService struct {
-	deviceRepository infrastructure.DeviceRepository
+	deviceRepository      infrastructure.DeviceRepository
+	transactionRepository infrastructure.TransactionRepository
+	idempotencyStore      infrastructure.IdempotencyStore
 }
 
+// DefaultIdempotencyWindow is how long idempotency keys of sign requests are remembered by default.
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// MaxBatchSize bounds the number of data items signed in one batch.
+const MaxBatchSize = 1000
+
+// maxIdempotencyKeyLength bounds the length of idempotency keys.
+const maxIdempotencyKeyLength = 255
+
 // NewTransactionService creates a new TransactionService.
-func NewTransactionService(deviceRepository infrastructure.DeviceRepository) *TransactionService {
-	return &TransactionService{deviceRepository: deviceRepository}
+// Idempotency keys are kept in memory for DefaultIdempotencyWindow unless another store is set.
+func NewTransactionService(
+	deviceRepository infrastructure.DeviceRepository,
+	transactionRepository infrastructure.TransactionRepository,
+) *TransactionService {
+	return &TransactionService{
+		deviceRepository:      deviceRepository,
+		transactionRepository: transactionRepository,
+		idempotencyStore:      infrastructure.NewInMemoryIdempotencyStore(DefaultIdempotencyWindow),
+	}
+}
+
+// SetIdempotencyStore replaces the store idempotency keys are tracked in, e.g. to change the window
+// keys expire after. It must be called before the service handles requests.
+func (s *TransactionService) SetIdempotencyStore(store infrastructure.IdempotencyStore) {
+	s.idempotencyStore = store
 }
 
+// maxConflictRetries bounds how often an operation is retried after a version conflict.
+const maxConflictRetries = 3
+
 // SignTransaction signs data using the specified signature device.
-func (s *TransactionService) SignTransaction(deviceId string, data string) (string, string, error) {
-	device, exists := s.deviceRepository.GetDeviceById(deviceId)
-	if !exists {
-		return "", "", errors.WrapError(nil,
-			fmt.Sprintf(
-				"Device with id %s not found", deviceId,
-			),
-			http.StatusNotFound,
+// The resulting transaction is persisted in the same critical section that advances the signature counter.
+// If the device was modified concurrently, it is fetched again and the operation retried.
+// Devices that are suspended or decommissioned refuse to sign.
+func (s *TransactionService) SignTransaction(deviceId string, data string) (*domain.Transaction, error) {
+	var transaction *domain.Transaction
+	err := s.withDevice(deviceId, "error while signing the data", func(device *domain.SignatureDevice) error {
+		var err error
+		transaction, err = device.Sign(data, s.transactionRepository.SaveTransaction)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// SignTransactions signs the given data items in order as consecutive transactions of the device.
+// The items are signed and persisted in one critical section, so that no other signature can be
+// interleaved, and either all transactions are stored or none of them.
+func (s *TransactionService) SignTransactions(deviceId string, data []string) ([]*domain.Transaction, error) {
+	if len(data) == 0 || len(data) > MaxBatchSize {
+		return nil, errors.WrapError(nil,
+			fmt.Sprintf("A batch must contain between 1 and %d data items", MaxBatchSize),
+			http.StatusBadRequest,
 		)
 	}
 
-	securedData, err := device.BuildSignData(data)
+	persist := func(transactions []*domain.Transaction) error {
+		for _, transaction := range transactions {
+			if err := s.transactionRepository.SaveTransaction(transaction); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if repository, ok := s.transactionRepository.(infrastructure.BatchTransactionRepository); ok {
+		persist = repository.SaveTransactions
+	}
+
+	var transactions []*domain.Transaction
+	err := s.withDevice(deviceId, "error while signing the batch", func(device *domain.SignatureDevice) error {
+		var err error
+		transactions, err = device.SignBatch(data, persist)
+		return err
+	})
 	if err != nil {
-		return "", "", errors.WrapError(
-			err,
-			"An error occurred while building secured data",
-			http.StatusInternalServerError,
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// CanonicalizePayload returns the RFC 8785 canonical form of a JSON object to be signed as transaction data,
+// so that semantically equal payloads are always signed as the same bytes.
+func (s *TransactionService) CanonicalizePayload(payload []byte) (string, error) {
+	if trimmed := bytes.TrimSpace(payload); len(trimmed) == 0 || trimmed[0] != '{' {
+		return "", errors.WrapError(nil, "Payload must be a JSON object", http.StatusBadRequest)
+	}
+
+	canonical, err := domain.CanonicalizeJSON(payload)
+	if err != nil {
+		return "", errors.WrapError(err,
+			fmt.Sprintf("Payload cannot be canonicalized: %v", err),
+			http.StatusBadRequest,
 		)
 	}
+	return canonical, nil
+}
+
+// SignTransactionIdempotent signs data like SignTransaction, unless the device already signed a request
+// with the same idempotency key, in which case the original transaction is returned and replayed is true.
+// Reusing a key with different data, or while the first request is still being processed, is rejected.
+// An empty key signs without idempotency.
+func (s *TransactionService) SignTransactionIdempotent(
+	deviceId string,
+	data string,
+	idempotencyKey string,
+) (transaction *domain.Transaction, replayed bool, err error) {
+	if idempotencyKey == "" {
+		transaction, err = s.SignTransaction(deviceId, data)
+		return transaction, false, err
+	}
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		return nil, false, errors.WrapError(nil,
+			fmt.Sprintf("Idempotency key must not be longer than %d characters", maxIdempotencyKeyLength),
+			http.StatusBadRequest,
+		)
+	}
+
+	// Keys are scoped per device, so that clients of different devices cannot collide.
+	key := deviceId + "/" + idempotencyKey
+	digest := sha256.Sum256([]byte(data))
+	fingerprint := hex.EncodeToString(digest[:])
+
+	record, reserved := s.idempotencyStore.Reserve(key, fingerprint)
+	if !reserved {
+		transaction, err = s.replay(deviceId, record, fingerprint)
+		return transaction, err == nil, err
+	}
 
-	signature, err := device.Signer.Sign([]byte(securedData))
+	transaction, err = s.SignTransaction(deviceId, data)
 	if err != nil {
-		return "", "", errors.WrapError(err,
-			"error while signing the data",
+		s.idempotencyStore.Release(key)
+		return nil, false, err
+	}
+
+	s.idempotencyStore.Complete(key, transaction.Counter)
+	return transaction, false, nil
+}
+
+// replay returns the transaction signed for an idempotency key that is already in use.
+func (s *TransactionService) replay(
+	deviceId string,
+	record infrastructure.IdempotencyRecord,
+	fingerprint string,
+) (*domain.Transaction, error) {
+	if record.Fingerprint != fingerprint {
+		return nil, errors.WrapError(nil,
+			"Idempotency key was already used with a different request",
+			http.StatusUnprocessableEntity,
+		)
+	}
+	if !record.Completed {
+		return nil, errors.WrapError(nil,
+			"A request with this idempotency key is still being processed",
+			http.StatusConflict,
+		)
+	}
+
+	transaction, exists := s.transactionRepository.GetTransaction(deviceId, record.Counter)
+	if !exists {
+		return nil, errors.WrapError(nil,
+			fmt.Sprintf("Transaction with counter %d not found for device %s", record.Counter, deviceId),
 			http.StatusInternalServerError,
 		)
 	}
+	return transaction, nil
+}
+
+// RotateKey replaces the key pair of a device with the given new key. A rotation record announcing
+// the new public key is signed with the old key as the next transaction of the chain.
+// If the repository stores keys, the record and the new key are persisted atomically.
+func (s *TransactionService) RotateKey(deviceId string, key domain.NewKey) (*domain.KeyRotation, error) {
+	persist := func(rotation *domain.KeyRotation) error {
+		return s.transactionRepository.SaveTransaction(rotation.Transaction)
+	}
+	if repository, ok := s.transactionRepository.(infrastructure.KeyRotationRepository); ok {
+		persist = repository.SaveKeyRotation
+	}
+
+	var rotation *domain.KeyRotation
+	err := s.withDevice(deviceId, "error while rotating the key", func(device *domain.SignatureDevice) error {
+		var err error
+		rotation, err = device.RotateKey(key, time.Now(), persist)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rotation, nil
+}
+
+// withDevice runs an operation that extends the signature chain of a device and persists the device afterwards.
+// If the device was modified concurrently, it is fetched again and the operation retried.
+// Failures other than inactive devices and conflicts are reported with the given message.
+func (s *TransactionService) withDevice(
+	deviceId string,
+	message string,
+	operation func(device *domain.SignatureDevice) error,
+) error {
+	var device *domain.SignatureDevice
+	for attempt := 0; ; attempt++ {
+		var exists bool
+		device, exists = s.deviceRepository.GetDeviceById(deviceId)
+		if !exists {
+			return errors.WrapError(nil,
+				fmt.Sprintf(
+					"Device with id %s not found", deviceId,
+				),
+				http.StatusNotFound,
+			)
+		}
+
+		err := operation(device)
+		if err == nil {
+			break
+		}
+		if domain.IsInactiveDevice(err) {
+			status, _ := device.LifecycleState()
+			return errors.WrapError(err,
+				fmt.Sprintf("Device with id %s is %s and cannot sign", deviceId, status),
+				http.StatusConflict,
+			)
+		}
+		if !infrastructure.IsVersionConflict(err) {
+			return errors.WrapError(err, message, http.StatusInternalServerError)
+		}
+		if attempt == maxConflictRetries {
+			return errors.WrapError(err,
+				"Device was modified concurrently, please retry",
+				http.StatusConflict,
+			)
+		}
+	}
+
+	return s.updateDevice(device)
+}
+
+// updateDevice persists a device, fetching it again and retrying if it was modified concurrently.
+func (s *TransactionService) updateDevice(device *domain.SignatureDevice) error {
+	deviceId := device.ID.String()
+	for attempt := 0; ; attempt++ {
+		err := s.deviceRepository.UpdateDevice(device)
+		if err == nil {
+			return nil
+		}
+		if !infrastructure.IsVersionConflict(err) {
+			return errors.WrapError(
+				err,
+				"An error occurred while updating device in repository",
+				http.StatusInternalServerError,
+			)
+		}
+		if attempt == maxConflictRetries {
+			return errors.WrapError(err,
+				"Device was modified concurrently, please retry",
+				http.StatusConflict,
+			)
+		}
+
+		refreshed, exists := s.deviceRepository.GetDeviceById(deviceId)
+		if !exists {
+			return errors.WrapError(nil,
+				fmt.Sprintf("Device with id %s not found", deviceId),
+				http.StatusNotFound,
+			)
+		}
+		device = refreshed
+	}
+}
+
+// ListTransactions returns a page of the transactions signed by a device, ordered by counter,
+// together with the total number of transactions of that device.
+func (s *TransactionService) ListTransactions(deviceId string, offset, limit int) ([]*domain.Transaction, int, error) {
+	if _, exists := s.deviceRepository.GetDeviceById(deviceId); !exists {
+		return nil, 0, errors.WrapError(nil,
+			fmt.Sprintf("Device with id %s not found", deviceId),
+			http.StatusNotFound,
+		)
+	}
 
-	err = device.CommitSignature(signature)
+	transactions, total, err := s.transactionRepository.GetTransactionsPage(deviceId, offset, limit)
 	if err != nil {
-		return "", "", errors.WrapError(
+		return nil, 0, errors.WrapError(
 			err,
-			"An error occurred while committing the signature: %w",
+			"Failed to list transactions from repository",
 			http.StatusInternalServerError,
 		)
 	}
 
-	err = s.deviceRepository.UpdateDevice(device)
+	return transactions, total, nil
+}
+
+// GetTransaction retrieves the transaction a device signed with the given counter.
+func (s *TransactionService) GetTransaction(deviceId string, counter int) (*domain.Transaction, error) {
+	if _, exists := s.deviceRepository.GetDeviceById(deviceId); !exists {
+		return nil, errors.WrapError(nil,
+			fmt.Sprintf("Device with id %s not found", deviceId),
+			http.StatusNotFound,
+		)
+	}
+
+	transaction, exists := s.transactionRepository.GetTransaction(deviceId, counter)
+	if !exists {
+		return nil, errors.WrapError(nil,
+			fmt.Sprintf("Transaction with counter %d not found for device %s", counter, deviceId),
+			http.StatusNotFound,
+		)
+	}
+
+	return transaction, nil
+}
+
+// VerifySignature checks whether a signature over the given secured data was issued by the device.
+// The signature is checked against the key the device used for the counter embedded in the secured data.
+func (s *TransactionService) VerifySignature(deviceId string, signedData string, signature []byte) (bool, error) {
+	device, exists := s.deviceRepository.GetDeviceById(deviceId)
+	if !exists {
+		return false, errors.WrapError(nil,
+			fmt.Sprintf("Device with id %s not found", deviceId),
+			http.StatusNotFound,
+		)
+	}
+
+	verifier := device.Verifier
+	if securedData, err := device.SecuredDataFormatter().Parse(signedData); err == nil {
+		verifier = device.VerifierFor(securedData.Counter)
+	}
+
+	valid, err := verifier.Verify([]byte(signedData), signature)
 	if err != nil {
-		return "", "", errors.WrapError(
+		return false, errors.WrapError(
 			err,
-			"An error occurred while updating device in repository: %w",
+			"An error occurred while verifying the signature",
 			http.StatusInternalServerError,
 		)
 	}
 
-	return securedData, base64.StdEncoding.EncodeToString(signature), nil
+	return valid, nil
 }